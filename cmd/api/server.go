@@ -8,6 +8,7 @@ import (
 	"os"
 	"time"
 
+	"restapi/internal/api/handlers"
 	mw "restapi/internal/api/middlewares"
 	"restapi/internal/api/routers"
 	"restapi/internal/repository/sqlconnect"
@@ -30,6 +31,25 @@ func main() {
 
 	PORT := os.Getenv("API_PORT")
 
+	// Deleted teachers and students can be restored for this long
+	retention := 30 * 24 * time.Hour
+	if value := os.Getenv("DELETED_RETENTION"); value != "" {
+		retention, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid DELETED_RETENTION: %s", err)
+		}
+	}
+	handlers.SchedulePurge(time.Hour, retention)
+
+	// Responses to POSTs with an Idempotency-Key are replayed for this long
+	idempotencyTTL := 24 * time.Hour
+	if value := os.Getenv("IDEMPOTENCY_TTL"); value != "" {
+		idempotencyTTL, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid IDEMPOTENCY_TTL: %s", err)
+		}
+	}
+
 	cert := "cert.pem"
 	key := "key.pem"
 
@@ -39,21 +59,31 @@ func main() {
 	}
 
 	rl := mw.NewRateLimiter(6, time.Minute)
+	idempotency := mw.NewIdempotency(idempotencyTTL)
 
 	hpp := mw.HPPOptions{
 		CheckQuerry:             true,
 		CheckBody:               true,
 		CheckBodyForContentType: "application/x-www-form-urlencoded",
-		Whitelist:               []string{"sortby", "sortorder", "name", "age", "class"},
+		Whitelist: []string{
+			"sortby", "sortorder", "name", "age", "class", "class_id", "grade", "section", "room",
+			"homeroom_teacher_id", "academic_year_id", "code", "teacher_id", "subject_id", "title",
+			"student_id", "date", "period", "status", "from", "to", "below",
+			"term_id", "weekday", "phone", "email",
+			"expires", "signature", "group_by", "include_deleted",
+			"entity", "id", "actor", "since", "page", "limit", "as_of", "atomic", "key", "dry_run", "fields",
+		},
 	}
 
 	//secureMux := mw.Cors(rl.MiddleWare(mw.ResponseTime(mw.Compression(mw.SecurityHeader(mw.Hpp(hpp)(mux))))))
 	secureMux := utils.ApplyMiddlewares(
 		mux,
 		mw.Hpp(hpp),
+		idempotency.MiddleWare,
 		mw.Compression,
 		mw.SecurityHeader,
 		mw.ResponseTime,
+		mw.RequestID,
 		rl.MiddleWare,
 		mw.Cors,
 	)
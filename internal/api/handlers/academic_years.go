@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+// Both dates have to be YYYY-MM-DD and the range cannot end before it starts
+func checkDateRange(start string, end string) error {
+	startDate, err := time.Parse(time.DateOnly, start)
+	if err != nil {
+		return utils.ErrorHandler(err, "start_date must be formatted as YYYY-MM-DD")
+	}
+	endDate, err := time.Parse(time.DateOnly, end)
+	if err != nil {
+		return utils.ErrorHandler(err, "end_date must be formatted as YYYY-MM-DD")
+	}
+	if endDate.Before(startDate) {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "end_date cannot be before start_date")
+	}
+	return nil
+}
+
+func checkAcademicYearFields(year models.AcademicYear) error {
+	if year.Name == "" {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "name is required")
+	}
+	return checkDateRange(year.StartDate, year.EndDate)
+}
+
+func checkTermFields(term models.Term) error {
+	if term.Name == "" || term.AcademicYearID <= 0 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "name and academic_year_id are required")
+	}
+	return checkDateRange(term.StartDate, term.EndDate)
+}
+
+func GetAcademicYearsHandler(w http.ResponseWriter, r *http.Request) {
+	years, err := sqlconnect.GetAcademicYearsFromDb()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string                `json:"status"`
+		Count  int                   `json:"count"`
+		Data   []models.AcademicYear `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(years),
+		Data:   years,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneAcademicYearHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid academic year id", http.StatusBadRequest)
+		return
+	}
+
+	year, err := sqlconnect.GetOneAcademicYear(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(year)
+}
+
+func AddAcademicYearsHandler(w http.ResponseWriter, r *http.Request) {
+	var newYears []models.AcademicYear
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &newYears)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for _, year := range newYears {
+		err = checkAcademicYearFields(year)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedYears, err := sqlconnect.AddAcademicYears(newYears)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string                `json:"status"`
+		Count  int                   `json:"count"`
+		Data   []models.AcademicYear `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedYears),
+		Data:   addedYears,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateAcademicYearHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid academic year id", http.StatusBadRequest)
+		return
+	}
+
+	var updateYear models.AcademicYear
+	err = json.NewDecoder(r.Body).Decode(&updateYear)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkAcademicYearFields(updateYear)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedYear, err := sqlconnect.UpdateAcademicYear(id, updateYear)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedYear)
+}
+
+func DeleteOneAcademicYearHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid academic year request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneAcademicYear(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Academic year succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Lists terms, optionally of one academic year via ?academic_year_id= or the
+// /academic_years/{id}/terms route
+func GetTermsHandler(w http.ResponseWriter, r *http.Request) {
+	query := ""
+	var args []interface{}
+
+	yearId := r.PathValue("id")
+	if yearId == "" {
+		yearId = r.URL.Query().Get("academic_year_id")
+	}
+	if yearId != "" {
+		query += " AND academic_year_id = ?"
+		args = append(args, yearId)
+	}
+
+	terms, err := sqlconnect.GetTermsFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string        `json:"status"`
+		Count  int           `json:"count"`
+		Data   []models.Term `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(terms),
+		Data:   terms,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneTermHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid term id", http.StatusBadRequest)
+		return
+	}
+
+	term, err := sqlconnect.GetOneTerm(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(term)
+}
+
+func AddTermsHandler(w http.ResponseWriter, r *http.Request) {
+	var newTerms []models.Term
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &newTerms)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for _, term := range newTerms {
+		err = checkTermFields(term)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedTerms, err := sqlconnect.AddTerms(newTerms)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string        `json:"status"`
+		Count  int           `json:"count"`
+		Data   []models.Term `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedTerms),
+		Data:   addedTerms,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateTermHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid term id", http.StatusBadRequest)
+		return
+	}
+
+	var updateTerm models.Term
+	err = json.NewDecoder(r.Body).Decode(&updateTerm)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkTermFields(updateTerm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedTerm, err := sqlconnect.UpdateTerm(id, updateTerm)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedTerm)
+}
+
+func DeleteOneTermHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid term request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneTerm(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Term succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
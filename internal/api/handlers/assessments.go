@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+func checkAssessmentFields(assessment models.Assessment) error {
+	if assessment.ClassID <= 0 || assessment.SubjectID <= 0 || assessment.Title == "" {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "class_id, subject_id and title are required")
+	}
+	if assessment.MaxScore <= 0 || assessment.Weight <= 0 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "max_score and weight must be positive")
+	}
+	_, err := time.Parse(time.DateOnly, assessment.DueDate)
+	if err != nil {
+		return utils.ErrorHandler(err, "due_date must be formatted as YYYY-MM-DD")
+	}
+	return nil
+}
+
+func addAssessmentFilter(r *http.Request, query string, args []interface{}) (string, []interface{}) {
+	params := map[string]string{
+		"class_id":   "class_id",
+		"subject_id": "subject_id",
+		"term_id":    "term_id",
+		"title":      "title",
+	}
+
+	for param, dbField := range params {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + dbField + " = ?"
+			args = append(args, value)
+		}
+	}
+	return query, args
+}
+
+func GetAssessmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var args []interface{}
+	query, args := addAssessmentFilter(r, "", args)
+
+	assessments, err := sqlconnect.GetAssessmentsFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string              `json:"status"`
+		Count  int                 `json:"count"`
+		Data   []models.Assessment `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(assessments),
+		Data:   assessments,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneAssessmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assessment id", http.StatusBadRequest)
+		return
+	}
+
+	assessment, err := sqlconnect.GetOneAssessment(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assessment)
+}
+
+func AddAssessmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var newAssessments []models.Assessment
+	var rawAssessments []map[string]interface{}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &rawAssessments)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	allowedFields := make(map[string]struct{})
+	for _, field := range getFieldNames(models.Assessment{}) {
+		allowedFields[field] = struct{}{}
+	}
+
+	for _, assessment := range rawAssessments {
+		for key := range assessment {
+			if _, ok := allowedFields[key]; !ok {
+				http.Error(w, "unnaccepable field found in request", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	err = json.Unmarshal(body, &newAssessments)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for i := range newAssessments {
+		// Assessments count equally unless told otherwise
+		if newAssessments[i].Weight == 0 {
+			newAssessments[i].Weight = 1
+		}
+		err = checkAssessmentFields(newAssessments[i])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedAssessments, err := sqlconnect.AddAssessments(newAssessments)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string              `json:"status"`
+		Count  int                 `json:"count"`
+		Data   []models.Assessment `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedAssessments),
+		Data:   addedAssessments,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateAssessmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assessment id", http.StatusBadRequest)
+		return
+	}
+
+	var updateAssessment models.Assessment
+	err = json.NewDecoder(r.Body).Decode(&updateAssessment)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkAssessmentFields(updateAssessment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedAssessment, err := sqlconnect.UpdateAssessment(id, updateAssessment)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedAssessment)
+}
+
+func PatchOneAssessmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assessment id", http.StatusBadRequest)
+		return
+	}
+
+	var updates map[string]interface{}
+	err = json.NewDecoder(r.Body).Decode(&updates)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	existingAssessment, err := sqlconnect.PatchOneAssessment(id, updates)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(existingAssessment)
+}
+
+func DeleteOneAssessmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assessment request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneAssessment(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Assessment succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Bulk score entry for one assessment. Takes [{"student_id": 1, "score": 17.5}, ...]
+func AddScoresHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assessment id", http.StatusBadRequest)
+		return
+	}
+
+	var scores []models.Score
+	err = json.NewDecoder(r.Body).Decode(&scores)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	for _, score := range scores {
+		if score.StudentID <= 0 {
+			http.Error(w, "student_id is required for every score", http.StatusBadRequest)
+			return
+		}
+	}
+
+	savedScores, err := sqlconnect.AddScores(id, scores)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string         `json:"status"`
+		Count  int            `json:"count"`
+		Data   []models.Score `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(savedScores),
+		Data:   savedScores,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetStudentGradesHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	grades, averages, err := sqlconnect.GetStudentGradesFromDb(id, r.URL.Query().Get("term_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status   string                  `json:"status"`
+		Count    int                     `json:"count"`
+		Data     []models.StudentGrade   `json:"data"`
+		Averages []models.SubjectAverage `json:"averages"`
+	}{
+		Status:   "success",
+		Count:    len(grades),
+		Data:     grades,
+		Averages: averages,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetClassGradebookHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	gradebook, err := sqlconnect.GetGradebookFromDb(id, r.URL.Query().Get("subject_id"), r.URL.Query().Get("term_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string           `json:"status"`
+		Data   models.Gradebook `json:"data"`
+	}{
+		Status: "success",
+		Data:   gradebook,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
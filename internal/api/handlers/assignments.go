@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/storage"
+	"restapi/pkg/utils"
+)
+
+// Limits for submitted files. The type is sniffed from the content rather
+// than trusted from the upload, .docx and other office files sniff as zip.
+const maxSubmissionSize = 10 << 20
+
+var allowedSubmissionTypes = map[string]bool{
+	"application/pdf":           true,
+	"application/zip":           true,
+	"image/jpeg":                true,
+	"image/png":                 true,
+	"text/plain; charset=utf-8": true,
+}
+
+// How long a download link handed out with a submission stays valid
+const downloadURLTTL = 15 * time.Minute
+
+func checkAssignmentFields(assignment models.Assignment) error {
+	err := checkBlankFields(assignment)
+	if err != nil {
+		return err
+	}
+	_, err = time.Parse(time.DateOnly, assignment.DueDate)
+	if err != nil {
+		return utils.ErrorHandler(err, "due_date must be formatted as YYYY-MM-DD")
+	}
+	return nil
+}
+
+func submissionDownloadURL(id int) string {
+	return utils.SignURL(fmt.Sprintf("/submissions/%d/file", id), downloadURLTTL)
+}
+
+func GetAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	query := ""
+	var args []interface{}
+	for _, param := range []string{"class_id", "subject_id"} {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + param + " = ?"
+			args = append(args, value)
+		}
+	}
+
+	assignments, err := sqlconnect.GetAssignmentsFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string              `json:"status"`
+		Count  int                 `json:"count"`
+		Data   []models.Assignment `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(assignments),
+		Data:   assignments,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assignment id", http.StatusBadRequest)
+		return
+	}
+
+	assignment, err := sqlconnect.GetOneAssignment(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignment)
+}
+
+func AddAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var newAssignments []models.Assignment
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &newAssignments)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for _, assignment := range newAssignments {
+		err = checkAssignmentFields(assignment)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedAssignments, err := sqlconnect.AddAssignments(newAssignments)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string              `json:"status"`
+		Count  int                 `json:"count"`
+		Data   []models.Assignment `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedAssignments),
+		Data:   addedAssignments,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assignment id", http.StatusBadRequest)
+		return
+	}
+
+	var updateAssignment models.Assignment
+	err = json.NewDecoder(r.Body).Decode(&updateAssignment)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkAssignmentFields(updateAssignment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedAssignment, err := sqlconnect.UpdateAssignment(id, updateAssignment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedAssignment)
+}
+
+func DeleteOneAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assignment request", http.StatusBadRequest)
+		return
+	}
+
+	store, err := blobStore()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fileKeys, err := sqlconnect.DeleteOneAssignment(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The rows are gone already, a file that fails to delete is only logged
+	for _, key := range fileKeys {
+		err = store.Delete(key)
+		if err != nil {
+			utils.ErrorHandler(err, "error deleting submission file "+key)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Assignment succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetSubmissionsByAssignmentId(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid assignment id", http.StatusBadRequest)
+		return
+	}
+
+	submissions, err := sqlconnect.GetSubmissionsByAssignmentIdFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range submissions {
+		submissions[i].DownloadURL = submissionDownloadURL(submissions[i].ID)
+	}
+
+	response := struct {
+		Status string              `json:"status"`
+		Count  int                 `json:"count"`
+		Data   []models.Submission `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(submissions),
+		Data:   submissions,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Takes a multipart form with a student_id field and the file under "file".
+// Submitting again replaces the earlier file.
+func AddSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	assignmentId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid assignment id", http.StatusBadRequest)
+		return
+	}
+
+	// Leave room for the other form fields and multipart headers
+	r.Body = http.MaxBytesReader(w, r.Body, maxSubmissionSize+1<<20)
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("file is larger than %d MB", maxSubmissionSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "error parsing multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	studentId, err := strconv.Atoi(r.FormValue("student_id"))
+	if err != nil || studentId <= 0 {
+		http.Error(w, "student_id is required", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxSubmissionSize {
+		http.Error(w, fmt.Sprintf("file is larger than %d MB", maxSubmissionSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		http.Error(w, "error reading file", http.StatusBadRequest)
+		return
+	}
+	contentType := http.DetectContentType(sniff[:n])
+	if !allowedSubmissionTypes[contentType] {
+		http.Error(w, "file type "+contentType+" is not allowed", http.StatusUnsupportedMediaType)
+		return
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		http.Error(w, "error reading file", http.StatusInternalServerError)
+		return
+	}
+
+	store, err := blobStore()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	key := fmt.Sprintf("submissions/%d/%d/%s", assignmentId, studentId, hex.EncodeToString(suffix))
+	err = store.Put(key, file, contentType)
+	if err != nil {
+		utils.ErrorHandler(err, "error storing submission file")
+		http.Error(w, "error storing submission file", http.StatusInternalServerError)
+		return
+	}
+
+	submission, oldKey, err := sqlconnect.SaveSubmission(models.Submission{
+		AssignmentID: assignmentId,
+		StudentID:    studentId,
+		FileKey:      key,
+		FileName:     filepath.Base(header.Filename),
+		ContentType:  contentType,
+		Size:         header.Size,
+	})
+	if err != nil {
+		store.Delete(key)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if oldKey != "" {
+		err = store.Delete(oldKey)
+		if err != nil {
+			utils.ErrorHandler(err, "error deleting replaced submission file "+oldKey)
+		}
+	}
+	submission.DownloadURL = submissionDownloadURL(submission.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(submission)
+}
+
+func GetOneSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid submission id", http.StatusBadRequest)
+		return
+	}
+
+	submission, err := sqlconnect.GetOneSubmission(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	submission.DownloadURL = submissionDownloadURL(submission.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(submission)
+}
+
+// Grades a submission. Takes {"grade": 8.5, "feedback": "..."}, either can
+// be left out.
+func GradeSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid submission id", http.StatusBadRequest)
+		return
+	}
+
+	var grade models.SubmissionGrade
+	err = json.NewDecoder(r.Body).Decode(&grade)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+	if grade.Grade != nil && *grade.Grade < 0 {
+		http.Error(w, "grade cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	submission, err := sqlconnect.GradeSubmission(id, grade)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	submission.DownloadURL = submissionDownloadURL(submission.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(submission)
+}
+
+// Serves the submitted file. Only reachable through the signed, expiring
+// url handed out with the submission.
+func DownloadSubmissionHandler(w http.ResponseWriter, r *http.Request) {
+	err := utils.VerifySignedURL(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid submission id", http.StatusBadRequest)
+		return
+	}
+
+	submission, err := sqlconnect.GetOneSubmission(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	store, err := blobStore()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	file, err := store.Open(submission.FileKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "submission file not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		utils.ErrorHandler(err, "error opening submission file")
+		http.Error(w, "error opening submission file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", submission.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", submission.FileName))
+	w.Header().Set("Cache-Control", "private, no-store")
+	_, err = io.Copy(w, file)
+	if err != nil {
+		utils.ErrorHandler(err, "error sending submission file")
+	}
+}
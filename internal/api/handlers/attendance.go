@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+var validAttendanceStatus = map[string]bool{
+	"present": true,
+	"absent":  true,
+	"late":    true,
+	"excused": true,
+}
+
+func checkAttendanceRegister(register models.AttendanceRegister) error {
+	_, err := time.Parse(time.DateOnly, register.Date)
+	if err != nil {
+		return utils.ErrorHandler(err, "date must be formatted as YYYY-MM-DD")
+	}
+	if register.Period < 0 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "period cannot be negative")
+	}
+	if len(register.Records) == 0 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "register has no records")
+	}
+
+	seen := make(map[int]bool)
+	for _, record := range register.Records {
+		if record.StudentID <= 0 {
+			return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "student_id is required for every record")
+		}
+		if seen[record.StudentID] {
+			return utils.ErrorHandler(fmt.Errorf("invalid field in models"), fmt.Sprintf("student %d is listed more than once", record.StudentID))
+		}
+		seen[record.StudentID] = true
+		if !validAttendanceStatus[record.Status] {
+			return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "status must be one of present, absent, late or excused")
+		}
+	}
+	return nil
+}
+
+// Adds the date range filters "from" and "to" (inclusive) used by every
+// attendance endpoint
+func addDateRangeFilter(r *http.Request, query string, args []interface{}) (string, []interface{}, error) {
+	for param, op := range map[string]string{"from": ">=", "to": "<="} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		_, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return query, args, fmt.Errorf("%s must be formatted as YYYY-MM-DD", param)
+		}
+		query += " AND date " + op + " ?"
+		args = append(args, value)
+	}
+	return query, args, nil
+}
+
+func addAttendanceFilter(r *http.Request, query string, args []interface{}) (string, []interface{}, error) {
+	params := map[string]string{
+		"student_id": "student_id",
+		"date":       "date",
+		"period":     "period",
+		"status":     "status",
+	}
+
+	for param, dbField := range params {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + dbField + " = ?"
+			args = append(args, value)
+		}
+	}
+
+	classId := r.URL.Query().Get("class_id")
+	if classId != "" {
+		query += " AND student_id IN (SELECT id FROM students WHERE class_id = ?)"
+		args = append(args, classId)
+	}
+	return addDateRangeFilter(r, query, args)
+}
+
+func GetAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	var args []interface{}
+	query, args, err := addAttendanceFilter(r, "", args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := sqlconnect.GetAttendanceFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string              `json:"status"`
+		Count  int                 `json:"count"`
+		Data   []models.Attendance `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(records),
+		Data:   records,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Registers attendance for a class on one date and period. Sending the same
+// register again overwrites the earlier one.
+func SaveClassAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	var register models.AttendanceRegister
+	err = json.NewDecoder(r.Body).Decode(&register)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkAttendanceRegister(register)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := sqlconnect.SaveAttendanceRegister(id, register)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string              `json:"status"`
+		Count  int                 `json:"count"`
+		Data   []models.Attendance `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(saved),
+		Data:   saved,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetStudentAttendanceSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	query, args, err := addDateRangeFilter(r, " AND student_id = ?", []interface{}{id})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summaries, err := sqlconnect.GetAttendanceSummariesFromDb(query, args, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// No records in the range still gets a summary
+	summary := models.AttendanceSummary{StudentID: id, Percentage: 100}
+	if len(summaries) > 0 {
+		summary = summaries[0]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string                   `json:"status"`
+		Data   models.AttendanceSummary `json:"data"`
+	}{
+		Status: "success",
+		Data:   summary,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Attendance summaries for many students. With ?below=75 only students under
+// 75% attendance are listed, lowest first.
+func GetAttendanceSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	query := ""
+	var args []interface{}
+
+	classId := r.URL.Query().Get("class_id")
+	if classId != "" {
+		query += " AND student_id IN (SELECT id FROM students WHERE class_id = ?)"
+		args = append(args, classId)
+	}
+
+	query, args, err := addDateRangeFilter(r, query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var below *float64
+	belowStr := r.URL.Query().Get("below")
+	if belowStr != "" {
+		threshold, err := strconv.ParseFloat(belowStr, 64)
+		if err != nil || threshold < 0 || threshold > 100 {
+			http.Error(w, "below must be a percentage between 0 and 100", http.StatusBadRequest)
+			return
+		}
+		below = &threshold
+	}
+
+	summaries, err := sqlconnect.GetAttendanceSummariesFromDb(query, args, below)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string                     `json:"status"`
+		Count  int                        `json:"count"`
+		Data   []models.AttendanceSummary `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(summaries),
+		Data:   summaries,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func DeleteOneAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid attendance request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneAttendance(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Attendance record succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+)
+
+// Lists audit entries newest first. Filters are entity ("teacher" or
+// "student"), id, actor and since, a date or an RFC 3339 time. Paged with
+// page and limit.
+func GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	query := ""
+	var args []interface{}
+	for param, dbField := range map[string]string{"entity": "entity", "actor": "actor"} {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + dbField + " = ?"
+			args = append(args, value)
+		}
+	}
+
+	if idStr := r.URL.Query().Get("id"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		query += " AND entity_id = ?"
+		args = append(args, id)
+	}
+
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		since, err := time.ParseInLocation(time.DateOnly, sinceStr, time.Local)
+		if err != nil {
+			since, err = time.Parse(time.RFC3339, sinceStr)
+		}
+		if err != nil {
+			http.Error(w, "since must be formatted as YYYY-MM-DD or RFC 3339", http.StatusBadRequest)
+			return
+		}
+		// created_at is stored in server local time
+		query += " AND created_at >= ?"
+		args = append(args, since.Local().Format(time.DateTime))
+	}
+
+	page, limit, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, total, err := sqlconnect.GetAuditLogFromDb(query, args, limit, (page-1)*limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string              `json:"status"`
+		Page   int                 `json:"page"`
+		Limit  int                 `json:"limit"`
+		Total  int                 `json:"total"`
+		Count  int                 `json:"count"`
+		Data   []models.AuditEntry `json:"data"`
+	}{
+		Status: "success",
+		Page:   page,
+		Limit:  limit,
+		Total:  total,
+		Count:  len(entries),
+		Data:   entries,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
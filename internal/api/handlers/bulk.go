@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// Bulk POST, PATCH and DELETE on /teachers/ and /students/ save everything
+// or nothing with ?atomic=true. Otherwise every item stands on its own and
+// the answer is 207 with a result per item.
+func atomicBulk(r *http.Request) bool {
+	return r.URL.Query().Get("atomic") == "true"
+}
+
+// Checks one item of a bulk POST, raw is the item as sent
+func checkNewItem(raw map[string]interface{}, allowedFields map[string]struct{}, model interface{}) error {
+	for key := range raw {
+		if _, ok := allowedFields[key]; !ok {
+			return utils.InvalidHandler(nil, fmt.Sprintf("unknown field %q", key))
+		}
+	}
+	err := checkBlankFields(model)
+	if err != nil {
+		return utils.InvalidHandler(nil, err.Error())
+	}
+	return nil
+}
+
+// Runs the items that passed their checks and puts their results back at the
+// index they had in the request, next to the items that failed a check.
+// checkErrs has an entry, nil or not, for every item.
+func runChecked(checkErrs []error, run func(indexes []int) ([]models.BulkItemResult, error)) ([]models.BulkItemResult, error) {
+	results := make([]models.BulkItemResult, len(checkErrs))
+	var indexes []int
+	for i, err := range checkErrs {
+		if err != nil {
+			results[i] = models.BulkItemResult{Index: i, Err: err}
+		} else {
+			indexes = append(indexes, i)
+		}
+	}
+
+	ran, err := run(indexes)
+	if err != nil {
+		return nil, err
+	}
+	for j, result := range ran {
+		result.Index = indexes[j]
+		results[indexes[j]] = result
+	}
+	return results, nil
+}
+
+// The first failed check of an atomic batch, which fails all of it
+func firstCheckErr(checkErrs []error) error {
+	for i, err := range checkErrs {
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Answers a bulk request that was not atomic. done is the status of an item
+// that went through, a student who ended up on a waitlist gets 202.
+func writeBulkResults(w http.ResponseWriter, results []models.BulkItemResult, done int) {
+	failed := setBulkStatuses(results, done)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMultiStatus)
+	response := struct {
+		Status    string                  `json:"status"`
+		Count     int                     `json:"count"`
+		Succeeded int                     `json:"succeeded"`
+		Failed    int                     `json:"failed"`
+		Results   []models.BulkItemResult `json:"results"`
+	}{
+		Status:    "multi-status",
+		Count:     len(results),
+		Succeeded: len(results) - failed,
+		Failed:    failed,
+		Results:   results,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Fills in Status and Error of every result and counts the failed ones. An
+// item that created a row gets 201 whatever done is.
+func setBulkStatuses(results []models.BulkItemResult, done int) int {
+	failed := 0
+	for i := range results {
+		result := &results[i]
+		switch {
+		case result.Err != nil:
+			result.Status = errorStatus(result.Err)
+			result.Error = result.Err.Error()
+			failed++
+		case result.Waitlist != nil:
+			result.Status = http.StatusAccepted
+		case result.Action == models.BulkCreated:
+			result.Status = http.StatusCreated
+		default:
+			result.Status = done
+		}
+	}
+	return failed
+}
+
+// The ids of an atomic batch, in request order
+func bulkIds(results []models.BulkItemResult) []int {
+	ids := make([]int, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	return ids
+}
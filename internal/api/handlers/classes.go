@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+// A class needs a name, grade and section. Room, capacity and homeroom
+// teacher can be filled in later.
+func checkClassFields(class models.Class) error {
+	if class.Name == "" || class.Section == "" || class.Grade <= 0 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "name, grade and section are required")
+	}
+	if class.Capacity < 0 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "capacity cannot be negative")
+	}
+	return nil
+}
+
+func addClassFilter(r *http.Request, query string, args []interface{}) (string, []interface{}) {
+	params := map[string]string{
+		"name":                "name",
+		"grade":               "grade",
+		"section":             "section",
+		"room":                "room",
+		"homeroom_teacher_id": "homeroom_teacher_id",
+		"academic_year_id":    "academic_year_id",
+	}
+
+	for param, dbField := range params {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + dbField + " = ?"
+			args = append(args, value)
+		}
+	}
+	return query, args
+}
+
+// Columns GET /classes/ can be sorted by
+var classSortFields = map[string]bool{
+	"name":     true,
+	"grade":    true,
+	"section":  true,
+	"room":     true,
+	"capacity": true,
+}
+
+func GetClassesHandler(w http.ResponseWriter, r *http.Request) {
+	var args []interface{}
+	query, args := addClassFilter(r, "", args)
+	query = sortBy(r, query, classSortFields)
+
+	classes, err := sqlconnect.GetClassesFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string         `json:"status"`
+		Count  int            `json:"count"`
+		Data   []models.Class `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(classes),
+		Data:   classes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneClassHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	class, err := sqlconnect.GetOneClass(id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(class)
+}
+
+func AddClassesHandler(w http.ResponseWriter, r *http.Request) {
+	var newClasses []models.Class
+	var rawClasses []map[string]interface{}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &rawClasses)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	allowedFields := make(map[string]struct{})
+	for _, field := range getFieldNames(models.Class{}) {
+		allowedFields[field] = struct{}{}
+	}
+
+	for _, class := range rawClasses {
+		for key := range class {
+			if _, ok := allowedFields[key]; !ok {
+				http.Error(w, "unnaccepable field found in request", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	err = json.Unmarshal(body, &newClasses)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for _, class := range newClasses {
+		err = checkClassFields(class)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedClasses, err := sqlconnect.AddClasses(newClasses)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string         `json:"status"`
+		Count  int            `json:"count"`
+		Data   []models.Class `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedClasses),
+		Data:   addedClasses,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateClassHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	var updateClass models.Class
+	err = json.NewDecoder(r.Body).Decode(&updateClass)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkClassFields(updateClass)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedClass, err := sqlconnect.UpdateClass(id, updateClass, auditInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedClass)
+}
+
+func PatchOneClassHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	var updates map[string]interface{}
+	err = json.NewDecoder(r.Body).Decode(&updates)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	existingClass, err := sqlconnect.PatchOneClass(id, updates, auditInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(existingClass)
+}
+
+func DeleteOneClassHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneClass(id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Class succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetStudentsByClassId(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	students, err := sqlconnect.GetStudentsByClassIdFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := struct {
+		Status string           `json:"status"`
+		Count  int              `json:"count"`
+		Data   []models.Student `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(students),
+		Data:   students,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetTeachersByClassId(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	teachers, err := sqlconnect.GetTeachersByClassIdFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := struct {
+		Status string           `json:"status"`
+		Count  int              `json:"count"`
+		Data   []models.Teacher `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(teachers),
+		Data:   teachers,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Students waiting for a seat in the class, first in line first
+func GetWaitlistByClassId(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := sqlconnect.GetWaitlistByClassIdFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := struct {
+		Status string                 `json:"status"`
+		Count  int                    `json:"count"`
+		Data   []models.WaitlistEntry `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(entries),
+		Data:   entries,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func DeleteWaitlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	classId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+	studentId, err := strconv.Atoi(r.PathValue("studentId"))
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteWaitlistEntry(classId, studentId)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status    string `json:"status"`
+		ClassID   int    `json:"class_id"`
+		StudentID int    `json:"student_id"`
+	}{
+		Status:    "Student succesfully removed from waitlist",
+		ClassID:   classId,
+		StudentID: studentId,
+	}
+	json.NewEncoder(w).Encode(response)
+}
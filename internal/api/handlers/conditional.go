@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"restapi/pkg/utils"
+)
+
+// Lists and single rows that can change at any moment are cached by the
+// client but checked with the server every time, which answers 304 while
+// they are unchanged
+const revalidateCacheControl = "private, no-cache"
+
+// CACHE_CONTROL overrides the Cache-Control of single routes, e.g.
+//
+//	CACHE_CONTROL="GET /teachers/=private, max-age=10; GET /students/{id}=no-store"
+//
+// Routes are named by the pattern they are registered with.
+var (
+	cacheControls     map[string]string
+	cacheControlsOnce sync.Once
+)
+
+// The Cache-Control for the route r was matched by, fallback when
+// CACHE_CONTROL does not set one
+func cacheControl(r *http.Request, fallback string) string {
+	cacheControlsOnce.Do(func() {
+		cacheControls = make(map[string]string)
+		for _, entry := range strings.Split(os.Getenv("CACHE_CONTROL"), ";") {
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			pattern, policy, ok := strings.Cut(entry, "=")
+			if !ok || strings.TrimSpace(pattern) == "" {
+				utils.ErrorHandler(fmt.Errorf("invalid CACHE_CONTROL entry %q", entry), "ignoring cache control entry")
+				continue
+			}
+			cacheControls[strings.TrimSpace(pattern)] = strings.TrimSpace(policy)
+		}
+	})
+	if policy, ok := cacheControls[r.Pattern]; ok {
+		return policy
+	}
+	return fallback
+}
+
+// Sets the ETag, Last-Modified and Cache-Control of a GET and answers 304
+// when the client's copy is still current. Reports true when it did, the
+// caller then writes nothing else. Either validator may be left empty.
+func writeNotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time, fallbackCacheControl string) bool {
+	w.Header().Set("Cache-Control", cacheControl(r, fallbackCacheControl))
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// If-Modified-Since is only looked at when there is no If-None-Match
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if etag == "" || !etagMatches(match, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// Weak comparison of an If-None-Match list against the current ETag
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// A list changes when a row in it changes, joins or leaves it, so its ETag
+// is taken from the newest change, the number of rows, the query that
+// picked them and the Accept header that picked the format. It is weak
+// because the list is compressed on the way out.
+func listETag(r *http.Request, count int, lastModified string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%s|%s", count, lastModified, r.URL.RawQuery, r.Header.Get("Accept"))))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// Reads the times sqlconnect returns, "2006-01-02 15:04:05" with or without
+// fractional seconds. The zero time when there is none.
+func parseDbTime(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05.999999", value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		want   bool
+	}{
+		{header: `"3"`, etag: `"3"`, want: true},
+		{header: `"4"`, etag: `"3"`, want: false},
+		{header: `"1", "3"`, etag: `"3"`, want: true},
+		{header: `"1","2"`, etag: `"3"`, want: false},
+		{header: `*`, etag: `"3"`, want: true},
+		{header: `W/"3"`, etag: `"3"`, want: true},
+		{header: `"3"`, etag: `W/"3"`, want: true},
+		{header: ` W/"ab" `, etag: `W/"ab"`, want: true},
+		{header: `3`, etag: `"3"`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header+" "+tt.etag, func(t *testing.T) {
+			if got := etagMatches(tt.header, tt.etag); got != tt.want {
+				t.Fatalf("etagMatches(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListETag(t *testing.T) {
+	request := func(url string, accept string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, url, nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		return r
+	}
+	base := listETag(request("/teachers/?subject=Math", ""), 3, "2024-01-02 03:04:05")
+	if !strings.HasPrefix(base, `W/"`) || !strings.HasSuffix(base, `"`) {
+		t.Fatalf("got %s, want a weak ETag", base)
+	}
+
+	tests := []struct {
+		name         string
+		r            *http.Request
+		count        int
+		lastModified string
+		same         bool
+	}{
+		{name: "same list", r: request("/teachers/?subject=Math", ""), count: 3, lastModified: "2024-01-02 03:04:05", same: true},
+		{name: "path is not part of it", r: request("/students/?subject=Math", ""), count: 3, lastModified: "2024-01-02 03:04:05", same: true},
+		{name: "row left", r: request("/teachers/?subject=Math", ""), count: 2, lastModified: "2024-01-02 03:04:05"},
+		{name: "row changed", r: request("/teachers/?subject=Math", ""), count: 3, lastModified: "2024-01-02 03:04:06"},
+		{name: "other query", r: request("/teachers/?subject=Art", ""), count: 3, lastModified: "2024-01-02 03:04:05"},
+		{name: "other format", r: request("/teachers/?subject=Math", "text/csv"), count: 3, lastModified: "2024-01-02 03:04:05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := listETag(tt.r, tt.count, tt.lastModified)
+			if (got == base) != tt.same {
+				t.Fatalf("got %s against %s, want same %v", got, base, tt.same)
+			}
+		})
+	}
+}
+
+func TestWriteNotModified(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	tests := []struct {
+		name         string
+		header       map[string]string
+		etag         string
+		lastModified time.Time
+		want         bool
+	}{
+		{name: "no conditions", etag: `"3"`, lastModified: modified},
+		{name: "etag matches", header: map[string]string{"If-None-Match": `"3"`}, etag: `"3"`, want: true},
+		{name: "etag changed", header: map[string]string{"If-None-Match": `"2"`}, etag: `"3"`},
+		{name: "no etag to match", header: map[string]string{"If-None-Match": `*`}},
+		{name: "same second", header: map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 03:04:05 GMT"}, lastModified: modified, want: true},
+		{name: "later", header: map[string]string{"If-Modified-Since": "Wed, 03 Jan 2024 00:00:00 GMT"}, lastModified: modified, want: true},
+		{name: "modified since", header: map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 03:04:04 GMT"}, lastModified: modified},
+		{name: "no time to compare", header: map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 03:04:05 GMT"}},
+		{name: "bad date", header: map[string]string{"If-Modified-Since": "yesterday"}, lastModified: modified},
+		{name: "If-None-Match wins", header: map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": "Wed, 03 Jan 2024 00:00:00 GMT"},
+			etag: `"3"`, lastModified: modified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/teachers/3", nil)
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			got := writeNotModified(w, r, tt.etag, tt.lastModified, revalidateCacheControl)
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if tt.want && w.Code != http.StatusNotModified {
+				t.Fatalf("got status %d, want 304", w.Code)
+			}
+			if !tt.want && w.Body.Len() > 0 {
+				t.Fatalf("wrote %q, want nothing", w.Body.String())
+			}
+
+			if got := w.Header().Get("ETag"); got != tt.etag {
+				t.Fatalf("got ETag %q, want %q", got, tt.etag)
+			}
+			wantLastModified := ""
+			if !tt.lastModified.IsZero() {
+				wantLastModified = "Tue, 02 Jan 2024 03:04:05 GMT"
+			}
+			if got := w.Header().Get("Last-Modified"); got != wantLastModified {
+				t.Fatalf("got Last-Modified %q, want %q", got, wantLastModified)
+			}
+			if got := w.Header().Get("Cache-Control"); got != revalidateCacheControl {
+				t.Fatalf("got Cache-Control %q, want %q", got, revalidateCacheControl)
+			}
+		})
+	}
+}
+
+func TestParseDbTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{value: "2024-01-02 03:04:05", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)},
+		{value: "2024-01-02 03:04:05.123456", want: time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.Local)},
+		{value: "2024-01-02 03:04:05.5", want: time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.Local)},
+		{value: "", want: time.Time{}},
+		{value: "2024-01-02", want: time.Time{}},
+		{value: "2024-01-02T03:04:05Z", want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseDbTime(tt.value); !got.Equal(tt.want) {
+				t.Fatalf("parseDbTime(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	t.Setenv("CACHE_CONTROL", "GET /teachers/=private, max-age=10; GET /students/{id} = no-store ;broken; =public")
+	cacheControlsOnce = sync.Once{}
+	t.Cleanup(func() { cacheControlsOnce = sync.Once{} })
+
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "GET /teachers/", want: "private, max-age=10"},
+		{pattern: "GET /students/{id}", want: "no-store"},
+		{pattern: "GET /classes/", want: revalidateCacheControl},
+		{pattern: "", want: revalidateCacheControl},
+		{pattern: "broken", want: revalidateCacheControl},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Pattern = tt.pattern
+			if got := cacheControl(r, revalidateCacheControl); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+// A guardian needs a name and at least one way to reach them
+func checkGuardianFields(guardian models.Guardian) error {
+	if guardian.Name == "" {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "name is required")
+	}
+	if guardian.Phone == "" && guardian.Email == "" {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "phone or email is required")
+	}
+	return nil
+}
+
+func GetGuardiansHandler(w http.ResponseWriter, r *http.Request) {
+	query := ""
+	var args []interface{}
+	for _, param := range []string{"name", "phone", "email"} {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + param + " = ?"
+			args = append(args, value)
+		}
+	}
+
+	guardians, err := sqlconnect.GetGuardiansFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string            `json:"status"`
+		Count  int               `json:"count"`
+		Data   []models.Guardian `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(guardians),
+		Data:   guardians,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneGuardianHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid guardian id", http.StatusBadRequest)
+		return
+	}
+
+	guardian, err := sqlconnect.GetOneGuardian(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(guardian)
+}
+
+func AddGuardiansHandler(w http.ResponseWriter, r *http.Request) {
+	var newGuardians []models.Guardian
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &newGuardians)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for _, guardian := range newGuardians {
+		err = checkGuardianFields(guardian)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedGuardians, err := sqlconnect.AddGuardians(newGuardians)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string            `json:"status"`
+		Count  int               `json:"count"`
+		Data   []models.Guardian `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedGuardians),
+		Data:   addedGuardians,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateGuardianHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid guardian id", http.StatusBadRequest)
+		return
+	}
+
+	var updateGuardian models.Guardian
+	err = json.NewDecoder(r.Body).Decode(&updateGuardian)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkGuardianFields(updateGuardian)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedGuardian, err := sqlconnect.UpdateGuardian(id, updateGuardian)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedGuardian)
+}
+
+func DeleteOneGuardianHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid guardian request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneGuardian(id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Guardian succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetGuardiansByStudentId(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	contacts, err := sqlconnect.GetGuardiansByStudentIdFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string                  `json:"status"`
+		Count  int                     `json:"count"`
+		Data   []models.StudentContact `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(contacts),
+		Data:   contacts,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Links a guardian to a student, or updates the link when it already exists.
+// Takes {"guardian_id": 3, "relationship": "mother", "is_primary": true}, the
+// guardian id can also come from the path.
+func SaveStudentGuardianHandler(w http.ResponseWriter, r *http.Request) {
+	studentId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	var link models.StudentGuardian
+	err = json.NewDecoder(r.Body).Decode(&link)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	guardianIdStr := r.PathValue("guardianId")
+	if guardianIdStr != "" {
+		link.GuardianID, err = strconv.Atoi(guardianIdStr)
+		if err != nil {
+			http.Error(w, "invalid guardian id", http.StatusBadRequest)
+			return
+		}
+	}
+	if link.GuardianID <= 0 {
+		http.Error(w, "guardian_id is required", http.StatusBadRequest)
+		return
+	}
+	link.StudentID = studentId
+
+	err = sqlconnect.SaveStudentGuardian(link)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(link)
+}
+
+func DeleteStudentGuardianHandler(w http.ResponseWriter, r *http.Request) {
+	studentId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+	guardianId, err := strconv.Atoi(r.PathValue("guardianId"))
+	if err != nil {
+		http.Error(w, "invalid guardian id", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteStudentGuardian(studentId, guardianId)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status     string `json:"status"`
+		StudentID  int    `json:"student_id"`
+		GuardianID int    `json:"guardian_id"`
+	}{
+		Status:     "Guardian succesfully removed from student",
+		StudentID:  studentId,
+		GuardianID: guardianId,
+	}
+	json.NewEncoder(w).Encode(response)
+}
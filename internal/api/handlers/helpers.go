@@ -1,25 +1,85 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
 	"reflect"
+	"restapi/internal/models"
+	"restapi/pkg/storage"
 	"restapi/pkg/utils"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Check if there exists a blank field. Returns and error if so.
 func checkBlankFields(model interface{}) error {
+	if blankField(model) != "" {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "all fields are required")
+	}
+	return nil
+}
+
+// The json name of the first required field of model that is blank, "" when
+// there is none
+func blankField(model interface{}) string {
 	val := reflect.ValueOf(model)
 	typ := val.Type()
 
 	for i := 0; i < typ.NumField(); i++ {
-		if typ.Field(i).Name == "id" {
+		// Fields without a db tag are not stored, so they are never required
+		if typ.Field(i).Name == "ID" || typ.Field(i).Tag.Get("db") == "" {
 			continue
 		}
 		fieldVal := val.Field(i)
+		name := strings.TrimSuffix(typ.Field(i).Tag.Get("json"), ",omitempty")
 		if fieldVal.Kind() == reflect.String && fieldVal.String() == "" {
-			return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "all fields are required")
+			return name
+		}
+		// Foreign keys such as class_id are ints and are required as well
+		if fieldVal.Kind() == reflect.Int && fieldVal.Int() == 0 {
+			return name
+		}
+	}
+	return ""
+}
+
+// Fields the server keeps itself. They are sent back but never taken from a
+// request body.
+var serverManagedFields = map[string]bool{"version": true, "deleted_at": true, "updated_at": true}
+
+// The field names a client may send for model
+func inputFieldNames(model interface{}) []string {
+	var fields []string
+	for _, field := range getFieldNames(model) {
+		if !serverManagedFields[field] {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Refuses a field of a JSON object body that is not one of model's input
+// fields, such as the server-managed version
+func checkInputFields(body []byte, model interface{}) error {
+	var raw map[string]interface{}
+	err := json.Unmarshal(body, &raw)
+	if err != nil {
+		return utils.InvalidHandler(err, "error decoding json")
+	}
+	allowedFields := make(map[string]struct{})
+	for _, field := range inputFieldNames(model) {
+		allowedFields[field] = struct{}{}
+	}
+	for key := range raw {
+		if _, ok := allowedFields[key]; !ok {
+			return utils.InvalidHandler(nil, fmt.Sprintf("unknown field %q", key))
 		}
 	}
 	return nil
@@ -39,28 +99,23 @@ func getFieldNames(model interface{}) []string {
 	return fields
 }
 
-// Add sorting to the query
-func sortBy(r *http.Request, query string) string {
-	sortParams := r.URL.Query()["sortby"]
-	if len(sortParams) > 0 {
-		query += " ORDER BY"
-		for i, param := range sortParams {
-			parts := strings.Split(param, ":")
-			fmt.Println(len(parts), parts)
-			if len(parts) != 2 {
-				continue
-			}
-			field, order := parts[0], parts[1]
-			fmt.Println(isValidSortField(field), isValidSortOrder(order))
-			if !isValidSortField(field) || !isValidSortOrder(order) {
-				continue
-			}
-			fmt.Println(field, order)
-			if i > 0 {
-				query += ","
-			}
-			query += " " + field + " " + order
+// Add sorting to the query. Fields that are not in validFields, the columns
+// of the resource that can be sorted by, are left out.
+func sortBy(r *http.Request, query string, validFields map[string]bool) string {
+	var clauses []string
+	for _, param := range r.URL.Query()["sortby"] {
+		parts := strings.Split(param, ":")
+		if len(parts) != 2 {
+			continue
+		}
+		field, order := parts[0], parts[1]
+		if !validFields[field] || !isValidSortOrder(order) {
+			continue
 		}
+		clauses = append(clauses, field+" "+order)
+	}
+	if len(clauses) > 0 {
+		query += " ORDER BY " + strings.Join(clauses, ", ")
 	}
 	return query
 }
@@ -69,3 +124,166 @@ func sortBy(r *http.Request, query string) string {
 func isValidSortOrder(order string) bool {
 	return order == "asc" || order == "desc"
 }
+
+// Deleted teachers and students are left out of reads unless asked for with
+// ?include_deleted=true
+func includeDeleted(r *http.Request) bool {
+	return r.URL.Query().Get("include_deleted") == "true"
+}
+
+// Page sizes for paged lists
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Reads page (from 1) and limit from the query
+func pagination(r *http.Request) (int, int, error) {
+	page, limit := 1, defaultPageLimit
+	var err error
+	if value := r.URL.Query().Get("page"); value != "" {
+		page, err = strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("page must be a number from 1")
+		}
+	}
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be a number from 1 to %d", maxPageLimit)
+		}
+	}
+	return page, limit, nil
+}
+
+// Who is behind the request, for the audit log. There are no logins yet, so
+// the actor is whatever the client sends as X-Actor.
+func auditInfo(r *http.Request) models.AuditInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	actor := r.Header.Get("X-Actor")
+	if len(actor) > maxActorLength {
+		actor = actor[:maxActorLength]
+	}
+	return models.AuditInfo{
+		Actor:     actor,
+		RequestID: r.Header.Get("X-Request-ID"),
+		IP:        ip,
+	}
+}
+
+// Size of audit_log.actor
+const maxActorLength = 100
+
+// Reads ?as_of=, an RFC 3339 time or a date, for reading a row as it was
+// then. Reports false when it is not given.
+func parseAsOf(r *http.Request) (time.Time, bool, error) {
+	value := r.URL.Query().Get("as_of")
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	asOf, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		asOf, err = time.ParseInLocation(time.DateOnly, value, time.Local)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("as_of must be formatted as RFC 3339 or YYYY-MM-DD")
+	}
+	return asOf, true, nil
+}
+
+// Pick the status code for an error coming back from sqlconnect
+func errorStatus(err error) int {
+	if errors.Is(err, utils.ErrConflict) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, utils.ErrInvalid) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, utils.ErrPreconditionFailed) {
+		return http.StatusPreconditionFailed
+	}
+	if errors.Is(err, utils.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+var (
+	blobs     storage.BlobStore
+	blobsErr  error
+	blobsOnce sync.Once
+)
+
+// The blob store uploads are kept in, set up from the environment on first use
+func blobStore() (storage.BlobStore, error) {
+	blobsOnce.Do(func() {
+		blobs, blobsErr = storage.FromEnv()
+		if blobsErr != nil {
+			blobsErr = utils.ErrorHandler(blobsErr, "error opening blob store")
+		}
+	})
+	return blobs, blobsErr
+}
+
+// Reads the body of a PATCH on a single row. It is a JSON Merge Patch, also
+// when sent as plain application/json, or a JSON Patch. Writes the error and
+// reports false when it cannot be read.
+func readPatch(w http.ResponseWriter, r *http.Request) (utils.Patch, bool) {
+	mediaType := "application/json"
+	if value := r.Header.Get("Content-Type"); value != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(value)
+		if err != nil {
+			http.Error(w, "invalid Content-Type", http.StatusBadRequest)
+			return utils.Patch{}, false
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		return utils.Patch{}, false
+	}
+
+	var patch utils.Patch
+	switch mediaType {
+	case utils.MergePatchType, "application/json":
+		patch, err = utils.MergePatch(body)
+	case utils.JSONPatchType:
+		patch, err = utils.JSONPatch(body)
+	default:
+		w.Header().Set("Accept-Patch", utils.MergePatchType+", "+utils.JSONPatchType)
+		http.Error(w, "PATCH takes "+utils.MergePatchType+" or "+utils.JSONPatchType, http.StatusUnsupportedMediaType)
+		return utils.Patch{}, false
+	}
+	if writeFieldErrors(w, err) {
+		return utils.Patch{}, false
+	} else if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return utils.Patch{}, false
+	}
+	return patch, true
+}
+
+// Answers 400 with every field that is wrong when err is a
+// utils.FieldErrors. Reports false when it is not.
+func writeFieldErrors(w http.ResponseWriter, err error) bool {
+	var fieldErrs utils.FieldErrors
+	if !errors.As(err, &fieldErrs) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	response := struct {
+		Status string             `json:"status"`
+		Errors []utils.FieldError `json:"errors"`
+	}{
+		Status: "invalid",
+		Errors: fieldErrs,
+	}
+	json.NewEncoder(w).Encode(response)
+	return true
+}
@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/storage"
+	"restapi/pkg/utils"
+)
+
+// POST /teachers/import and /students/import take a text/csv file whose
+// header row names the columns by their json field. Every stored field but
+// the id is a column. The file is read row by row and saved in batches of
+// importBatchSize, each row on its own like the items of a bulk POST, so a
+// row that fails is left out and the rest is imported. Rejected rows and the
+// reason are listed in a CSV linked from error_report_url, the first
+// maxReportRows of them.
+//
+// With ?dry_run=true nothing is saved. Rows are checked the same way, rows
+// whose email is used already are rejected, and imported counts the rows
+// that would be.
+//
+// An error that stops the import after batches were saved is answered with
+// its status and the summary of what was saved so far.
+const (
+	importBatchSize = 200
+	maxImportSize   = 10 << 20
+	maxReportRows   = 1000
+)
+
+type importSummary struct {
+	Status          string `json:"status"`
+	DryRun          bool   `json:"dry_run"`
+	Rows            int    `json:"rows"`
+	Imported        int    `json:"imported"`
+	Waitlisted      int    `json:"waitlisted,omitempty"`
+	Rejected        int    `json:"rejected"`
+	ErrorReportURL  string `json:"error_report_url,omitempty"`
+	ReportTruncated bool   `json:"report_truncated,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// A row that passed its checks, waiting for the rest of its batch
+type importRow struct {
+	line   int
+	record []string
+	model  interface{}
+	email  string
+}
+
+// Error reports are kept as long as finished jobs, see jobRetention
+var (
+	importReports   = make(map[string]time.Time)
+	importReportsMu sync.Mutex
+	validReportId   = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+// What a dry run looks up in the database, variables so tests can answer
+// for it
+var (
+	takenEmails = sqlconnect.TakenEmails
+	freeSeats   = sqlconnect.FreeSeats
+)
+
+func ImportTeachersHandler(w http.ResponseWriter, r *http.Request) {
+	importCSV(w, r, "teachers", models.Teacher{}, func(rows []interface{}) ([]models.BulkItemResult, error) {
+		teachers := make([]models.Teacher, len(rows))
+		for i, row := range rows {
+			teachers[i] = row.(models.Teacher)
+		}
+		return sqlconnect.AddTeacher(w, teachers, false, auditInfo(r))
+	}, nil)
+}
+
+// Students whose class is full are imported onto its waitlist. A dry run
+// checks their class is there and counts the seats the file takes.
+func ImportStudentsHandler(w http.ResponseWriter, r *http.Request) {
+	// Free seats of the classes seen so far, see sqlconnect.FreeSeats
+	seats := make(map[int]int)
+	importCSV(w, r, "students", models.Student{}, func(rows []interface{}) ([]models.BulkItemResult, error) {
+		students := make([]models.Student, len(rows))
+		for i, row := range rows {
+			students[i] = row.(models.Student)
+		}
+		return sqlconnect.AddStudent(w, students, false, auditInfo(r))
+	}, func(rows []interface{}) ([]models.BulkItemResult, error) {
+		var classIds []int
+		for _, row := range rows {
+			classId := row.(models.Student).ClassID
+			if _, ok := seats[classId]; !ok {
+				classIds = append(classIds, classId)
+				seats[classId] = 0
+			}
+		}
+		found, err := freeSeats(classIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, classId := range classIds {
+			if free, ok := found[classId]; ok {
+				seats[classId] = free
+			} else {
+				delete(seats, classId)
+			}
+		}
+
+		results := make([]models.BulkItemResult, len(rows))
+		for i, row := range rows {
+			classId := row.(models.Student).ClassID
+			free, ok := seats[classId]
+			switch {
+			case !ok:
+				results[i].Err = utils.InvalidHandler(nil, "class not found")
+			case free == 0:
+				results[i].Waitlist = &models.WaitlistEntry{ClassID: classId}
+			case free > 0:
+				seats[classId]--
+			}
+		}
+		return results, nil
+	})
+}
+
+// Reads the file into models shaped like model and hands them to save a
+// batch at a time. A dry run hands them to preview instead, when it is set,
+// which answers like save without saving anything.
+func importCSV(w http.ResponseWriter, r *http.Request, table string, model interface{},
+	save func(rows []interface{}) ([]models.BulkItemResult, error), preview func(rows []interface{}) ([]models.BulkItemResult, error)) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/csv" {
+		http.Error(w, "import takes text/csv", http.StatusUnsupportedMediaType)
+		return
+	}
+	entity := strings.TrimSuffix(table, "s")
+	typ := reflect.TypeOf(model)
+
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, maxImportSize))
+	// Rows with the wrong number of fields are rejected one by one below
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		http.Error(w, "the file is empty", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "error reading header row: "+err.Error(), importReadStatus(err))
+		return
+	}
+	// Spreadsheets often save a byte order mark before the first column
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	columns, err := importColumns(typ, header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summary := importSummary{DryRun: r.URL.Query().Get("dry_run") == "true"}
+	var report bytes.Buffer
+	reportWriter := csv.NewWriter(&report)
+	reportWriter.Write(append(append([]string{"line"}, header...), "error"))
+	reject := func(line int, record []string, reason string) {
+		summary.Rejected++
+		if summary.Rejected > maxReportRows {
+			summary.ReportTruncated = true
+			return
+		}
+		reportWriter.Write(append(append([]string{strconv.Itoa(line)}, record...), reason))
+	}
+
+	// The line each email was first seen on, so a file cannot add the same
+	// person twice
+	emails := make(map[string]int)
+	var batch []importRow
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() { batch = batch[:0] }()
+
+		if summary.DryRun {
+			batchEmails := make([]string, len(batch))
+			for i, row := range batch {
+				batchEmails[i] = row.email
+			}
+			taken, err := takenEmails(table, batchEmails)
+			if err != nil {
+				return err
+			}
+			kept := batch[:0]
+			for _, row := range batch {
+				if taken[row.email] {
+					reject(row.line, row.record, "email is already used by another "+entity+": "+row.email)
+				} else {
+					kept = append(kept, row)
+				}
+			}
+			batch = kept
+			if preview == nil {
+				summary.Imported += len(batch)
+				return nil
+			}
+		}
+
+		rows := make([]interface{}, len(batch))
+		for i, row := range batch {
+			rows[i] = row.model
+		}
+		var results []models.BulkItemResult
+		var err error
+		if summary.DryRun {
+			results, err = preview(rows)
+		} else {
+			results, err = save(rows)
+		}
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if result.Err != nil {
+				reject(batch[i].line, batch[i].record, result.Err.Error())
+				continue
+			}
+			summary.Imported++
+			if result.Waitlist != nil {
+				summary.Waitlisted++
+			}
+		}
+		return nil
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && parseErr.Err != nil && !isMaxBytes(parseErr.Err) {
+			summary.Rows++
+			reject(parseErr.StartLine, record, parseErr.Err.Error())
+			continue
+		} else if err != nil {
+			finishImport(w, &summary, reportWriter, &report, importReadStatus(err), errors.New("error reading request body: "+err.Error()))
+			return
+		}
+		summary.Rows++
+		line, _ := reader.FieldPos(0)
+
+		if len(record) != len(header) {
+			reject(line, record, fmt.Sprintf("row has %d fields, the header has %d", len(record), len(header)))
+			continue
+		}
+		rowModel, reason := importModel(typ, columns, record)
+		if reason != "" {
+			reject(line, record, reason)
+			continue
+		}
+		email := strings.ToLower(reflect.ValueOf(rowModel).FieldByName("Email").String())
+		if first, ok := emails[email]; ok {
+			reject(line, record, fmt.Sprintf("email is already on line %d", first))
+			continue
+		}
+		emails[email] = line
+
+		batch = append(batch, importRow{line: line, record: record, model: rowModel, email: email})
+		if len(batch) == importBatchSize {
+			err = flush()
+			if err != nil {
+				finishImport(w, &summary, reportWriter, &report, errorStatus(err), err)
+				return
+			}
+		}
+	}
+	err = flush()
+	if err != nil {
+		finishImport(w, &summary, reportWriter, &report, errorStatus(err), err)
+		return
+	}
+	finishImport(w, &summary, reportWriter, &report, http.StatusOK, nil)
+}
+
+// Answers with the summary. An import stopped by err before anything was
+// saved gets a plain error, one that saved rows already gets the summary of
+// those with the error and status.
+func finishImport(w http.ResponseWriter, summary *importSummary, reportWriter *csv.Writer, report *bytes.Buffer, status int, err error) {
+	if err != nil && (summary.DryRun || summary.Imported == 0) {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	switch {
+	case err != nil:
+		summary.Status = "aborted"
+		summary.Error = err.Error()
+	case summary.Rejected == 0:
+		summary.Status = "success"
+	case summary.Imported == 0:
+		summary.Status = "failed"
+	default:
+		summary.Status = "partial"
+	}
+	if summary.Rejected > 0 {
+		reportWriter.Flush()
+		var reportErr error
+		summary.ErrorReportURL, reportErr = storeImportReport(report)
+		if reportErr != nil && err == nil {
+			http.Error(w, reportErr.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(summary)
+}
+
+// A file over maxImportSize is a 413, any other read error a 400
+func importReadStatus(err error) int {
+	if isMaxBytes(err) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func isMaxBytes(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// The index of the model field behind every column of the header. All the
+// stored fields but the id have to be there, nothing else may be.
+func importColumns(typ reflect.Type, header []string) ([]int, error) {
+	fields := make(map[string]int)
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Name == "ID" || field.Tag.Get("db") == "" {
+			continue
+		}
+		name := strings.TrimSuffix(field.Tag.Get("json"), ",omitempty")
+		fields[name] = i
+		names = append(names, name)
+	}
+
+	columns := make([]int, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		index, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column %q appears twice", name)
+		}
+		seen[name] = true
+		columns[i] = index
+	}
+	for _, name := range names {
+		if !seen[name] {
+			return nil, fmt.Errorf("column %q is missing", name)
+		}
+	}
+	return columns, nil
+}
+
+// Builds a model from one row, or says why the row is rejected
+func importModel(typ reflect.Type, columns []int, record []string) (interface{}, string) {
+	model := reflect.New(typ).Elem()
+	for i, index := range columns {
+		field := model.Field(index)
+		value := strings.TrimSpace(record[i])
+		name := strings.TrimSuffix(typ.Field(index).Tag.Get("json"), ",omitempty")
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int:
+			if value == "" {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, name + " must be an integer"
+			}
+			field.SetInt(int64(n))
+		}
+	}
+	if name := blankField(model.Interface()); name != "" {
+		return nil, name + " is required"
+	}
+	return model.Interface(), ""
+}
+
+// Stores an error report and returns the link it is downloaded from
+func storeImportReport(report io.Reader) (string, error) {
+	store, err := blobStore()
+	if err != nil {
+		return "", err
+	}
+
+	importReportsMu.Lock()
+	for id, created := range importReports {
+		if time.Since(created) > jobRetention {
+			store.Delete(importReportKey(id))
+			delete(importReports, id)
+		}
+	}
+	importReportsMu.Unlock()
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	err = store.Put(importReportKey(id), report, "text/csv")
+	if err != nil {
+		return "", utils.ErrorHandler(err, "error storing error report")
+	}
+
+	importReportsMu.Lock()
+	importReports[id] = time.Now()
+	importReportsMu.Unlock()
+	return utils.SignURL("/imports/"+id+"/errors", downloadURLTTL), nil
+}
+
+func importReportKey(id string) string {
+	return "imports/" + id + "-errors.csv"
+}
+
+// Serves an import's error report through the signed url in error_report_url
+func GetImportErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	err := utils.VerifySignedURL(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	id := r.PathValue("id")
+	if !validReportId.MatchString(id) {
+		http.Error(w, "error report not found", http.StatusNotFound)
+		return
+	}
+	store, err := blobStore()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	file, err := store.Open(importReportKey(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "error report not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		utils.ErrorHandler(err, "error opening error report")
+		http.Error(w, "error opening error report", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "import-errors-"+id+".csv"))
+	w.Header().Set("Cache-Control", "private, no-store")
+	_, err = io.Copy(w, file)
+	if err != nil {
+		utils.ErrorHandler(err, "error sending error report")
+	}
+}
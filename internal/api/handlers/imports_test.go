@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"restapi/internal/models"
+)
+
+func TestImportColumns(t *testing.T) {
+	typ := reflect.TypeOf(models.Student{})
+	tests := []struct {
+		name    string
+		header  []string
+		want    []int
+		wantErr string
+	}{
+		{name: "in order", header: []string{"first_name", "last_name", "email", "class_id"}, want: []int{1, 2, 3, 4}},
+		{name: "any order", header: []string{"class_id", "email", " last_name ", "first_name"}, want: []int{4, 3, 2, 1}},
+		{name: "id is not a column", header: []string{"id", "first_name", "last_name", "email", "class_id"}, wantErr: `unknown column "id"`},
+		{name: "field without db tag", header: []string{"first_name", "last_name", "email", "class_id", "version"}, wantErr: `unknown column "version"`},
+		{name: "twice", header: []string{"first_name", "last_name", "email", "class_id", "email"}, wantErr: `column "email" appears twice`},
+		{name: "missing", header: []string{"first_name", "last_name", "email"}, wantErr: `column "class_id" is missing`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importColumns(typ, tt.header)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("importColumns() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("importColumns() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("importColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportModel(t *testing.T) {
+	typ := reflect.TypeOf(models.Student{})
+	columns := []int{1, 2, 3, 4}
+	tests := []struct {
+		name       string
+		record     []string
+		want       interface{}
+		wantReason string
+	}{
+		{name: "valid", record: []string{"Ada", "Lovelace", "ada@example.com", "3"},
+			want: models.Student{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", ClassID: 3}},
+		{name: "trims values", record: []string{" Ada ", "Lovelace", "ada@example.com ", " 3"},
+			want: models.Student{FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", ClassID: 3}},
+		{name: "not an integer", record: []string{"Ada", "Lovelace", "ada@example.com", "three"}, wantReason: "class_id must be an integer"},
+		{name: "blank string", record: []string{"Ada", " ", "ada@example.com", "3"}, wantReason: "last_name is required"},
+		{name: "blank integer", record: []string{"Ada", "Lovelace", "ada@example.com", ""}, wantReason: "class_id is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := importModel(typ, columns, tt.record)
+			if reason != tt.wantReason {
+				t.Fatalf("importModel() reason = %q, want %q", reason, tt.wantReason)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("importModel() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportRefusesFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{name: "not csv", contentType: "application/json", body: `[]`, wantStatus: http.StatusUnsupportedMediaType, wantBody: "import takes text/csv"},
+		{name: "empty", contentType: "text/csv", body: "", wantStatus: http.StatusBadRequest, wantBody: "the file is empty"},
+		{name: "unknown column", contentType: "text/csv", body: "first_name,last_name,email,subject,age\n", wantStatus: http.StatusBadRequest, wantBody: `unknown column "age"`},
+		{name: "missing column", contentType: "text/csv; charset=utf-8", body: "\ufefffirst_name,last_name,email\n", wantStatus: http.StatusBadRequest, wantBody: `column "subject" is missing`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/teachers/import", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			ImportTeachersHandler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+				t.Fatalf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+// A dry run checks every row as the import would, without saving any
+func TestImportStudentsDryRun(t *testing.T) {
+	t.Setenv("BLOB_DIR", t.TempDir())
+	blobsOnce = sync.Once{}
+	t.Cleanup(func() { blobsOnce = sync.Once{} })
+
+	oldTakenEmails, oldFreeSeats := takenEmails, freeSeats
+	t.Cleanup(func() {
+		takenEmails = oldTakenEmails
+		freeSeats = oldFreeSeats
+	})
+	takenEmails = func(table string, emails []string) (map[string]bool, error) {
+		if table != "students" {
+			t.Errorf("takenEmails() table = %q, want students", table)
+		}
+		return map[string]bool{"taken@example.com": true}, nil
+	}
+	// Class 1 has one seat left, class 3 has no limit, class 9 is not there
+	freeSeats = func(classIds []int) (map[int]int, error) {
+		return map[int]int{1: 1, 2: 5, 3: -1}, nil
+	}
+
+	body := strings.Join([]string{
+		"first_name,last_name,email,class_id",
+		"Ada,Lovelace,ada@example.com,1",
+		"Alan,Turing,alan@example.com,1",
+		"Grace,Hopper,ADA@example.com,2",
+		"Edsger,Dijkstra,taken@example.com,2",
+		"Barbara,Liskov,barbara@example.com,x",
+		"Ken,Thompson,ken@example.com",
+		"Dennis,Ritchie,dennis@example.com,9",
+		"Linus,Torvalds,linus@example.com,3",
+		",Knuth,don@example.com,3",
+	}, "\n") + "\n"
+	r := httptest.NewRequest(http.MethodPost, "/students/import?dry_run=true", strings.NewReader(body))
+	r.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	ImportStudentsHandler(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var summary importSummary
+	err := json.NewDecoder(w.Body).Decode(&summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportURL := summary.ErrorReportURL
+	summary.ErrorReportURL = ""
+	want := importSummary{Status: "partial", DryRun: true, Rows: 9, Imported: 3, Waitlisted: 1, Rejected: 6}
+	if summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+
+	u, err := url.Parse(reportURL)
+	if err != nil || !strings.HasPrefix(u.Path, "/imports/") {
+		t.Fatalf("error_report_url = %q", reportURL)
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(u.Path, "/imports/"), "/errors")
+	store, err := blobStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := store.Open(importReportKey(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer report.Close()
+	reader := csv.NewReader(report)
+	// The row that was short is reported as it was
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantErrors := map[string]string{
+		"4":  "email is already on line 2",
+		"5":  "email is already used by another student: taken@example.com",
+		"6":  "class_id must be an integer",
+		"7":  "row has 3 fields, the header has 4",
+		"8":  "class not found",
+		"10": "first_name is required",
+	}
+	if len(records) != len(wantErrors)+1 {
+		t.Fatalf("report has %d rows, want %d: %v", len(records), len(wantErrors)+1, records)
+	}
+	for _, record := range records[1:] {
+		line, reason := record[0], record[len(record)-1]
+		if wantErrors[line] != reason {
+			t.Errorf("line %s rejected with %q, want %q", line, reason, wantErrors[line])
+		}
+	}
+}
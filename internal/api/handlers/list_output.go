@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"restapi/pkg/utils"
+)
+
+// Media types GET /teachers/ and /students/ answer with besides JSON, picked
+// from Accept. NDJSON is one JSON object per row and line, sent as the rows
+// are read. CSV and XLSX are downloads of the same rows, with a column per
+// field.
+const (
+	ndjsonType = "application/x-ndjson"
+	csvType    = "text/csv"
+	xlsxType   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// A streamed list is flushed after this many rows or this long, whichever
+// comes first, so the client gets rows while the rest is still being read
+const (
+	listFlushRows     = 100
+	listFlushInterval = time.Second
+)
+
+// How a list is written: the media type it is sent as and the json names of
+// the fields picked with ?fields=first_name,email, all of them by default
+type listOutput struct {
+	format string
+	fields []string
+}
+
+// Reads Accept and ?fields= for a list of model. Adds Vary: Accept, as the
+// same url answers with different formats.
+func parseListOutput(w http.ResponseWriter, r *http.Request, model interface{}) (listOutput, error) {
+	w.Header().Add("Vary", "Accept")
+	output := listOutput{
+		format: negotiate(r.Header.Get("Accept"), "application/json", ndjsonType, csvType, xlsxType),
+		fields: getFieldNames(model),
+	}
+	if output.format == "" {
+		output.format = "application/json"
+	}
+
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		return output, nil
+	}
+	known := make(map[string]bool)
+	for _, field := range output.fields {
+		known[field] = true
+	}
+	output.fields = nil
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if !known[field] {
+			return listOutput{}, utils.InvalidHandler(nil, fmt.Sprintf("unknown field %q", field))
+		}
+		output.fields = append(output.fields, field)
+	}
+	return output, nil
+}
+
+// The first of offers the client takes most, "" when it takes none of them.
+// Wildcards such as text/* match the first offer they cover.
+func negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type acceptRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType, q})
+	}
+	// The most specific range decides, */* loses to text/* loses to text/csv
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return strings.Count(ranges[i].mediaType, "*") < strings.Count(ranges[j].mediaType, "*")
+	})
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		for _, ar := range ranges {
+			major, _, _ := strings.Cut(offer, "/")
+			if ar.mediaType == offer || ar.mediaType == major+"/*" || ar.mediaType == "*/*" {
+				if ar.q > bestQ {
+					best, bestQ = offer, ar.q
+				}
+				break
+			}
+		}
+	}
+	return best
+}
+
+// Writes the rows of a list as they are read from the database
+type listWriter interface {
+	Write(row interface{}) error
+	Close() error
+	// Ends a list that cannot be finished. A list whose rows are already on
+	// their way is cut off, so the client does not take it for complete.
+	Abort(msg string)
+}
+
+// Sends the headers and returns the writer for the rows. name is what the
+// list is called in a download's file name. Only a download can fail here,
+// once it has started.
+func (o listOutput) start(w http.ResponseWriter, name string) (listWriter, error) {
+	if o.format == "application/json" {
+		w.Header().Set("Content-Type", "application/json")
+		return &jsonList{w: w, fields: o.fields, rows: make([]interface{}, 0)}, nil
+	}
+	if o.format == ndjsonType {
+		w.Header().Set("Content-Type", ndjsonType)
+		return &ndjsonList{w: w, encoder: json.NewEncoder(w), fields: o.fields, flushed: time.Now()}, nil
+	}
+
+	extension := ".csv"
+	if o.format == xlsxType {
+		extension = ".xlsx"
+	}
+	fileName := name + "-" + time.Now().Format(time.DateOnly) + extension
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+
+	if o.format == csvType {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		list := &csvList{csv: csv.NewWriter(w), fields: o.fields}
+		return list, list.csv.Write(o.fields)
+	}
+	w.Header().Set("Content-Type", xlsxType)
+	xlsx, err := utils.NewXLSXWriter(w, name)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]interface{}, len(o.fields))
+	for i, field := range o.fields {
+		header[i] = field
+	}
+	return &xlsxList{xlsx: xlsx, fields: o.fields}, xlsx.WriteRow(header)
+}
+
+// The JSON list is written as a whole once every row is read, with the
+// fields that were not picked left out
+type jsonList struct {
+	w      http.ResponseWriter
+	fields []string
+	rows   []interface{}
+}
+
+func (l *jsonList) Write(row interface{}) error {
+	l.rows = append(l.rows, projectRow(row, l.fields))
+	return nil
+}
+
+func (l *jsonList) Close() error {
+	response := struct {
+		Status string        `json:"status"`
+		Count  int           `json:"count"`
+		Data   []interface{} `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(l.rows),
+		Data:   l.rows,
+	}
+	return json.NewEncoder(l.w).Encode(response)
+}
+
+func (l *jsonList) Abort(msg string) {
+	http.Error(l.w, msg, http.StatusInternalServerError)
+}
+
+type ndjsonList struct {
+	w       http.ResponseWriter
+	encoder *json.Encoder
+	fields  []string
+	pending int
+	flushed time.Time
+}
+
+func (l *ndjsonList) Write(row interface{}) error {
+	err := l.encoder.Encode(projectRow(row, l.fields))
+	if err != nil {
+		return err
+	}
+	l.pending++
+	if l.pending >= listFlushRows || time.Since(l.flushed) >= listFlushInterval {
+		return l.flush()
+	}
+	return nil
+}
+
+func (l *ndjsonList) flush() error {
+	l.pending = 0
+	l.flushed = time.Now()
+	err := http.NewResponseController(l.w).Flush()
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
+
+func (l *ndjsonList) Close() error {
+	return l.flush()
+}
+
+func (l *ndjsonList) Abort(msg string) {
+	abortList(msg)
+}
+
+type csvList struct {
+	csv    *csv.Writer
+	fields []string
+}
+
+func (l *csvList) Write(row interface{}) error {
+	values := fieldValues(row, l.fields)
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = fmt.Sprint(value)
+	}
+	return l.csv.Write(record)
+}
+
+func (l *csvList) Close() error {
+	l.csv.Flush()
+	return l.csv.Error()
+}
+
+func (l *csvList) Abort(msg string) {
+	abortList(msg)
+}
+
+type xlsxList struct {
+	xlsx   *utils.XLSXWriter
+	fields []string
+}
+
+func (l *xlsxList) Write(row interface{}) error {
+	return l.xlsx.WriteRow(fieldValues(row, l.fields))
+}
+
+func (l *xlsxList) Close() error {
+	return l.xlsx.Close()
+}
+
+func (l *xlsxList) Abort(msg string) {
+	abortList(msg)
+}
+
+// Cuts a response off that has a 200 on its way already
+func abortList(msg string) {
+	utils.ErrorHandler(nil, msg)
+	panic(http.ErrAbortHandler)
+}
+
+// A copy of row, a model, with only the given json fields set. The others
+// are left out of its JSON as they are all omitempty.
+func projectRow(row interface{}, fields []string) interface{} {
+	val := reflect.ValueOf(row)
+	projected := reflect.New(val.Type()).Elem()
+	for _, field := range fields {
+		index, _ := fieldIndex(val.Type(), field)
+		projected.Field(index).Set(val.Field(index))
+	}
+	return projected.Interface()
+}
+
+// The values of the given json fields of row, a model. A nil pointer such as
+// the deleted_at of a row that is not deleted is an empty value.
+func fieldValues(row interface{}, fields []string) []interface{} {
+	val := reflect.ValueOf(row)
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		index, _ := fieldIndex(val.Type(), field)
+		fieldVal := val.Field(index)
+		if fieldVal.Kind() == reflect.Pointer {
+			if fieldVal.IsNil() {
+				values[i] = ""
+				continue
+			}
+			fieldVal = fieldVal.Elem()
+		}
+		values[i] = fieldVal.Interface()
+	}
+	return values
+}
+
+func fieldIndex(typ reflect.Type, name string) (int, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		if strings.TrimSuffix(typ.Field(i).Tag.Get("json"), ",omitempty") == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
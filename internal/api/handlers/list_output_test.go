@@ -0,0 +1,33 @@
+package handlers
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", ndjsonType, csvType, xlsxType}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "", want: "application/json"},
+		{accept: "*/*", want: "application/json"},
+		{accept: "text/csv", want: csvType},
+		{accept: "text/csv; charset=utf-8", want: csvType},
+		{accept: "text/*", want: csvType},
+		{accept: "application/x-ndjson, application/json;q=0.5", want: ndjsonType},
+		{accept: "application/json;q=0.5, text/csv", want: csvType},
+		{accept: "text/csv;q=0.1, */*;q=0.5", want: "application/json"},
+		{accept: "*/*;q=0.5, text/csv;q=0.1", want: "application/json"},
+		{accept: "text/csv;q=0, application/json;q=0.1", want: "application/json"},
+		{accept: "image/png", want: ""},
+		{accept: "text/csv;q=0", want: ""},
+		{accept: "text/csv;q=high", want: ""},
+		{accept: "not a type, " + xlsxType, want: xlsxType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got := negotiate(tt.accept, offers...); got != tt.want {
+				t.Fatalf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
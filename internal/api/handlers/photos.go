@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strconv"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/storage"
+	"restapi/pkg/utils"
+)
+
+// Limits for profile photos. Dimensions are read from the header before the
+// image is decoded, so an oversized image is rejected without decoding it.
+const (
+	maxPhotoSize      = 5 << 20
+	minPhotoDimension = 64
+	maxPhotoDimension = 4096
+	thumbnailSize     = 128
+)
+
+// Photos change rarely and their urls stay the same, so browsers keep them
+// for a day and revalidate with the ETag after that. CACHE_CONTROL can
+// change this per route.
+const photoCacheControl = "private, max-age=86400"
+
+func PutStudentPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	savePhoto(w, r, "student")
+}
+
+func PutTeacherPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	savePhoto(w, r, "teacher")
+}
+
+func GetStudentPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	writePhoto(w, r, "student", false)
+}
+
+func GetStudentThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	writePhoto(w, r, "student", true)
+}
+
+func GetTeacherPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	writePhoto(w, r, "teacher", false)
+}
+
+func GetTeacherThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	writePhoto(w, r, "teacher", true)
+}
+
+// Takes the raw JPEG or PNG as the request body and stores it together with
+// a JPEG thumbnail. Shared by students and teachers, owner is "student" or
+// "teacher".
+func savePhoto(w http.ResponseWriter, r *http.Request, owner string) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid "+owner+" id", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPhotoSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("photo is larger than %d MB", maxPhotoSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := http.DetectContentType(body)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		http.Error(w, "photo must be a JPEG or PNG image", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "photo is not a valid image", http.StatusBadRequest)
+		return
+	}
+	if config.Width < minPhotoDimension || config.Height < minPhotoDimension ||
+		config.Width > maxPhotoDimension || config.Height > maxPhotoDimension {
+		http.Error(w, fmt.Sprintf("photo must be between %dx%d and %dx%d pixels", minPhotoDimension, minPhotoDimension, maxPhotoDimension, maxPhotoDimension), http.StatusBadRequest)
+		return
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "photo is not a valid image", http.StatusBadRequest)
+		return
+	}
+
+	// JPEG has no transparency, transparent PNGs are put on white
+	thumb := utils.Thumbnail(img, thumbnailSize)
+	flat := image.NewRGBA(thumb.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), thumb, thumb.Bounds().Min, draw.Over)
+	var thumbBuf bytes.Buffer
+	err = jpeg.Encode(&thumbBuf, flat, &jpeg.Options{Quality: 85})
+	if err != nil {
+		utils.ErrorHandler(err, "error encoding thumbnail")
+		http.Error(w, "error creating thumbnail", http.StatusInternalServerError)
+		return
+	}
+
+	store, err := blobStore()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	base := fmt.Sprintf("photos/%ss/%d/%s", owner, id, hex.EncodeToString(suffix))
+	photo := models.Photo{
+		OwnerID:      id,
+		Key:          base,
+		ThumbnailKey: base + "-thumb",
+		ContentType:  contentType,
+		Width:        config.Width,
+		Height:       config.Height,
+	}
+
+	err = store.Put(photo.Key, bytes.NewReader(body), contentType)
+	if err == nil {
+		err = store.Put(photo.ThumbnailKey, &thumbBuf, "image/jpeg")
+	}
+	if err != nil {
+		store.Delete(photo.Key)
+		utils.ErrorHandler(err, "error storing photo")
+		http.Error(w, "error storing photo", http.StatusInternalServerError)
+		return
+	}
+
+	saved, old, err := sqlconnect.SavePhoto(owner, photo)
+	if err != nil {
+		store.Delete(photo.Key)
+		store.Delete(photo.ThumbnailKey)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, key := range []string{old.Key, old.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		err = store.Delete(key)
+		if err != nil {
+			utils.ErrorHandler(err, "error deleting replaced photo "+key)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string       `json:"status"`
+		Data   models.Photo `json:"data"`
+	}{
+		Status: "success",
+		Data:   saved,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Serves the photo or its thumbnail. Answers 304 when the client already has
+// the current version.
+func writePhoto(w http.ResponseWriter, r *http.Request, owner string, thumbnail bool) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid "+owner+" id", http.StatusBadRequest)
+		return
+	}
+
+	photo, err := sqlconnect.GetPhotoFromDb(owner, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	key, contentType := photo.Key, photo.ContentType
+	if thumbnail {
+		key, contentType = photo.ThumbnailKey, "image/jpeg"
+	}
+
+	if writeNotModified(w, r, strconv.Quote(key), parseDbTime(photo.UpdatedAt), photoCacheControl) {
+		return
+	}
+
+	store, err := blobStore()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	file, err := store.Open(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "photo not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		utils.ErrorHandler(err, "error opening photo")
+		http.Error(w, "error opening photo", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	_, err = io.Copy(w, file)
+	if err != nil {
+		utils.ErrorHandler(err, "error sending photo")
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+// A teacher's or student's ETag is their row version, e.g. "3". PUT, PATCH
+// and DELETE on one of them send it back in If-Match and are answered with
+// 412 and the current row when it has changed since.
+//
+// IF_MATCH sets how strict this is: "required" (the default) answers 428
+// when If-Match is missing, "optional" only checks it when it is sent.
+const (
+	ifMatchRequired = "required"
+	ifMatchOptional = "optional"
+)
+
+var (
+	ifMatchMode     string
+	ifMatchModeOnce sync.Once
+)
+
+func ifMatchIsRequired() bool {
+	ifMatchModeOnce.Do(func() {
+		ifMatchMode = ifMatchRequired
+		switch value := os.Getenv("IF_MATCH"); value {
+		case "", ifMatchRequired:
+		case ifMatchOptional:
+			ifMatchMode = ifMatchOptional
+		default:
+			utils.ErrorHandler(fmt.Errorf("invalid IF_MATCH %q", value), "requiring If-Match")
+		}
+	})
+	return ifMatchMode == ifMatchRequired
+}
+
+func versionETag(version int) string {
+	if version == 0 {
+		return ""
+	}
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+func setETag(w http.ResponseWriter, version int) {
+	if version != 0 {
+		w.Header().Set("ETag", versionETag(version))
+	}
+}
+
+// Reads the version the client based its write on from If-Match, 0 when any
+// version will do. Writes the error and reports false when the request
+// cannot go ahead.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		if ifMatchIsRequired() {
+			http.Error(w, "If-Match with the ETag of the current version is required", http.StatusPreconditionRequired)
+			return 0, false
+		}
+		return 0, true
+	}
+	if value == "*" {
+		return 0, true
+	}
+
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		http.Error(w, `If-Match must be a single strong ETag such as "3"`, http.StatusBadRequest)
+		return 0, false
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		http.Error(w, `If-Match must be a single strong ETag such as "3"`, http.StatusBadRequest)
+		return 0, false
+	}
+	return version, true
+}
+
+// Bulk PATCH and DELETE carry the version each item is based on in the body
+// rather than in If-Match. When If-Match is required, every item must have
+// one, hasVersion tells if item i has. Writes 428 and reports false when one
+// is missing.
+func bulkVersionsGiven(w http.ResponseWriter, n int, hasVersion func(i int) bool) bool {
+	if !ifMatchIsRequired() {
+		return true
+	}
+	for i := 0; i < n; i++ {
+		if !hasVersion(i) {
+			http.Error(w, fmt.Sprintf("item %d has no version, every item needs the version it is based on", i), http.StatusPreconditionRequired)
+			return false
+		}
+	}
+	return true
+}
+
+// Answers a write that was based on an older version with 412 and the
+// teacher as they are now. Reports false when err is about something else.
+func writeStaleTeacher(w http.ResponseWriter, id int, err error) bool {
+	if !errors.Is(err, utils.ErrPreconditionFailed) {
+		return false
+	}
+	teacher, err := sqlconnect.GetOneTeacher(w, id, false)
+	if err != nil {
+		return false
+	}
+	writeStale(w, teacher, teacher.Version)
+	return true
+}
+
+// Like writeStaleTeacher, for students
+func writeStaleStudent(w http.ResponseWriter, id int, err error) bool {
+	if !errors.Is(err, utils.ErrPreconditionFailed) {
+		return false
+	}
+	student, err := sqlconnect.GetOneStudent(w, id, false)
+	if err != nil {
+		return false
+	}
+	writeStale(w, student, student.Version)
+	return true
+}
+
+func writeStale(w http.ResponseWriter, current interface{}, version int) {
+	setETag(w, version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(current)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+)
+
+// Year-end promotion of a class. Send "dry_run": true to see what would
+// happen without changing anything.
+func PromoteClassHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.PromotionRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.FromClassID <= 0 || req.ToAcademicYearID <= 0 {
+		http.Error(w, "from_class_id and to_academic_year_id are required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := sqlconnect.PromoteClass(req, auditInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !req.DryRun {
+		w.WriteHeader(http.StatusCreated)
+	}
+	response := struct {
+		Status string                 `json:"status"`
+		Data   models.PromotionResult `json:"data"`
+	}{
+		Status: "success",
+		Data:   result,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetEnrollmentsByClassId(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	enrollments, err := sqlconnect.GetEnrollmentsByClassIdFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := struct {
+		Status string              `json:"status"`
+		Count  int                 `json:"count"`
+		Data   []models.Enrollment `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(enrollments),
+		Data:   enrollments,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+// Purges soft deleted teachers and students every interval, starting right
+// away. Rows deleted more than retention ago are removed for good.
+func SchedulePurge(interval, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			purgeDeleted(retention)
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeDeleted(retention time.Duration) {
+	result, err := sqlconnect.PurgeDeleted(time.Now().Add(-retention), models.AuditInfo{Actor: "scheduled purge"})
+	if err != nil {
+		return
+	}
+	if result.Teachers > 0 || result.Students > 0 {
+		log.Printf("purged %d teachers and %d students deleted before %s", result.Teachers, result.Students, time.Now().Add(-retention).Format(time.DateTime))
+	}
+	if len(result.BlobKeys) == 0 {
+		return
+	}
+
+	store, err := blobStore()
+	if err != nil {
+		return
+	}
+	for _, key := range result.BlobKeys {
+		err = store.Delete(key)
+		if err != nil {
+			utils.ErrorHandler(err, "error deleting purged file "+key)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/jobs"
+	"restapi/pkg/storage"
+	"restapi/pkg/utils"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Finished jobs and their zip files are removed after a day
+const jobRetention = 24 * time.Hour
+
+func GetReportCardHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	termId, err := strconv.Atoi(r.URL.Query().Get("term_id"))
+	if err != nil || termId <= 0 {
+		http.Error(w, "term_id is required", http.StatusBadRequest)
+		return
+	}
+
+	card, err := sqlconnect.GetReportCardFromDb(id, termId)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	var buf bytes.Buffer
+	err = renderReportCard(&buf, card)
+	if err != nil {
+		utils.ErrorHandler(err, "error rendering report card")
+		http.Error(w, "error rendering report card", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", reportCardFileName(card)))
+	w.Write(buf.Bytes())
+}
+
+// Saves a teacher's comment for the report card. Takes {"teacher_id": 2,
+// "term_id": 4, "comment": "..."}, sending it again replaces it.
+func SaveReportCommentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	var comment models.ReportComment
+	err = json.NewDecoder(r.Body).Decode(&comment)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+	comment.StudentID = id
+
+	err = checkBlankFields(comment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	savedComment, err := sqlconnect.SaveReportComment(comment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(savedComment)
+}
+
+// Starts a job that renders the report cards of every student in the class
+// into one zip file. Answers 202 with the job, poll GET /jobs/{id} until it
+// is done and download the zip from its result_url.
+func AddClassReportCardsJobHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	classId, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid class id", http.StatusBadRequest)
+		return
+	}
+
+	termId, err := strconv.Atoi(r.URL.Query().Get("term_id"))
+	if err != nil || termId <= 0 {
+		http.Error(w, "term_id is required", http.StatusBadRequest)
+		return
+	}
+
+	store, err := blobStore()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, old := range jobs.Prune(jobRetention) {
+		if old.ResultKey != "" {
+			store.Delete(old.ResultKey)
+		}
+	}
+
+	job := jobs.Start("reportcards", func(t jobs.Tracker) (string, error) {
+		students, err := sqlconnect.GetStudentsByClassIdFromDb(classId)
+		if err != nil {
+			return "", err
+		}
+		t.SetTotal(len(students))
+
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, student := range students {
+			card, err := sqlconnect.GetReportCardFromDb(student.ID, termId)
+			if err != nil {
+				return "", err
+			}
+			f, err := zw.Create(reportCardFileName(card))
+			if err != nil {
+				return "", err
+			}
+			err = renderReportCard(f, card)
+			if err != nil {
+				return "", utils.ErrorHandler(err, "error rendering report card")
+			}
+			t.Step()
+		}
+		err = zw.Close()
+		if err != nil {
+			return "", err
+		}
+
+		key := fmt.Sprintf("reportcards/class-%d-%s.zip", classId, time.Now().Format("20060102-150405.000"))
+		err = store.Put(key, &buf, "application/zip")
+		if err != nil {
+			return "", utils.ErrorHandler(err, "error storing report cards")
+		}
+		return key, nil
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(jobResponse(job))
+}
+
+func GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := jobs.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobResponse(job))
+}
+
+// Serves the result of a finished job through the signed url in result_url
+func GetJobResultHandler(w http.ResponseWriter, r *http.Request) {
+	err := utils.VerifySignedURL(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	job, ok := jobs.Get(r.PathValue("id"))
+	if !ok || job.Status != jobs.Done {
+		http.Error(w, "job result not found", http.StatusNotFound)
+		return
+	}
+
+	store, err := blobStore()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	file, err := store.Open(job.ResultKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "job result not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		utils.ErrorHandler(err, "error opening job result")
+		http.Error(w, "error opening job result", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.Kind+"-"+job.ID+".zip"))
+	w.Header().Set("Cache-Control", "private, no-store")
+	_, err = io.Copy(w, file)
+	if err != nil {
+		utils.ErrorHandler(err, "error sending job result")
+	}
+}
+
+// The job with a signed download link once it is done
+func jobResponse(job jobs.Job) interface{} {
+	resultURL := ""
+	if job.Status == jobs.Done {
+		resultURL = utils.SignURL("/jobs/"+job.ID+"/result", downloadURLTTL)
+	}
+	return struct {
+		jobs.Job
+		ResultURL string `json:"result_url,omitempty"`
+	}{job, resultURL}
+}
+
+func reportCardFileName(card models.ReportCard) string {
+	return fmt.Sprintf("reportcard-%d-%s-%s.pdf", card.Student.ID, card.Student.LastName, card.Term)
+}
+
+// Lays out one report card on an A4 page
+func renderReportCard(w io.Writer, card models.ReportCard) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Report card "+card.Student.FirstName+" "+card.Student.LastName, true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+	// The core fonts are cp1252, this keeps accented names readable
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Report Card", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(0, 6, tr(card.Student.FirstName+" "+card.Student.LastName), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, tr("Class: "+card.ClassName+"    Term: "+card.Term), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "Issued: "+time.Now().Format(time.DateOnly), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, "Grades", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	pdf.CellFormat(100, 7, "Subject", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(35, 7, "Assessments", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(35, 7, "Average", "1", 1, "C", true, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	if len(card.Subjects) == 0 {
+		pdf.CellFormat(170, 7, "No grades recorded this term", "1", 1, "L", false, 0, "")
+	}
+	for _, s := range card.Subjects {
+		pdf.CellFormat(100, 7, tr(s.Subject), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(35, 7, strconv.Itoa(s.Assessments), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(35, 7, fmt.Sprintf("%.1f%%", s.Average), "1", 1, "C", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	a := card.Attendance
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, "Attendance", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("%.1f%% attended (%d present, %d late, %d absent, %d excused of %d records)",
+		a.Percentage, a.Present, a.Late, a.Absent, a.Excused, a.Total), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.CellFormat(0, 8, "Teacher comments", "", 1, "L", false, 0, "")
+	if len(card.Comments) == 0 {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 6, "No comments", "", 1, "L", false, 0, "")
+	}
+	for _, c := range card.Comments {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 6, tr(c.Teacher+" ("+c.Subject+")"), "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 5, tr(c.Comment), "", "L", false)
+		pdf.Ln(2)
+	}
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/cache"
+	"restapi/pkg/utils"
+)
+
+// Statistics are recomputed at most once per STATS_CACHE_TTL, e.g. "2m"
+const defaultStatsCacheTTL = 30 * time.Second
+
+var (
+	stats     *cache.Cache
+	statsOnce sync.Once
+)
+
+func statsCache() *cache.Cache {
+	statsOnce.Do(func() {
+		ttl := defaultStatsCacheTTL
+		if value := os.Getenv("STATS_CACHE_TTL"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				utils.ErrorHandler(fmt.Errorf("invalid STATS_CACHE_TTL %q", value), "using the default stats cache ttl")
+			} else {
+				ttl = parsed
+			}
+		}
+		stats = cache.New(ttl)
+	})
+	return stats
+}
+
+func GetStudentCountStatsHandler(w http.ResponseWriter, r *http.Request) {
+	writeStats(w, r, "students", "class", sqlconnect.GetStudentCountStats)
+}
+
+func GetTeacherCountStatsHandler(w http.ResponseWriter, r *http.Request) {
+	writeStats(w, r, "teachers", "subject", sqlconnect.GetTeacherCountStats)
+}
+
+func GetStudentTeacherRatioStatsHandler(w http.ResponseWriter, r *http.Request) {
+	writeStats(w, r, "ratio", "grade", sqlconnect.GetStudentTeacherRatioStats)
+}
+
+func GetClassFillRateStatsHandler(w http.ResponseWriter, r *http.Request) {
+	writeStats(w, r, "fillrates", "class", sqlconnect.GetClassFillRateStats)
+}
+
+func GetAttendanceStatsHandler(w http.ResponseWriter, r *http.Request) {
+	writeStats(w, r, "attendance", "class", sqlconnect.GetAttendanceStats)
+}
+
+func GetGradeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	writeStats(w, r, "grades", "subject", sqlconnect.GetGradeStats)
+}
+
+// Reads group_by, from and to, and answers from the cache when the same
+// statistic was computed within the ttl
+func writeStats[T any](w http.ResponseWriter, r *http.Request, name, defaultGroup string, compute func(models.StatsFilter) ([]T, error)) {
+	filter := models.StatsFilter{
+		GroupBy: r.URL.Query().Get("group_by"),
+		From:    r.URL.Query().Get("from"),
+		To:      r.URL.Query().Get("to"),
+	}
+	if filter.GroupBy == "" {
+		filter.GroupBy = defaultGroup
+	}
+	for param, value := range map[string]string{"from": filter.From, "to": filter.To} {
+		if value == "" {
+			continue
+		}
+		_, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			http.Error(w, param+" must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if filter.From != "" && filter.To != "" && filter.From > filter.To {
+		http.Error(w, "from cannot be after to", http.StatusBadRequest)
+		return
+	}
+
+	c := statsCache()
+	key := name + "|" + filter.GroupBy + "|" + filter.From + "|" + filter.To
+	data, ok := c.Get(key)
+	if !ok {
+		computed, err := compute(filter)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		c.Set(key, computed)
+		data = computed
+	}
+	rows := data.([]T)
+
+	response := struct {
+		Status string `json:"status"`
+		models.StatsFilter
+		Count int `json:"count"`
+		Data  []T `json:"data"`
+	}{
+		Status:      "success",
+		StatsFilter: filter,
+		Count:       len(rows),
+		Data:        rows,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControl(r, fmt.Sprintf("private, max-age=%d", int(c.TTL().Seconds()))))
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"restapi/internal/models"
 	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
 	"strconv"
 )
 
@@ -23,7 +25,37 @@ func StudentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Columns GET /students/ can be sorted by
+var studentSortFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+	"class_id":   true,
+}
+
 func GetStudentsHandler(w http.ResponseWriter, r *http.Request) {
+	where := " WHERE 1=1"
+	var args []interface{}
+	if !includeDeleted(r) {
+		where += " AND deleted_at IS NULL"
+	}
+	where, args = addStudentFilter(r, where, args)
+	output, err := parseListOutput(w, r, models.Student{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Polling clients get a 304 without the list being read
+	count, lastModified, err := sqlconnect.GetListState("students", where, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if writeNotModified(w, r, listETag(r, count, lastModified), parseDbTime(lastModified), revalidateCacheControl) {
+		return
+	}
+
 	db, err := sqlconnect.ConnectDb()
 	if err != nil {
 		http.Error(w, "unable to open database", http.StatusInternalServerError)
@@ -31,11 +63,7 @@ func GetStudentsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := "SELECT * FROM students WHERE 1=1"
-	var args []interface{}
-
-	query, args = addStudentFilter(r, query, args)
-	query = sortBy(r, query)
+	query := sortBy(r, "SELECT id, first_name, last_name, email, COALESCE(class_id, 0), deleted_at, version, ROW_START FROM students"+where, studentSortFields)
 
 	// get rows
 	rows, err := db.Query(query, args...)
@@ -45,29 +73,38 @@ func GetStudentsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	studentsList := make([]models.Student, 0)
-
+	list, err := output.start(w, "students")
+	if err != nil {
+		abortList("error writing students")
+		return
+	}
 	for rows.Next() {
 		var student models.Student
-		err = rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.Class)
+		var deletedAt sql.NullString
+		err = rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.ClassID, &deletedAt, &student.Version, &student.UpdatedAt)
+		if err != nil {
+			list.Abort("error scanning database results")
+			return
+		}
+		if deletedAt.Valid {
+			student.DeletedAt = &deletedAt.String
+		}
+		err = list.Write(student)
 		if err != nil {
-			http.Error(w, "error scanning database results", http.StatusInternalServerError)
+			list.Abort("error writing students")
 			return
 		}
-		studentsList = append(studentsList, student)
 	}
-	response := struct {
-		Status string           `json:"status"`
-		Count  int              `json:"count"`
-		Data   []models.Student `json:"data"`
-	}{
-		Status: "success",
-		Count:  len(studentsList),
-		Data:   studentsList,
+	// A read that broke off must not pass for the end of the list
+	err = rows.Err()
+	if err != nil {
+		list.Abort("error reading students")
+		return
+	}
+	err = list.Close()
+	if err != nil {
+		utils.ErrorHandler(err, "error writing students")
 	}
-
-	w.Header().Set("content-type", "application/json")
-	json.NewEncoder(w).Encode(response)
 }
 
 func GetOneStudentHandler(w http.ResponseWriter, r *http.Request) {
@@ -78,14 +115,29 @@ func GetOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	student, err := sqlconnect.GetOneStudent(w, id)
+	asOf, ok, err := parseAsOf(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var student models.Student
+	if ok {
+		student, err = sqlconnect.GetStudentAsOf(id, asOf)
+	} else {
+		student, err = sqlconnect.GetOneStudent(w, id, includeDeleted(r))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if writeNotModified(w, r, versionETag(student.Version), parseDbTime(student.UpdatedAt), revalidateCacheControl) {
 		return
 	}
 	json.NewEncoder(w).Encode(student)
 }
 
+// A bulk request, see atomicBulk for how the batch is saved
 func AddStudentHandler(w http.ResponseWriter, r *http.Request) {
 	var newStudent []models.Student
 	var rawStudent []map[string]interface{}
@@ -103,57 +155,129 @@ func AddStudentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fields := getFieldNames(models.Student{})
+	fields := inputFieldNames(models.Student{})
 
 	allowedFields := make(map[string]struct{})
 	for _, field := range fields {
 		allowedFields[field] = struct{}{}
 	}
 
-	fmt.Println(rawStudent)
-	for _, teacher := range rawStudent {
-		for key := range teacher {
-			if _, ok := allowedFields[key]; !ok {
-				http.Error(w, "unnaccepable field found in request", http.StatusBadRequest)
-				return
-			}
-		}
-	}
-
 	err = json.Unmarshal(body, &newStudent)
 	if err != nil {
 		http.Error(w, "error decoding json", http.StatusBadRequest)
 		return
 	}
 
-	for _, teacher := range newStudent {
-		err = checkBlankFields(teacher)
+	checkErrs := make([]error, len(newStudent))
+	for i, student := range newStudent {
+		checkErrs[i] = checkNewItem(rawStudent[i], allowedFields, student)
+	}
+
+	if !atomicBulk(r) {
+		results, err := runChecked(checkErrs, func(indexes []int) ([]models.BulkItemResult, error) {
+			students := make([]models.Student, len(indexes))
+			for j, i := range indexes {
+				students[j] = newStudent[i]
+			}
+			return sqlconnect.AddStudent(w, students, false, auditInfo(r))
+		})
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
 		}
+		writeBulkResults(w, results, http.StatusCreated)
+		return
 	}
 
-	addedStudent, err := sqlconnect.AddStudent(w, newStudent)
+	err = firstCheckErr(checkErrs)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results, err := sqlconnect.AddStudent(w, newStudent, true, auditInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
+	addedStudent, waitlisted := bulkStudents(results)
 
+	// Students whose class was full are created without a class and listed
+	// under "waitlisted"
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	response := struct {
-		Status string           `json:"status"`
-		Count  int              `json:"count"`
-		Data   []models.Student `json:"data"`
+		Status     string                 `json:"status"`
+		Count      int                    `json:"count"`
+		Data       []models.Student       `json:"data"`
+		Waitlisted []models.WaitlistEntry `json:"waitlisted,omitempty"`
 	}{
-		Status: "success",
-		Count:  len(addedStudent),
-		Data:   addedStudent,
+		Status:     "success",
+		Count:      len(addedStudent),
+		Data:       addedStudent,
+		Waitlisted: waitlisted,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
+// Creates or updates students by their natural key, see naturalKey. A
+// bulk request, see atomicBulk for how the batch is saved.
+func UpsertStudentsHandler(w http.ResponseWriter, r *http.Request) {
+	key, err := naturalKey(r, "email", "first_name", "last_name")
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var rawStudents []map[string]interface{}
+	var students []models.Student
+	err = json.Unmarshal(body, &rawStudents)
+	if err == nil {
+		err = json.Unmarshal(body, &students)
+	}
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	allowedFields := make(map[string]struct{})
+	for _, field := range inputFieldNames(models.Student{}) {
+		allowedFields[field] = struct{}{}
+	}
+	checkErrs := make([]error, len(students))
+	for i, student := range students {
+		checkErrs[i] = checkNewItem(rawStudents[i], allowedFields, student)
+	}
+
+	atomic := atomicBulk(r)
+	if atomic {
+		err = firstCheckErr(checkErrs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	results, err := runChecked(checkErrs, func(indexes []int) ([]models.BulkItemResult, error) {
+		checked := make([]models.Student, len(indexes))
+		for j, i := range indexes {
+			checked[j] = students[i]
+		}
+		return sqlconnect.UpsertStudents(w, checked, key, atomic, auditInfo(r))
+	})
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	writeUpsertResults(w, results, atomic)
+}
+
 func UpdateStudentsHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
@@ -175,16 +299,31 @@ func UpdateStudentsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = sqlconnect.UpdateStudent(w, id, updateStudent)
+	// The version is only taken from If-Match
+	err = checkInputFields(body, models.Student{})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updateStudent)
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+	updateStudent.Version = version
+
+	updatedStudent, entry, err := sqlconnect.UpdateStudent(w, id, updateStudent, auditInfo(r))
+	if writeStaleStudent(w, id, err) {
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	writeStudentPlacement(w, updatedStudent, entry)
 }
 
+// A bulk request, see atomicBulk for how the batch is saved
 func PatchStudentsHandler(w http.ResponseWriter, r *http.Request) {
 	var updates []map[string]interface{}
 	err := json.NewDecoder(r.Body).Decode(&updates)
@@ -192,13 +331,39 @@ func PatchStudentsHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	if !bulkVersionsGiven(w, len(updates), func(i int) bool {
+		version, ok := updates[i]["version"]
+		return ok && version != float64(0)
+	}) {
+		return
+	}
 
-	err = sqlconnect.PatchStudent(w, updates)
+	results, err := sqlconnect.PatchStudent(w, updates, atomicBulk(r), auditInfo(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if !atomicBulk(r) {
+		writeBulkResults(w, results, http.StatusOK)
+		return
+	}
+	_, waitlisted := bulkStudents(results)
+	if len(waitlisted) == 0 {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+
+	// Some students asked for a full class and stay where they are for now
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	response := struct {
+		Status     string                 `json:"status"`
+		Waitlisted []models.WaitlistEntry `json:"waitlisted"`
+	}{
+		Status:     "waitlisted",
+		Waitlisted: waitlisted,
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func PatchOneStudentHandler(w http.ResponseWriter, r *http.Request) {
@@ -209,21 +374,62 @@ func PatchOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updates map[string]interface{}
-	err = json.NewDecoder(r.Body).Decode(&updates)
-	if err != nil {
-		http.Error(w, "error decoding request body", http.StatusBadRequest)
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
 		return
 	}
 
-	existingStudent, err := sqlconnect.PatchOneStudent(w, id, updates)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	patch, ok := readPatch(w, r)
+	if !ok {
+		return
+	}
+
+	existingStudent, entry, err := sqlconnect.PatchOneStudent(w, id, patch, version, auditInfo(r))
+	if writeStaleStudent(w, id, err) || writeFieldErrors(w, err) {
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 
+	writeStudentPlacement(w, existingStudent, entry)
+}
+
+// The students of an atomic batch and the waitlist entries of those whose
+// class was full
+func bulkStudents(results []models.BulkItemResult) ([]models.Student, []models.WaitlistEntry) {
+	students := make([]models.Student, len(results))
+	waitlisted := make([]models.WaitlistEntry, 0)
+	for i, result := range results {
+		students[i] = result.Data.(models.Student)
+		if result.Waitlist != nil {
+			waitlisted = append(waitlisted, *result.Waitlist)
+		}
+	}
+	return students, waitlisted
+}
+
+// Writes the updated student. When the student asked for a full class they
+// stay in their old one, which is answered with 202 and their waitlist entry.
+func writeStudentPlacement(w http.ResponseWriter, student models.Student, entry *models.WaitlistEntry) {
+	setETag(w, student.Version)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(existingStudent)
+	if entry == nil {
+		json.NewEncoder(w).Encode(student)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	response := struct {
+		Status   string               `json:"status"`
+		Data     models.Student       `json:"data"`
+		Waitlist models.WaitlistEntry `json:"waitlist"`
+	}{
+		Status:   "waitlisted",
+		Data:     student,
+		Waitlist: *entry,
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
 func DeleteOneStudentHandler(w http.ResponseWriter, r *http.Request) {
@@ -234,9 +440,17 @@ func DeleteOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = sqlconnect.DeleteOneStudent(w, id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	err = sqlconnect.DeleteOneStudent(w, id, version, auditInfo(r))
+	if writeStaleStudent(w, id, err) {
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
 	}
 
 	// Status 204, with no response body
@@ -254,19 +468,28 @@ func DeleteOneStudentHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// A bulk request, see atomicBulk for how the batch is saved
 func DeleteStudentsHandler(w http.ResponseWriter, r *http.Request) {
-	var ids []int
-	err := json.NewDecoder(r.Body).Decode(&ids)
+	var items []models.BulkDelete
+	err := json.NewDecoder(r.Body).Decode(&items)
 	if err != nil {
 		http.Error(w, "error retrieving body values", http.StatusBadRequest)
 		return
 	}
+	if !bulkVersionsGiven(w, len(items), func(i int) bool { return items[i].Version != 0 }) {
+		return
+	}
 
-	deletedIds, err := sqlconnect.DeleteStudentsFromDb(w, ids)
+	results, err := sqlconnect.DeleteStudentsFromDb(w, items, atomicBulk(r), auditInfo(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if !atomicBulk(r) {
+		writeBulkResults(w, results, http.StatusOK)
 		return
 	}
+	deletedIds := bulkIds(results)
 
 	w.Header().Set("Content-Type", "application/json")
 	response := struct {
@@ -279,12 +502,32 @@ func DeleteStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// Brings back a deleted student, answers 202 when their old class is full
+// and they are put on its waitlist
+func RestoreStudentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	student, entry, err := sqlconnect.RestoreStudent(id, auditInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	writeStudentPlacement(w, student, entry)
+}
+
 func addStudentFilter(r *http.Request, query string, args []interface{}) (string, []interface{}) {
 	params := map[string]string{
 		"first_name": "first_name",
 		"last_name":  "last_name",
 		"email":      "email",
-		"class":      "class",
+		"class_id":   "class_id",
 	}
 
 	for param, dbField := range params {
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+)
+
+func GetSubjectsHandler(w http.ResponseWriter, r *http.Request) {
+	query := ""
+	var args []interface{}
+	for _, param := range []string{"name", "code"} {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + param + " = ?"
+			args = append(args, value)
+		}
+	}
+
+	subjects, err := sqlconnect.GetSubjectsFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string           `json:"status"`
+		Count  int              `json:"count"`
+		Data   []models.Subject `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(subjects),
+		Data:   subjects,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneSubjectHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid subject id", http.StatusBadRequest)
+		return
+	}
+
+	subject, err := sqlconnect.GetOneSubject(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subject)
+}
+
+func AddSubjectsHandler(w http.ResponseWriter, r *http.Request) {
+	var newSubjects []models.Subject
+	var rawSubjects []map[string]interface{}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &rawSubjects)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	allowedFields := make(map[string]struct{})
+	for _, field := range getFieldNames(models.Subject{}) {
+		allowedFields[field] = struct{}{}
+	}
+
+	for _, subject := range rawSubjects {
+		for key := range subject {
+			if _, ok := allowedFields[key]; !ok {
+				http.Error(w, "unnaccepable field found in request", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	err = json.Unmarshal(body, &newSubjects)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for _, subject := range newSubjects {
+		if subject.Name == "" {
+			http.Error(w, "subject name is required", http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedSubjects, err := sqlconnect.AddSubjects(newSubjects)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string           `json:"status"`
+		Count  int              `json:"count"`
+		Data   []models.Subject `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedSubjects),
+		Data:   addedSubjects,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateSubjectHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid subject id", http.StatusBadRequest)
+		return
+	}
+
+	var updateSubject models.Subject
+	err = json.NewDecoder(r.Body).Decode(&updateSubject)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	if updateSubject.Name == "" {
+		http.Error(w, "subject name is required", http.StatusBadRequest)
+		return
+	}
+
+	updatedSubject, err := sqlconnect.UpdateSubject(id, updateSubject)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedSubject)
+}
+
+func PatchOneSubjectHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid subject id", http.StatusBadRequest)
+		return
+	}
+
+	var updates map[string]interface{}
+	err = json.NewDecoder(r.Body).Decode(&updates)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	existingSubject, err := sqlconnect.PatchOneSubject(id, updates)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(existingSubject)
+}
+
+func DeleteOneSubjectHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid subject request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneSubject(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Subject succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 
 	"restapi/internal/models"
 	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
 )
 
 var (
@@ -23,7 +25,6 @@ func init() {
 		ID:        nextId,
 		FirstName: "John",
 		LastName:  "Doe",
-		Class:     "9A",
 		Subject:   "Math",
 	}
 	nextId++
@@ -31,24 +32,42 @@ func init() {
 		ID:        nextId,
 		FirstName: "Luwo",
 		LastName:  "Ko",
-		Class:     "7B",
 		Subject:   "Physics",
 	}
 	nextId++
 }
 
-func isValidSortField(field string) bool {
-	validFields := map[string]bool{
-		"first_name": true,
-		"last_name":  true,
-		"email":      true,
-		"class":      true,
-		"subject":    true,
-	}
-	return validFields[field]
+// Columns GET /teachers/ can be sorted by
+var teacherSortFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+	"subject":    true,
 }
 
 func GetTeachersHandler(w http.ResponseWriter, r *http.Request) {
+	where := " WHERE 1=1"
+	var args []interface{}
+	if !includeDeleted(r) {
+		where += " AND deleted_at IS NULL"
+	}
+	where, args = addTeacherFilter(r, where, args)
+	output, err := parseListOutput(w, r, models.Teacher{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Polling clients get a 304 without the list being read
+	count, lastModified, err := sqlconnect.GetListState("teachers", where, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if writeNotModified(w, r, listETag(r, count, lastModified), parseDbTime(lastModified), revalidateCacheControl) {
+		return
+	}
+
 	db, err := sqlconnect.ConnectDb()
 	if err != nil {
 		http.Error(w, "error trying to open sql database", http.StatusInternalServerError)
@@ -56,11 +75,7 @@ func GetTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer db.Close()
 
-	query := "SELECT * FROM teachers WHERE 1=1"
-	var args []interface{}
-
-	query, args = addTeacherFilter(r, query, args)
-	query = sortBy(r, query)
+	query := sortBy(r, "SELECT id, first_name, last_name, email, subject, deleted_at, version, ROW_START FROM teachers"+where, teacherSortFields)
 
 	fmt.Println(query)
 	// get rows
@@ -71,29 +86,38 @@ func GetTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
-	teacherList := make([]models.Teacher, 0)
-
+	list, err := output.start(w, "teachers")
+	if err != nil {
+		abortList("error writing teachers")
+		return
+	}
 	for rows.Next() {
 		var teacher models.Teacher
-		err = rows.Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Class, &teacher.Subject)
+		var deletedAt sql.NullString
+		err = rows.Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Subject, &deletedAt, &teacher.Version, &teacher.UpdatedAt)
 		if err != nil {
-			http.Error(w, "error scanning database results", http.StatusInternalServerError)
+			list.Abort("error scanning database results")
+			return
+		}
+		if deletedAt.Valid {
+			teacher.DeletedAt = &deletedAt.String
+		}
+		err = list.Write(teacher)
+		if err != nil {
+			list.Abort("error writing teachers")
 			return
 		}
-		teacherList = append(teacherList, teacher)
 	}
-	response := struct {
-		Status string           `json:"status"`
-		Count  int              `json:"count"`
-		Data   []models.Teacher `json:"data"`
-	}{
-		Status: "success",
-		Count:  len(teacherList),
-		Data:   teacherList,
+	// A read that broke off must not pass for the end of the list
+	err = rows.Err()
+	if err != nil {
+		list.Abort("error reading teachers")
+		return
+	}
+	err = list.Close()
+	if err != nil {
+		utils.ErrorHandler(err, "error writing teachers")
 	}
-
-	w.Header().Set("content-type", "application/json")
-	json.NewEncoder(w).Encode(response)
 }
 
 func GetOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
@@ -104,9 +128,23 @@ func GetOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	teacher, err := sqlconnect.GetOneTeacher(w, id)
+	asOf, ok, err := parseAsOf(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var teacher models.Teacher
+	if ok {
+		teacher, err = sqlconnect.GetTeacherAsOf(id, asOf)
+	} else {
+		teacher, err = sqlconnect.GetOneTeacher(w, id, includeDeleted(r))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if writeNotModified(w, r, versionETag(teacher.Version), parseDbTime(teacher.UpdatedAt), revalidateCacheControl) {
 		return
 	}
 	json.NewEncoder(w).Encode(teacher)
@@ -117,7 +155,6 @@ func addTeacherFilter(r *http.Request, query string, args []interface{}) (string
 		"first_name": "first_name",
 		"last_name":  "last_name",
 		"email":      "email",
-		"class":      "class",
 		"subject":    "subject",
 	}
 
@@ -131,6 +168,7 @@ func addTeacherFilter(r *http.Request, query string, args []interface{}) (string
 	return query, args
 }
 
+// A bulk request, see atomicBulk for how the batch is saved
 func AddTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	var newTeachers []models.Teacher
 	var rawTeachers []map[string]interface{}
@@ -148,41 +186,54 @@ func AddTeachersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fields := getFieldNames(models.Teacher{})
+	fields := inputFieldNames(models.Teacher{})
 
 	allowedFields := make(map[string]struct{})
 	for _, field := range fields {
 		allowedFields[field] = struct{}{}
 	}
 
-	fmt.Println(rawTeachers)
-	for _, teacher := range rawTeachers {
-		for key := range teacher {
-			if _, ok := allowedFields[key]; !ok {
-				http.Error(w, "unnaccepable field found in request", http.StatusBadRequest)
-				return
-			}
-		}
-	}
-
 	err = json.Unmarshal(body, &newTeachers)
 	if err != nil {
 		http.Error(w, "error decoding json", http.StatusBadRequest)
 		return
 	}
 
-	for _, teacher := range newTeachers {
-		err = checkBlankFields(teacher)
+	checkErrs := make([]error, len(newTeachers))
+	for i, teacher := range newTeachers {
+		checkErrs[i] = checkNewItem(rawTeachers[i], allowedFields, teacher)
+	}
+
+	if !atomicBulk(r) {
+		results, err := runChecked(checkErrs, func(indexes []int) ([]models.BulkItemResult, error) {
+			teachers := make([]models.Teacher, len(indexes))
+			for j, i := range indexes {
+				teachers[j] = newTeachers[i]
+			}
+			return sqlconnect.AddTeacher(w, teachers, false, auditInfo(r))
+		})
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
 		}
+		writeBulkResults(w, results, http.StatusCreated)
+		return
 	}
 
-	addedTeachers, err := sqlconnect.AddTeacher(w, newTeachers)
+	err = firstCheckErr(checkErrs)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	results, err := sqlconnect.AddTeacher(w, newTeachers, true, auditInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	addedTeachers := make([]models.Teacher, len(results))
+	for i, result := range results {
+		addedTeachers[i] = result.Data.(models.Teacher)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -199,6 +250,64 @@ func AddTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// Creates or updates teachers by their natural key, see naturalKey. A
+// bulk request, see atomicBulk for how the batch is saved.
+func UpsertTeachersHandler(w http.ResponseWriter, r *http.Request) {
+	key, err := naturalKey(r, "email", "first_name", "last_name", "subject")
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	var rawTeachers []map[string]interface{}
+	var teachers []models.Teacher
+	err = json.Unmarshal(body, &rawTeachers)
+	if err == nil {
+		err = json.Unmarshal(body, &teachers)
+	}
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	allowedFields := make(map[string]struct{})
+	for _, field := range inputFieldNames(models.Teacher{}) {
+		allowedFields[field] = struct{}{}
+	}
+	checkErrs := make([]error, len(teachers))
+	for i, teacher := range teachers {
+		checkErrs[i] = checkNewItem(rawTeachers[i], allowedFields, teacher)
+	}
+
+	atomic := atomicBulk(r)
+	if atomic {
+		err = firstCheckErr(checkErrs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	results, err := runChecked(checkErrs, func(indexes []int) ([]models.BulkItemResult, error) {
+		checked := make([]models.Teacher, len(indexes))
+		for j, i := range indexes {
+			checked[j] = teachers[i]
+		}
+		return sqlconnect.UpsertTeachers(w, checked, key, atomic, auditInfo(r))
+	})
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	writeUpsertResults(w, results, atomic)
+}
+
 func UpdateTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
@@ -221,16 +330,33 @@ func UpdateTeachersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = sqlconnect.UpdateTeacher(w, id, updateTeacher)
+	// The version is only taken from If-Match
+	err = checkInputFields(body, models.Teacher{})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+	updateTeacher.Version = version
+
+	updatedTeacher, err := sqlconnect.UpdateTeacher(w, id, updateTeacher, auditInfo(r))
+	if writeStaleTeacher(w, id, err) {
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 
+	setETag(w, updatedTeacher.Version)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updateTeacher)
+	json.NewEncoder(w).Encode(updatedTeacher)
 }
 
+// A bulk request, see atomicBulk for how the batch is saved
 func PatchTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	var updates []map[string]interface{}
 	err := json.NewDecoder(r.Body).Decode(&updates)
@@ -238,10 +364,20 @@ func PatchTeachersHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
+	if !bulkVersionsGiven(w, len(updates), func(i int) bool {
+		version, ok := updates[i]["version"]
+		return ok && version != float64(0)
+	}) {
+		return
+	}
 
-	err = sqlconnect.PatchTeachers(w, updates)
+	results, err := sqlconnect.PatchTeachers(w, updates, atomicBulk(r), auditInfo(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if !atomicBulk(r) {
+		writeBulkResults(w, results, http.StatusOK)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -255,19 +391,25 @@ func PatchOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updates map[string]interface{}
-	err = json.NewDecoder(r.Body).Decode(&updates)
-	if err != nil {
-		http.Error(w, "error decoding request body", http.StatusBadRequest)
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
 		return
 	}
 
-	existingTeacher, err := sqlconnect.PatchOneTeacher(w, id, updates)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	patch, ok := readPatch(w, r)
+	if !ok {
 		return
 	}
 
+	existingTeacher, err := sqlconnect.PatchOneTeacher(w, id, patch, version, auditInfo(r))
+	if writeStaleTeacher(w, id, err) || writeFieldErrors(w, err) {
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	setETag(w, existingTeacher.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(existingTeacher)
 }
@@ -280,9 +422,17 @@ func DeleteOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = sqlconnect.DeleteOneTeacher(w, id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	err = sqlconnect.DeleteOneTeacher(w, id, version, auditInfo(r))
+	if writeStaleTeacher(w, id, err) {
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
 	}
 
 	// Status 204, with no response body
@@ -300,19 +450,28 @@ func DeleteOneTeacherHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// A bulk request, see atomicBulk for how the batch is saved
 func DeleteTeachersHandler(w http.ResponseWriter, r *http.Request) {
-	var ids []int
-	err := json.NewDecoder(r.Body).Decode(&ids)
+	var items []models.BulkDelete
+	err := json.NewDecoder(r.Body).Decode(&items)
 	if err != nil {
 		http.Error(w, "error retrieving body values", http.StatusBadRequest)
 		return
 	}
+	if !bulkVersionsGiven(w, len(items), func(i int) bool { return items[i].Version != 0 }) {
+		return
+	}
 
-	deletedIds, err := sqlconnect.DeleteTeachersFromDb(w, ids)
+	results, err := sqlconnect.DeleteTeachersFromDb(w, items, atomicBulk(r), auditInfo(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if !atomicBulk(r) {
+		writeBulkResults(w, results, http.StatusOK)
 		return
 	}
+	deletedIds := bulkIds(results)
 
 	w.Header().Set("Content-Type", "application/json")
 	response := struct {
@@ -326,6 +485,25 @@ func DeleteTeachersHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+func RestoreTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid teacher id", http.StatusBadRequest)
+		return
+	}
+
+	teacher, err := sqlconnect.RestoreTeacher(id, auditInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	setETag(w, teacher.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(teacher)
+}
+
 func GetStudentsByTeacherId(w http.ResponseWriter, r *http.Request) {
 	teacherId := r.PathValue("id")
 
@@ -349,12 +527,38 @@ func GetStudentsByTeacherId(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+func GetClassesByTeacherId(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid teacher id", http.StatusBadRequest)
+		return
+	}
+
+	classes, err := sqlconnect.GetClassesByTeacherIdFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := struct {
+		Status string         `json:"status"`
+		Count  int            `json:"count"`
+		Data   []models.Class `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(classes),
+		Data:   classes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 // This is a seperate handler due to if the client only wants the count. It is much more faster than getting all students
 func GetStudentCountByTeacherId(w http.ResponseWriter, r *http.Request) {
 	teacherId := r.PathValue("id")
 
-	var studentCount int
-	err := sqlconnect.GetStudentCountByTeacherFromDb(teacherId, studentCount)
+	studentCount, err := sqlconnect.GetStudentCountByTeacherFromDb(teacherId)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+// The term is optional, everything else has to point at an existing row
+func checkTeachingAssignmentFields(assignment models.TeachingAssignment) error {
+	if assignment.TeacherID <= 0 || assignment.ClassID <= 0 || assignment.SubjectID <= 0 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "teacher_id, class_id and subject_id are required")
+	}
+	return nil
+}
+
+func addTeachingAssignmentFilter(r *http.Request, query string, args []interface{}) (string, []interface{}) {
+	params := map[string]string{
+		"teacher_id": "teacher_id",
+		"class_id":   "class_id",
+		"subject_id": "subject_id",
+		"term_id":    "term_id",
+	}
+
+	for param, dbField := range params {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + dbField + " = ?"
+			args = append(args, value)
+		}
+	}
+	return query, args
+}
+
+func GetTeachingAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var args []interface{}
+	query, args := addTeachingAssignmentFilter(r, "", args)
+
+	assignments, err := sqlconnect.GetTeachingAssignmentsFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string                      `json:"status"`
+		Count  int                         `json:"count"`
+		Data   []models.TeachingAssignment `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(assignments),
+		Data:   assignments,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneTeachingAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid teaching assignment id", http.StatusBadRequest)
+		return
+	}
+
+	assignment, err := sqlconnect.GetOneTeachingAssignment(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignment)
+}
+
+func AddTeachingAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	var newAssignments []models.TeachingAssignment
+	var rawAssignments []map[string]interface{}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &rawAssignments)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	allowedFields := make(map[string]struct{})
+	for _, field := range getFieldNames(models.TeachingAssignment{}) {
+		allowedFields[field] = struct{}{}
+	}
+
+	for _, assignment := range rawAssignments {
+		for key := range assignment {
+			if _, ok := allowedFields[key]; !ok {
+				http.Error(w, "unnaccepable field found in request", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	err = json.Unmarshal(body, &newAssignments)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for _, assignment := range newAssignments {
+		err = checkTeachingAssignmentFields(assignment)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedAssignments, err := sqlconnect.AddTeachingAssignments(newAssignments)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string                      `json:"status"`
+		Count  int                         `json:"count"`
+		Data   []models.TeachingAssignment `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedAssignments),
+		Data:   addedAssignments,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateTeachingAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid teaching assignment id", http.StatusBadRequest)
+		return
+	}
+
+	var updateAssignment models.TeachingAssignment
+	err = json.NewDecoder(r.Body).Decode(&updateAssignment)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkTeachingAssignmentFields(updateAssignment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedAssignment, err := sqlconnect.UpdateTeachingAssignment(id, updateAssignment)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedAssignment)
+}
+
+func PatchOneTeachingAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid teaching assignment id", http.StatusBadRequest)
+		return
+	}
+
+	var updates map[string]interface{}
+	err = json.NewDecoder(r.Body).Decode(&updates)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	existingAssignment, err := sqlconnect.PatchOneTeachingAssignment(id, updates)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(existingAssignment)
+}
+
+func DeleteOneTeachingAssignmentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid teaching assignment request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneTeachingAssignment(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Teaching assignment succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+func checkTimetableSlotFields(slot models.TimetableSlot) error {
+	if slot.ClassID <= 0 || slot.SubjectID <= 0 || slot.TeacherID <= 0 || slot.TermID <= 0 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "class_id, subject_id, teacher_id and term_id are required")
+	}
+	if slot.Weekday < 1 || slot.Weekday > 7 {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "weekday must be between 1 (Monday) and 7 (Sunday)")
+	}
+	start, err := time.Parse("15:04", slot.StartTime)
+	if err != nil {
+		return utils.ErrorHandler(err, "start_time must be formatted as HH:MM")
+	}
+	end, err := time.Parse("15:04", slot.EndTime)
+	if err != nil {
+		return utils.ErrorHandler(err, "end_time must be formatted as HH:MM")
+	}
+	if !start.Before(end) {
+		return utils.ErrorHandler(fmt.Errorf("invalid field in models"), "start_time must be before end_time")
+	}
+	return nil
+}
+
+func addTimetableFilter(r *http.Request, query string, args []interface{}) (string, []interface{}) {
+	params := map[string]string{
+		"class_id":   "class_id",
+		"subject_id": "subject_id",
+		"teacher_id": "teacher_id",
+		"room":       "room",
+		"weekday":    "weekday",
+		"term_id":    "term_id",
+	}
+
+	for param, dbField := range params {
+		value := r.URL.Query().Get(param)
+		if value != "" {
+			query += " AND " + dbField + " = ?"
+			args = append(args, value)
+		}
+	}
+	return query, args
+}
+
+func GetTimetableSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	var args []interface{}
+	query, args := addTimetableFilter(r, "", args)
+
+	slots, err := sqlconnect.GetTimetableSlotsFromDb(query, args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Status string                 `json:"status"`
+		Count  int                    `json:"count"`
+		Data   []models.TimetableSlot `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(slots),
+		Data:   slots,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetOneTimetableSlotHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid timetable slot id", http.StatusBadRequest)
+		return
+	}
+
+	slot, err := sqlconnect.GetOneTimetableSlot(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(slot)
+}
+
+func AddTimetableSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	var newSlots []models.TimetableSlot
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading request body", http.StatusInternalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	err = json.Unmarshal(body, &newSlots)
+	if err != nil {
+		http.Error(w, "error decoding json", http.StatusBadRequest)
+		return
+	}
+
+	for _, slot := range newSlots {
+		err = checkTimetableSlotFields(slot)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	addedSlots, err := sqlconnect.AddTimetableSlots(newSlots)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string                 `json:"status"`
+		Count  int                    `json:"count"`
+		Data   []models.TimetableSlot `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(addedSlots),
+		Data:   addedSlots,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateTimetableSlotHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid timetable slot id", http.StatusBadRequest)
+		return
+	}
+
+	var updateSlot models.TimetableSlot
+	err = json.NewDecoder(r.Body).Decode(&updateSlot)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	err = checkTimetableSlotFields(updateSlot)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedSlot, err := sqlconnect.UpdateTimetableSlot(id, updateSlot)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updatedSlot)
+}
+
+func DeleteOneTimetableSlotHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid timetable slot request", http.StatusBadRequest)
+		return
+	}
+
+	err = sqlconnect.DeleteOneTimetableSlot(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Status string `json:"status"`
+		ID     int    `json:"id"`
+	}{
+		Status: "Timetable slot succesfully deleted",
+		ID:     id,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetTeacherTimetableHandler(w http.ResponseWriter, r *http.Request) {
+	writeTimetable(w, r, "teacher_id", false)
+}
+
+func GetClassTimetableHandler(w http.ResponseWriter, r *http.Request) {
+	writeTimetable(w, r, "class_id", false)
+}
+
+func GetTeacherTimetableICSHandler(w http.ResponseWriter, r *http.Request) {
+	writeTimetable(w, r, "teacher_id", true)
+}
+
+func GetClassTimetableICSHandler(w http.ResponseWriter, r *http.Request) {
+	writeTimetable(w, r, "class_id", true)
+}
+
+// Shared by the teacher and class timetable views. Writes json, or an
+// iCalendar file with one weekly recurring event per slot when ics is true.
+func writeTimetable(w http.ResponseWriter, r *http.Request, column string, ics bool) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := sqlconnect.GetTimetableFromDb(column, id, r.URL.Query().Get("term_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !ics {
+		response := struct {
+			Status string                  `json:"status"`
+			Count  int                     `json:"count"`
+			Data   []models.TimetableEntry `json:"data"`
+		}{
+			Status: "success",
+			Count:  len(entries),
+			Data:   entries,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	events := make([]utils.ICalEvent, 0, len(entries))
+	for _, e := range entries {
+		event, err := timetableEvent(e)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		events = append(events, event)
+	}
+
+	name := fmt.Sprintf("timetable-%s-%d", column[:len(column)-3], id)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".ics"))
+	err = utils.WriteICalendar(w, name, events)
+	if err != nil {
+		utils.ErrorHandler(err, "error writing calendar")
+	}
+}
+
+// The slot repeats weekly from its first weekday in the term until the term ends
+func timetableEvent(e models.TimetableEntry) (utils.ICalEvent, error) {
+	termStart, err := time.Parse(time.DateOnly, e.TermStartDate)
+	if err != nil {
+		return utils.ICalEvent{}, utils.ErrorHandler(err, "invalid term start date")
+	}
+	termEnd, err := time.Parse(time.DateOnly, e.TermEndDate)
+	if err != nil {
+		return utils.ICalEvent{}, utils.ErrorHandler(err, "invalid term end date")
+	}
+
+	first := utils.NextWeekday(termStart, e.Weekday)
+	start, err := utils.DateAt(first, e.StartTime)
+	if err != nil {
+		return utils.ICalEvent{}, utils.ErrorHandler(err, "invalid slot start time")
+	}
+	end, err := utils.DateAt(first, e.EndTime)
+	if err != nil {
+		return utils.ICalEvent{}, utils.ErrorHandler(err, "invalid slot end time")
+	}
+
+	return utils.ICalEvent{
+		UID:         fmt.Sprintf("timetable-slot-%d@restapi", e.ID),
+		Summary:     e.SubjectName + " - " + e.ClassName,
+		Location:    e.Room,
+		Description: "Teacher: " + e.TeacherName,
+		Start:       start,
+		End:         end,
+		Until:       termEnd,
+	}, nil
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+)
+
+// Moves a student to another class. Takes {"from_class_id": 1,
+// "to_class_id": 2, "effective_date": "2024-02-01", "reason": "..."}, the
+// effective date defaults to today. The X-Actor header is recorded as who
+// made the transfer.
+func TransferStudentHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.TransferRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "error decoding request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.FromClassID <= 0 || req.ToClassID <= 0 || req.Reason == "" {
+		http.Error(w, "from_class_id, to_class_id and reason are required", http.StatusBadRequest)
+		return
+	}
+	if req.FromClassID == req.ToClassID {
+		http.Error(w, "student is already in that class", http.StatusBadRequest)
+		return
+	}
+	if req.EffectiveDate == "" {
+		req.EffectiveDate = time.Now().Format(time.DateOnly)
+	}
+	effective, err := time.Parse(time.DateOnly, req.EffectiveDate)
+	if err != nil {
+		http.Error(w, "effective_date must be formatted as YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	// students.class_id changes right away, so a transfer cannot wait for a
+	// later date
+	if effective.After(time.Now()) {
+		http.Error(w, "effective_date cannot be in the future", http.StatusBadRequest)
+		return
+	}
+
+	enrollment, err := sqlconnect.TransferStudent(id, req, auditInfo(r))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := struct {
+		Status string            `json:"status"`
+		Data   models.Enrollment `json:"data"`
+	}{
+		Status: "success",
+		Data:   enrollment,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetStudentHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+
+	history, err := sqlconnect.GetStudentHistoryFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := struct {
+		Status string                     `json:"status"`
+		Count  int                        `json:"count"`
+		Data   []models.EnrollmentHistory `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(history),
+		Data:   history,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
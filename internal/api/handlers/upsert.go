@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// PUT /teachers/ and /students/ match every item to a stored row by a natural
+// key, ?key=email by default. Several fields can make up the key, e.g.
+// ?key=first_name,last_name. Only rows that are not deleted are matched.
+func naturalKey(r *http.Request, allowed ...string) ([]string, error) {
+	value := r.URL.Query().Get("key")
+	if value == "" {
+		return []string{"email"}, nil
+	}
+
+	var key []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		found := false
+		for _, allowedField := range allowed {
+			if field == allowedField {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, utils.InvalidHandler(nil, "key must be made of "+strings.Join(allowed, ", "))
+		}
+		key = append(key, field)
+	}
+	return key, nil
+}
+
+// Answers an upsert with what happened to every item. An atomic batch either
+// went through as a whole, which is a 200, or failed before this. Otherwise
+// it is a 207 like the other bulk requests.
+func writeUpsertResults(w http.ResponseWriter, results []models.BulkItemResult, atomic bool) {
+	failed := setBulkStatuses(results, http.StatusOK)
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Action]++
+	}
+
+	status, code := "multi-status", http.StatusMultiStatus
+	if atomic {
+		status, code = "success", http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	response := struct {
+		Status    string                  `json:"status"`
+		Count     int                     `json:"count"`
+		Created   int                     `json:"created"`
+		Updated   int                     `json:"updated"`
+		Unchanged int                     `json:"unchanged"`
+		Failed    int                     `json:"failed"`
+		Results   []models.BulkItemResult `json:"results"`
+	}{
+		Status:    status,
+		Count:     len(results),
+		Created:   counts[models.BulkCreated],
+		Updated:   counts[models.BulkUpdated],
+		Unchanged: counts[models.BulkUnchanged],
+		Failed:    failed,
+		Results:   results,
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"restapi/internal/models"
+	"restapi/internal/repository/sqlconnect"
+	"restapi/pkg/utils"
+)
+
+func GetStudentVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	writeVersions(w, r, "student", sqlconnect.GetStudentVersionsFromDb)
+}
+
+func GetTeacherVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	writeVersions(w, r, "teacher", sqlconnect.GetTeacherVersionsFromDb)
+}
+
+func GetStudentVersionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	writeVersionDiff(w, r, "student", sqlconnect.GetStudentVersionsFromDb)
+}
+
+func GetTeacherVersionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	writeVersionDiff(w, r, "teacher", sqlconnect.GetTeacherVersionsFromDb)
+}
+
+// Lists every version of the row, oldest first
+func writeVersions(w http.ResponseWriter, r *http.Request, entity string, versionsFromDb func(int) ([]models.RecordVersion, error)) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid "+entity+" id", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := versionsFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	response := struct {
+		Status string                 `json:"status"`
+		Count  int                    `json:"count"`
+		Data   []models.RecordVersion `json:"data"`
+	}{
+		Status: "success",
+		Count:  len(versions),
+		Data:   versions,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Shows what changed between the versions from and to, as in the version of
+// a RecordVersion. to defaults to the current version and from to the one
+// stored before to.
+func writeVersionDiff(w http.ResponseWriter, r *http.Request, entity string, versionsFromDb func(int) ([]models.RecordVersion, error)) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid "+entity+" id", http.StatusBadRequest)
+		return
+	}
+
+	versions, err := versionsFromDb(id)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	to := len(versions) - 1
+	if value := r.URL.Query().Get("to"); value != "" {
+		to = versionIndex(versions, value)
+		if to < 0 {
+			http.Error(w, fmt.Sprintf("to must be one of the versions of the %s", entity), http.StatusBadRequest)
+			return
+		}
+	}
+	from := to - 1
+	if value := r.URL.Query().Get("from"); value != "" {
+		from = versionIndex(versions, value)
+		if from < 0 {
+			http.Error(w, fmt.Sprintf("from must be one of the versions of the %s", entity), http.StatusBadRequest)
+			return
+		}
+	}
+	if from < 0 {
+		http.Error(w, "there is no version before that one", http.StatusBadRequest)
+		return
+	}
+
+	before, after, err := utils.DiffFields(versions[from].Data, versions[to].Data)
+	if err != nil {
+		utils.ErrorHandler(err, "error comparing versions")
+		http.Error(w, "error comparing versions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.VersionDiff{
+		From:   versions[from].Version,
+		To:     versions[to].Version,
+		Before: before,
+		After:  after,
+	})
+}
+
+// The index in versions of the last state stored as the version in value,
+// -1 when there is none
+func versionIndex(versions []models.RecordVersion, value string) int {
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Version == version {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+func TestWriteVersionDiff(t *testing.T) {
+	// Version 2 was written twice in one transaction, the last one counts
+	versions := []models.RecordVersion{
+		{Version: 1, Data: models.Student{ID: 7, FirstName: "Ada", LastName: "Lovelace", ClassID: 1}},
+		{Version: 2, Data: models.Student{ID: 7, FirstName: "Ada", LastName: "Byron", ClassID: 1}},
+		{Version: 2, Data: models.Student{ID: 7, FirstName: "Ada", LastName: "King", ClassID: 1}},
+		{Version: 3, Data: models.Student{ID: 7, FirstName: "Ada", LastName: "King", ClassID: 2}},
+	}
+	tests := []struct {
+		name       string
+		id         string
+		query      string
+		wantStatus int
+		wantDiff   models.VersionDiff
+		wantBody   string
+	}{
+		{name: "last change", id: "7", wantStatus: http.StatusOK,
+			wantDiff: models.VersionDiff{From: 2, To: 3, Before: map[string]interface{}{"class_id": float64(1)}, After: map[string]interface{}{"class_id": float64(2)}}},
+		{name: "to an earlier version", id: "7", query: "?to=2", wantStatus: http.StatusOK,
+			wantDiff: models.VersionDiff{From: 2, To: 2, Before: map[string]interface{}{"last_name": "Byron"}, After: map[string]interface{}{"last_name": "King"}}},
+		{name: "between two versions", id: "7", query: "?from=1&to=3", wantStatus: http.StatusOK,
+			wantDiff: models.VersionDiff{From: 1, To: 3, Before: map[string]interface{}{"last_name": "Lovelace", "class_id": float64(1)},
+				After: map[string]interface{}{"last_name": "King", "class_id": float64(2)}}},
+		{name: "nothing before the first", id: "7", query: "?to=1", wantStatus: http.StatusBadRequest, wantBody: "there is no version before that one"},
+		{name: "unknown to", id: "7", query: "?to=9", wantStatus: http.StatusBadRequest, wantBody: "to must be one of the versions of the student"},
+		{name: "from not a number", id: "7", query: "?from=first", wantStatus: http.StatusBadRequest, wantBody: "from must be one of the versions of the student"},
+		{name: "bad id", id: "x", wantStatus: http.StatusBadRequest, wantBody: "invalid student id"},
+		{name: "no such student", id: "8", wantStatus: http.StatusNotFound, wantBody: "no versions found"},
+	}
+	versionsFromDb := func(id int) ([]models.RecordVersion, error) {
+		if id != 7 {
+			return nil, utils.NotFoundHandler(sql.ErrNoRows, "no versions found")
+		}
+		return versions, nil
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/students/"+tt.id+"/versions/diff"+tt.query, nil)
+			r.SetPathValue("id", tt.id)
+			w := httptest.NewRecorder()
+			writeVersionDiff(w, r, "student", versionsFromDb)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+					t.Fatalf("body = %q, want %q", got, tt.wantBody)
+				}
+				return
+			}
+			var diff models.VersionDiff
+			err := json.NewDecoder(w.Body).Decode(&diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(diff, tt.wantDiff) {
+				t.Fatalf("diff = %+v, want %+v", diff, tt.wantDiff)
+			}
+		})
+	}
+}
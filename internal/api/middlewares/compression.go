@@ -9,32 +9,78 @@ import (
 
 func Compression(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
 		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
 			fmt.Println("Could not locate gzip")
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		//Set Response Header
-		w.Header().Set("Content-Encoding", "gzip")
-		gz := gzip.NewWriter(w)
-		defer gz.Close()
-
 		// Wrap response Writer
-		w = &gzipResponseWriter{
-			ResponseWriter: w,
-			Writer:         gz,
-		}
-
-		next.ServeHTTP(w, r)
+		gw := &gzipResponseWriter{ResponseWriter: w, head: r.Method == http.MethodHead}
+		next.ServeHTTP(gw, r)
+		// Not deferred: a handler that cuts its response off panics with
+		// http.ErrAbortHandler, and ending the gzip stream then would make
+		// the cut body look complete to the client
+		gw.Close()
 	})
 }
 
+// Compresses the body once the status is known. Responses that have no
+// body, such as a 304, a 204 or the answer to a HEAD, go out as they are,
+// without Content-Encoding and without an empty gzip stream.
 type gzipResponseWriter struct {
 	http.ResponseWriter
-	Writer *gzip.Writer
+	Writer      *gzip.Writer
+	head        bool
+	wroteHeader bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		g.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if code >= http.StatusOK && code != http.StatusNoContent && code != http.StatusNotModified && !g.head {
+		g.wroteHeader = true
+		g.Header().Set("Content-Encoding", "gzip")
+		// The length the handler set is the one before compression
+		g.Header().Del("Content-Length")
+		g.Writer = gzip.NewWriter(g.ResponseWriter)
+	} else if code >= http.StatusOK {
+		g.wroteHeader = true
+	}
+	g.ResponseWriter.WriteHeader(code)
 }
 
 func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.Writer == nil {
+		return g.ResponseWriter.Write(b)
+	}
 	return g.Writer.Write(b)
 }
+
+// Sends what has been compressed so far, so streamed responses reach the
+// client while the handler is still writing them
+func (g *gzipResponseWriter) Flush() {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.Writer != nil {
+		g.Writer.Flush()
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+func (g *gzipResponseWriter) Close() {
+	if g.Writer != nil {
+		g.Writer.Close()
+	}
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
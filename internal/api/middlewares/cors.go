@@ -20,8 +20,8 @@ func Cors(next http.Handler) http.Handler {
 			http.Error(w, "Not allowed by CORS", http.StatusForbidden)
 			return
 		}
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Expose-Headers", "Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Actor, X-Request-ID, If-Match, If-None-Match, If-Modified-Since, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "Authorization, X-Request-ID, ETag, Idempotent-Replayed")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Max-Age", "3600")
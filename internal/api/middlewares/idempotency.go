@@ -0,0 +1,272 @@
+package middlewares
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Keys longer than this are refused, so are requests with a key whose body
+// is larger than maxIdempotentBodySize. A response larger than that is not
+// stored, like a 5xx.
+//
+// At most maxIdempotencyEntries keys and maxIdempotencyBytes of stored
+// responses are kept. The least recently used responses make room for new
+// ones. A new key is refused with a 503 when the room is taken by requests
+// that are still running.
+const (
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20
+	maxIdempotencyEntries   = 10000
+	maxIdempotencyBytes     = 64 << 20
+)
+
+// A POST sent with an Idempotency-Key is answered once. Retries with the
+// same key and the same request get the stored response again, marked with
+// Idempotent-Replayed, for ttl after the first one finished. A retry that
+// arrives while the first request is still running waits for it. Reusing a
+// key for a different request is a 409. Keys are kept apart per X-Actor.
+// Responses with a 5xx status are not stored, so the request can be retried.
+//
+// Entries are kept in memory, per process. A retry that reaches another
+// instance, or this one after a restart, runs again.
+type idempotency struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	// The entries, most recently used first
+	lru *list.List
+	// The size of the stored response bodies
+	bytes      int
+	maxEntries int
+	maxBytes   int
+	ttl        time.Duration
+}
+
+type idempotencyEntry struct {
+	key         string
+	elem        *list.Element
+	fingerprint string
+	done        chan struct{} // closed once the response is stored
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time // zero while the request is running
+}
+
+func NewIdempotency(ttl time.Duration) *idempotency {
+	id := &idempotency{
+		entries:    make(map[string]*idempotencyEntry),
+		lru:        list.New(),
+		maxEntries: maxIdempotencyEntries,
+		maxBytes:   maxIdempotencyBytes,
+		ttl:        ttl,
+	}
+	go id.removeExpired()
+	return id
+}
+
+func (id *idempotency) removeExpired() {
+	for {
+		time.Sleep(time.Minute)
+		now := time.Now()
+		id.mu.Lock()
+		for _, entry := range id.entries {
+			if !entry.expires.IsZero() && now.After(entry.expires) {
+				id.remove(entry)
+			}
+		}
+		id.mu.Unlock()
+	}
+}
+
+// Must be called with mu held
+func (id *idempotency) remove(entry *idempotencyEntry) {
+	delete(id.entries, entry.key)
+	id.lru.Remove(entry.elem)
+	id.bytes -= len(entry.body)
+}
+
+// Drops the least recently used stored responses until entries more keys
+// and bytes more of response fit. Reports false when they still do not,
+// because the rest of the keys belong to running requests. Must be called
+// with mu held.
+func (id *idempotency) makeRoom(entries int, bytes int) bool {
+	full := func() bool {
+		return len(id.entries)+entries > id.maxEntries || id.bytes+bytes > id.maxBytes
+	}
+	for elem := id.lru.Back(); elem != nil && full(); {
+		prev := elem.Prev()
+		if entry := elem.Value.(*idempotencyEntry); !entry.expires.IsZero() {
+			id.remove(entry)
+		}
+		elem = prev
+	}
+	return !full()
+}
+
+func (id *idempotency) MiddleWare(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			http.Error(w, "error reading request body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxIdempotentBodySize {
+			http.Error(w, fmt.Sprintf("requests with an Idempotency-Key are limited to %d MB", maxIdempotentBodySize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.New()
+		io.WriteString(sum, r.Method+" "+r.URL.RequestURI()+"\n"+r.Header.Get("Content-Type")+"\n")
+		sum.Write(body)
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+		key = r.Header.Get("X-Actor") + "\x00" + key
+
+		for {
+			id.mu.Lock()
+			entry, ok := id.entries[key]
+			if ok && !entry.expires.IsZero() && time.Now().After(entry.expires) {
+				id.remove(entry)
+				ok = false
+			}
+			if !ok {
+				if !id.makeRoom(1, 0) {
+					id.mu.Unlock()
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, "too many requests with an Idempotency-Key are running, try again", http.StatusServiceUnavailable)
+					return
+				}
+				entry = &idempotencyEntry{key: key, fingerprint: fingerprint, done: make(chan struct{})}
+				entry.elem = id.lru.PushFront(entry)
+				id.entries[key] = entry
+				id.mu.Unlock()
+				id.serveFirst(w, r, next, entry)
+				return
+			}
+			id.lru.MoveToFront(entry.elem)
+			id.mu.Unlock()
+
+			if entry.fingerprint != fingerprint {
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusConflict)
+				return
+			}
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+			if entry.header != nil {
+				replay(w, entry)
+				return
+			}
+			// The first request failed and was forgotten, try again
+		}
+	})
+}
+
+// Runs the request that holds the key and stores its response. The entry is
+// dropped again when the response is a 5xx, does not fit, or the handler
+// panics.
+func (id *idempotency) serveFirst(w http.ResponseWriter, r *http.Request, next http.Handler, entry *idempotencyEntry) {
+	recorder := &recordingWriter{ResponseWriter: w, before: w.Header().Clone()}
+	keep := false
+	defer func() {
+		id.mu.Lock()
+		if keep && id.makeRoom(0, recorder.body.Len()) {
+			entry.status = recorder.status
+			entry.header = recorder.header
+			entry.body = recorder.body.Bytes()
+			entry.expires = time.Now().Add(id.ttl)
+			id.bytes += len(entry.body)
+		} else {
+			id.remove(entry)
+		}
+		id.mu.Unlock()
+		close(entry.done)
+	}()
+
+	next.ServeHTTP(recorder, r)
+
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+		recorder.header = recorder.handlerHeader()
+	}
+	keep = recorder.status < http.StatusInternalServerError && !recorder.tooLarge
+}
+
+func replay(w http.ResponseWriter, entry *idempotencyEntry) {
+	for name, values := range entry.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
+
+// Passes the response on and keeps a copy of it. Only the headers the
+// handler set are kept, not the ones the middlewares around it set for
+// this request alone.
+type recordingWriter struct {
+	http.ResponseWriter
+	before http.Header
+	status int
+	header http.Header
+	body   bytes.Buffer
+	// Set once the body outgrows maxIdempotentBodySize, it is not kept then
+	tooLarge bool
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+		rw.header = rw.handlerHeader()
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if !rw.tooLarge && rw.body.Len()+len(b) <= maxIdempotentBodySize {
+		rw.body.Write(b)
+	} else {
+		rw.tooLarge = true
+		rw.body.Reset()
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *recordingWriter) handlerHeader() http.Header {
+	header := make(http.Header)
+	for name, values := range rw.Header() {
+		if before, ok := rw.before[name]; ok && slices.Equal(before, values) {
+			continue
+		}
+		header[name] = append([]string(nil), values...)
+	}
+	return header
+}
@@ -0,0 +1,268 @@
+package middlewares
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type idempotentRequest struct {
+	method string
+	key    string
+	actor  string
+	body   string
+}
+
+func (req idempotentRequest) build() *http.Request {
+	method := req.method
+	if method == "" {
+		method = http.MethodPost
+	}
+	r := httptest.NewRequest(method, "/teachers/", strings.NewReader(req.body))
+	r.Header.Set("Content-Type", "application/json")
+	if req.key != "" {
+		r.Header.Set("Idempotency-Key", req.key)
+	}
+	if req.actor != "" {
+		r.Header.Set("X-Actor", req.actor)
+	}
+	return r
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		respSize   int
+		first      idempotentRequest
+		second     idempotentRequest
+		wantCalls  int32
+		wantStatus int
+		replayed   bool
+	}{
+		{name: "replayed", first: idempotentRequest{key: "a", body: `{"x":1}`}, second: idempotentRequest{key: "a", body: `{"x":1}`},
+			wantCalls: 1, wantStatus: http.StatusCreated, replayed: true},
+		{name: "without a key", first: idempotentRequest{body: `{"x":1}`}, second: idempotentRequest{body: `{"x":1}`},
+			wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "only POST", first: idempotentRequest{method: http.MethodPatch, key: "a"}, second: idempotentRequest{method: http.MethodPatch, key: "a"},
+			wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "other key", first: idempotentRequest{key: "a", body: `{"x":1}`}, second: idempotentRequest{key: "b", body: `{"x":1}`},
+			wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "key reused for another body", first: idempotentRequest{key: "a", body: `{"x":1}`}, second: idempotentRequest{key: "a", body: `{"x":2}`},
+			wantCalls: 1, wantStatus: http.StatusConflict},
+		{name: "keys kept apart per actor", first: idempotentRequest{key: "a", actor: "ann"}, second: idempotentRequest{key: "a", actor: "bob"},
+			wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "client errors are kept", status: http.StatusBadRequest, first: idempotentRequest{key: "a"}, second: idempotentRequest{key: "a"},
+			wantCalls: 1, wantStatus: http.StatusBadRequest, replayed: true},
+		{name: "server errors are not kept", status: http.StatusInternalServerError, first: idempotentRequest{key: "a"}, second: idempotentRequest{key: "a"},
+			wantCalls: 2, wantStatus: http.StatusInternalServerError},
+		{name: "large responses are not kept", respSize: maxIdempotentBodySize + 1, first: idempotentRequest{key: "a"}, second: idempotentRequest{key: "a"},
+			wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "key too long", first: idempotentRequest{key: strings.Repeat("k", maxIdempotencyKeyLength+1)},
+			second: idempotentRequest{key: strings.Repeat("k", maxIdempotencyKeyLength+1)}, wantCalls: 0, wantStatus: http.StatusBadRequest},
+		{name: "body too large", first: idempotentRequest{key: "a", body: strings.Repeat("x", maxIdempotentBodySize+1)},
+			second: idempotentRequest{key: "a", body: strings.Repeat("x", maxIdempotentBodySize+1)}, wantCalls: 0, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := tt.status
+			if status == 0 {
+				status = http.StatusCreated
+			}
+			var calls atomic.Int32
+			handler := NewIdempotency(time.Hour).MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				body, _ := io.ReadAll(r.Body)
+				w.Header().Set("Location", "/teachers/1")
+				w.WriteHeader(status)
+				if tt.respSize > 0 {
+					w.Write([]byte(strings.Repeat("r", tt.respSize)))
+					return
+				}
+				w.Write([]byte(string(body) + " call " + strconv.Itoa(int(n))))
+			}))
+
+			first := httptest.NewRecorder()
+			handler.ServeHTTP(first, tt.first.build())
+			second := httptest.NewRecorder()
+			handler.ServeHTTP(second, tt.second.build())
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("handler ran %d times, want %d", got, tt.wantCalls)
+			}
+			if second.Code != tt.wantStatus {
+				t.Fatalf("second request got %d, want %d", second.Code, tt.wantStatus)
+			}
+			if got := second.Header().Get("Idempotent-Replayed") == "true"; got != tt.replayed {
+				t.Fatalf("second request replayed %v, want %v", got, tt.replayed)
+			}
+			if first.Header().Get("Idempotent-Replayed") != "" {
+				t.Fatal("first request is marked as replayed")
+			}
+			if tt.replayed {
+				if second.Body.String() != first.Body.String() {
+					t.Fatalf("replayed body %q, want %q", second.Body.String(), first.Body.String())
+				}
+				if second.Header().Get("Location") != "/teachers/1" {
+					t.Fatalf("replayed Location %q, want the handler's", second.Header().Get("Location"))
+				}
+			}
+		})
+	}
+}
+
+// Headers set by the middlewares around it belong to each request, not to
+// the stored response
+func TestIdempotencyKeepsHandlerHeaders(t *testing.T) {
+	var requestId atomic.Int32
+	handler := NewIdempotency(time.Hour).MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	outer := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", strconv.Itoa(int(requestId.Add(1))))
+		handler.ServeHTTP(w, r)
+	})
+
+	first := httptest.NewRecorder()
+	outer.ServeHTTP(first, idempotentRequest{key: "a"}.build())
+	second := httptest.NewRecorder()
+	outer.ServeHTTP(second, idempotentRequest{key: "a"}.build())
+
+	if got := second.Header().Get("X-Request-ID"); got != "2" {
+		t.Fatalf("replay has X-Request-ID %q, want the one of its own request", got)
+	}
+	if got := second.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("replay has Content-Type %q, want the handler's", got)
+	}
+}
+
+// A retry that arrives while the first request runs waits for its response
+func TestIdempotencyConcurrentRetry(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := NewIdempotency(time.Hour).MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}))
+
+	recorders := make([]*httptest.ResponseRecorder, 5)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			handler.ServeHTTP(w, idempotentRequest{key: "a", body: "{}"}.build())
+		}(recorders[i])
+		if i == 0 {
+			<-started
+		}
+	}
+	// Let the retries reach the wait before the first one finishes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("handler ran %d times, want 1", got)
+	}
+	replayed := 0
+	for _, w := range recorders {
+		if w.Code != http.StatusCreated || w.Body.String() != "done" {
+			t.Fatalf("got %d %q, want 201 done", w.Code, w.Body.String())
+		}
+		if w.Header().Get("Idempotent-Replayed") == "true" {
+			replayed++
+		}
+	}
+	if replayed != len(recorders)-1 {
+		t.Fatalf("%d responses replayed, want %d", replayed, len(recorders)-1)
+	}
+}
+
+func TestIdempotencyEvictsLeastRecentlyUsed(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int
+		keys       []string
+		replayed   []bool
+	}{
+		{name: "by number of keys", maxEntries: 2, maxBytes: maxIdempotencyBytes,
+			keys: []string{"a", "b", "a", "c", "a", "b"}, replayed: []bool{false, false, true, false, true, false}},
+		{name: "by size of the responses", maxEntries: maxIdempotencyEntries, maxBytes: 10,
+			keys: []string{"a", "b", "a", "c", "a", "b"}, replayed: []bool{false, false, true, false, true, false}},
+		{name: "response larger than the room", maxEntries: maxIdempotencyEntries, maxBytes: 3,
+			keys: []string{"a", "a"}, replayed: []bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := NewIdempotency(time.Hour)
+			id.maxEntries = tt.maxEntries
+			id.maxBytes = tt.maxBytes
+			handler := id.MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("body"))
+			}))
+
+			for i, key := range tt.keys {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, idempotentRequest{key: key}.build())
+				if w.Code != http.StatusCreated {
+					t.Fatalf("request %d got %d, want 201", i, w.Code)
+				}
+				if got := w.Header().Get("Idempotent-Replayed") == "true"; got != tt.replayed[i] {
+					t.Fatalf("request %d with key %s replayed %v, want %v", i, key, got, tt.replayed[i])
+				}
+			}
+		})
+	}
+}
+
+// Keys of running requests cannot be evicted, so a new key has to wait
+func TestIdempotencyRefusesNewKeysWhenFull(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	id := NewIdempotency(time.Hour)
+	id.maxEntries = 1
+	handler := id.MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Idempotency-Key") == "a" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest{key: "a"}.build())
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, idempotentRequest{key: "b"}.build())
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("got %d with Retry-After %q, want 503 with one", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	<-done
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, idempotentRequest{key: "b"}.build())
+	if w.Code != http.StatusCreated {
+		t.Fatalf("got %d once the first request finished, want 201", w.Code)
+	}
+}
@@ -0,0 +1,33 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func academicYearsRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Academic year routers
+	mux.HandleFunc("GET /academic_years/", handlers.GetAcademicYearsHandler)
+	mux.HandleFunc("POST /academic_years/", handlers.AddAcademicYearsHandler)
+
+	mux.HandleFunc("PUT /academic_years/{id}", handlers.UpdateAcademicYearHandler)
+	mux.HandleFunc("GET /academic_years/{id}", handlers.GetOneAcademicYearHandler)
+	mux.HandleFunc("DELETE /academic_years/{id}", handlers.DeleteOneAcademicYearHandler)
+
+	mux.HandleFunc("GET /academic_years/{id}/terms", handlers.GetTermsHandler)
+
+	// Term routers
+	mux.HandleFunc("GET /terms/", handlers.GetTermsHandler)
+	mux.HandleFunc("POST /terms/", handlers.AddTermsHandler)
+
+	mux.HandleFunc("PUT /terms/{id}", handlers.UpdateTermHandler)
+	mux.HandleFunc("GET /terms/{id}", handlers.GetOneTermHandler)
+	mux.HandleFunc("DELETE /terms/{id}", handlers.DeleteOneTermHandler)
+
+	// Promotion routers
+	mux.HandleFunc("POST /promotions", handlers.PromoteClassHandler)
+
+	return mux
+}
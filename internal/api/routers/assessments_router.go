@@ -0,0 +1,23 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func assessmentsRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Assessment routers
+	mux.HandleFunc("GET /assessments/", handlers.GetAssessmentsHandler)
+	mux.HandleFunc("POST /assessments/", handlers.AddAssessmentsHandler)
+
+	mux.HandleFunc("PUT /assessments/{id}", handlers.UpdateAssessmentHandler)
+	mux.HandleFunc("GET /assessments/{id}", handlers.GetOneAssessmentHandler)
+	mux.HandleFunc("PATCH /assessments/{id}", handlers.PatchOneAssessmentHandler)
+	mux.HandleFunc("DELETE /assessments/{id}", handlers.DeleteOneAssessmentHandler)
+
+	mux.HandleFunc("POST /assessments/{id}/scores", handlers.AddScoresHandler)
+
+	return mux
+}
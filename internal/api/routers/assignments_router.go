@@ -0,0 +1,28 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func assignmentsRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Homework assignment routers
+	mux.HandleFunc("GET /assignments/", handlers.GetAssignmentsHandler)
+	mux.HandleFunc("POST /assignments/", handlers.AddAssignmentsHandler)
+
+	mux.HandleFunc("PUT /assignments/{id}", handlers.UpdateAssignmentHandler)
+	mux.HandleFunc("GET /assignments/{id}", handlers.GetOneAssignmentHandler)
+	mux.HandleFunc("DELETE /assignments/{id}", handlers.DeleteOneAssignmentHandler)
+
+	mux.HandleFunc("GET /assignments/{id}/submissions", handlers.GetSubmissionsByAssignmentId)
+	mux.HandleFunc("POST /assignments/{id}/submissions", handlers.AddSubmissionHandler)
+
+	// Submission routers
+	mux.HandleFunc("GET /submissions/{id}", handlers.GetOneSubmissionHandler)
+	mux.HandleFunc("PATCH /submissions/{id}", handlers.GradeSubmissionHandler)
+	mux.HandleFunc("GET /submissions/{id}/file", handlers.DownloadSubmissionHandler)
+
+	return mux
+}
@@ -0,0 +1,17 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func attendanceRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Attendance routers
+	mux.HandleFunc("GET /attendance/", handlers.GetAttendanceHandler)
+	mux.HandleFunc("GET /attendance/summary", handlers.GetAttendanceSummaryHandler)
+	mux.HandleFunc("DELETE /attendance/{id}", handlers.DeleteOneAttendanceHandler)
+
+	return mux
+}
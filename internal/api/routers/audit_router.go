@@ -0,0 +1,15 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func auditRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Audit log routers
+	mux.HandleFunc("GET /audit", handlers.GetAuditLogHandler)
+
+	return mux
+}
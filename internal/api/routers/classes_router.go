@@ -0,0 +1,32 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func classesRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Class routers
+	mux.HandleFunc("GET /classes/", handlers.GetClassesHandler)
+	mux.HandleFunc("POST /classes/", handlers.AddClassesHandler)
+
+	mux.HandleFunc("PUT /classes/{id}", handlers.UpdateClassHandler)
+	mux.HandleFunc("GET /classes/{id}", handlers.GetOneClassHandler)
+	mux.HandleFunc("PATCH /classes/{id}", handlers.PatchOneClassHandler)
+	mux.HandleFunc("DELETE /classes/{id}", handlers.DeleteOneClassHandler)
+
+	mux.HandleFunc("GET /classes/{id}/students", handlers.GetStudentsByClassId)
+	mux.HandleFunc("GET /classes/{id}/teachers", handlers.GetTeachersByClassId)
+	mux.HandleFunc("GET /classes/{id}/gradebook", handlers.GetClassGradebookHandler)
+	mux.HandleFunc("POST /classes/{id}/attendance", handlers.SaveClassAttendanceHandler)
+	mux.HandleFunc("GET /classes/{id}/enrollments", handlers.GetEnrollmentsByClassId)
+	mux.HandleFunc("GET /classes/{id}/waitlist", handlers.GetWaitlistByClassId)
+	mux.HandleFunc("DELETE /classes/{id}/waitlist/{studentId}", handlers.DeleteWaitlistEntryHandler)
+	mux.HandleFunc("GET /classes/{id}/timetable", handlers.GetClassTimetableHandler)
+	mux.HandleFunc("GET /classes/{id}/timetable.ics", handlers.GetClassTimetableICSHandler)
+	mux.HandleFunc("POST /classes/{id}/reportcards", handlers.AddClassReportCardsJobHandler)
+
+	return mux
+}
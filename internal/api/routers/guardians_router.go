@@ -0,0 +1,20 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func guardiansRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Guardian routers
+	mux.HandleFunc("GET /guardians/", handlers.GetGuardiansHandler)
+	mux.HandleFunc("POST /guardians/", handlers.AddGuardiansHandler)
+
+	mux.HandleFunc("PUT /guardians/{id}", handlers.UpdateGuardianHandler)
+	mux.HandleFunc("GET /guardians/{id}", handlers.GetOneGuardianHandler)
+	mux.HandleFunc("DELETE /guardians/{id}", handlers.DeleteOneGuardianHandler)
+
+	return mux
+}
@@ -0,0 +1,15 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func importsRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// CSV import routers, the imports themselves are under /teachers/ and /students/
+	mux.HandleFunc("GET /imports/{id}/errors", handlers.GetImportErrorsHandler)
+
+	return mux
+}
@@ -0,0 +1,16 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func jobsRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Background job routers
+	mux.HandleFunc("GET /jobs/{id}", handlers.GetJobHandler)
+	mux.HandleFunc("GET /jobs/{id}/result", handlers.GetJobResultHandler)
+
+	return mux
+}
@@ -7,7 +7,31 @@ import (
 func MainRouter() *http.ServeMux {
 	tRouter := teachersRouter()
 	sRouter := studentsRouter()
+	cRouter := classesRouter()
+	subRouter := subjectsRouter()
+	aRouter := assessmentsRouter()
+	attRouter := attendanceRouter()
+	yRouter := academicYearsRouter()
+	ttRouter := timetableRouter()
+	gRouter := guardiansRouter()
+	hwRouter := assignmentsRouter()
+	jRouter := jobsRouter()
+	stRouter := statsRouter()
+	auRouter := auditRouter()
+	imRouter := importsRouter()
 
+	auRouter.Handle("/", imRouter)
+	stRouter.Handle("/", auRouter)
+	jRouter.Handle("/", stRouter)
+	hwRouter.Handle("/", jRouter)
+	gRouter.Handle("/", hwRouter)
+	ttRouter.Handle("/", gRouter)
+	yRouter.Handle("/", ttRouter)
+	attRouter.Handle("/", yRouter)
+	aRouter.Handle("/", attRouter)
+	subRouter.Handle("/", aRouter)
+	cRouter.Handle("/", subRouter)
+	sRouter.Handle("/", cRouter)
 	tRouter.Handle("/", sRouter)
 	return tRouter
 
@@ -0,0 +1,20 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func statsRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Statistics routers, all take group_by, from and to
+	mux.HandleFunc("GET /stats/students", handlers.GetStudentCountStatsHandler)
+	mux.HandleFunc("GET /stats/teachers", handlers.GetTeacherCountStatsHandler)
+	mux.HandleFunc("GET /stats/ratio", handlers.GetStudentTeacherRatioStatsHandler)
+	mux.HandleFunc("GET /stats/fillrates", handlers.GetClassFillRateStatsHandler)
+	mux.HandleFunc("GET /stats/attendance", handlers.GetAttendanceStatsHandler)
+	mux.HandleFunc("GET /stats/grades", handlers.GetGradeStatsHandler)
+
+	return mux
+}
@@ -11,13 +11,36 @@ func studentsRouter() *http.ServeMux {
 	// Student routers
 	mux.HandleFunc("GET /students/", handlers.GetStudentsHandler)
 	mux.HandleFunc("POST /students/", handlers.AddStudentHandler)
+	mux.HandleFunc("PUT /students/", handlers.UpsertStudentsHandler)
 	mux.HandleFunc("PATCH /students/", handlers.PatchStudentsHandler)
 	mux.HandleFunc("DELETE /students/", handlers.DeleteStudentsHandler)
+	mux.HandleFunc("POST /students/import", handlers.ImportStudentsHandler)
 
 	mux.HandleFunc("PUT /students/{id}", handlers.UpdateStudentsHandler)
 	mux.HandleFunc("GET /students/{id}", handlers.GetOneStudentHandler)
 	mux.HandleFunc("PATCH /students/{id}", handlers.PatchOneStudentHandler)
 	mux.HandleFunc("DELETE /students/{id}", handlers.DeleteOneStudentHandler)
+	mux.HandleFunc("POST /students/{id}/restore", handlers.RestoreStudentHandler)
+	mux.HandleFunc("GET /students/{id}/versions", handlers.GetStudentVersionsHandler)
+	mux.HandleFunc("GET /students/{id}/versions/diff", handlers.GetStudentVersionDiffHandler)
+
+	mux.HandleFunc("GET /students/{id}/grades", handlers.GetStudentGradesHandler)
+	mux.HandleFunc("GET /students/{id}/attendance/summary", handlers.GetStudentAttendanceSummaryHandler)
+
+	mux.HandleFunc("POST /students/{id}/transfer", handlers.TransferStudentHandler)
+	mux.HandleFunc("GET /students/{id}/history", handlers.GetStudentHistoryHandler)
+
+	mux.HandleFunc("GET /students/{id}/reportcard", handlers.GetReportCardHandler)
+	mux.HandleFunc("PUT /students/{id}/reportcard/comments", handlers.SaveReportCommentHandler)
+
+	mux.HandleFunc("PUT /students/{id}/photo", handlers.PutStudentPhotoHandler)
+	mux.HandleFunc("GET /students/{id}/photo", handlers.GetStudentPhotoHandler)
+	mux.HandleFunc("GET /students/{id}/photo/thumbnail", handlers.GetStudentThumbnailHandler)
+
+	mux.HandleFunc("GET /students/{id}/guardians", handlers.GetGuardiansByStudentId)
+	mux.HandleFunc("POST /students/{id}/guardians", handlers.SaveStudentGuardianHandler)
+	mux.HandleFunc("PUT /students/{id}/guardians/{guardianId}", handlers.SaveStudentGuardianHandler)
+	mux.HandleFunc("DELETE /students/{id}/guardians/{guardianId}", handlers.DeleteStudentGuardianHandler)
 
 	return mux
 }
@@ -0,0 +1,30 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func subjectsRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Subject routers
+	mux.HandleFunc("GET /subjects/", handlers.GetSubjectsHandler)
+	mux.HandleFunc("POST /subjects/", handlers.AddSubjectsHandler)
+
+	mux.HandleFunc("PUT /subjects/{id}", handlers.UpdateSubjectHandler)
+	mux.HandleFunc("GET /subjects/{id}", handlers.GetOneSubjectHandler)
+	mux.HandleFunc("PATCH /subjects/{id}", handlers.PatchOneSubjectHandler)
+	mux.HandleFunc("DELETE /subjects/{id}", handlers.DeleteOneSubjectHandler)
+
+	// Teaching assignment routers
+	mux.HandleFunc("GET /teaching_assignments/", handlers.GetTeachingAssignmentsHandler)
+	mux.HandleFunc("POST /teaching_assignments/", handlers.AddTeachingAssignmentsHandler)
+
+	mux.HandleFunc("PUT /teaching_assignments/{id}", handlers.UpdateTeachingAssignmentHandler)
+	mux.HandleFunc("GET /teaching_assignments/{id}", handlers.GetOneTeachingAssignmentHandler)
+	mux.HandleFunc("PATCH /teaching_assignments/{id}", handlers.PatchOneTeachingAssignmentHandler)
+	mux.HandleFunc("DELETE /teaching_assignments/{id}", handlers.DeleteOneTeachingAssignmentHandler)
+
+	return mux
+}
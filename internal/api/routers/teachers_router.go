@@ -12,15 +12,27 @@ func teachersRouter() *http.ServeMux {
 	// Teacher routers
 	mux.HandleFunc("GET /teachers/", handlers.GetTeachersHandler)
 	mux.HandleFunc("POST /teachers/", handlers.AddTeachersHandler)
+	mux.HandleFunc("PUT /teachers/", handlers.UpsertTeachersHandler)
 	mux.HandleFunc("PATCH /teachers/", handlers.PatchTeachersHandler)
 	mux.HandleFunc("DELETE /teachers/", handlers.DeleteTeachersHandler)
+	mux.HandleFunc("POST /teachers/import", handlers.ImportTeachersHandler)
 
 	mux.HandleFunc("PUT /teachers/{id}", handlers.UpdateTeachersHandler)
 	mux.HandleFunc("GET /teachers/{id}", handlers.GetOneTeacherHandler)
 	mux.HandleFunc("PATCH /teachers/{id}", handlers.PatchOneTeacherHandler)
 	mux.HandleFunc("DELETE /teachers/{id}", handlers.DeleteOneTeacherHandler)
+	mux.HandleFunc("POST /teachers/{id}/restore", handlers.RestoreTeacherHandler)
+	mux.HandleFunc("GET /teachers/{id}/versions", handlers.GetTeacherVersionsHandler)
+	mux.HandleFunc("GET /teachers/{id}/versions/diff", handlers.GetTeacherVersionDiffHandler)
+
+	mux.HandleFunc("PUT /teachers/{id}/photo", handlers.PutTeacherPhotoHandler)
+	mux.HandleFunc("GET /teachers/{id}/photo", handlers.GetTeacherPhotoHandler)
+	mux.HandleFunc("GET /teachers/{id}/photo/thumbnail", handlers.GetTeacherThumbnailHandler)
 
 	mux.HandleFunc("GET /teachers/{id}/students", handlers.GetStudentsByTeacherId)
+	mux.HandleFunc("GET /teachers/{id}/classes", handlers.GetClassesByTeacherId)
+	mux.HandleFunc("GET /teachers/{id}/timetable", handlers.GetTeacherTimetableHandler)
+	mux.HandleFunc("GET /teachers/{id}/timetable.ics", handlers.GetTeacherTimetableICSHandler)
 	mux.HandleFunc("GET /teachers/{id}/studentcount", handlers.GetStudentCountByTeacherId)
 
 	return mux
@@ -0,0 +1,20 @@
+package routers
+
+import (
+	"net/http"
+	"restapi/internal/api/handlers"
+)
+
+func timetableRouter() *http.ServeMux {
+
+	mux := http.NewServeMux()
+	// Timetable routers
+	mux.HandleFunc("GET /timetable/", handlers.GetTimetableSlotsHandler)
+	mux.HandleFunc("POST /timetable/", handlers.AddTimetableSlotsHandler)
+
+	mux.HandleFunc("PUT /timetable/{id}", handlers.UpdateTimetableSlotHandler)
+	mux.HandleFunc("GET /timetable/{id}", handlers.GetOneTimetableSlotHandler)
+	mux.HandleFunc("DELETE /timetable/{id}", handlers.DeleteOneTimetableSlotHandler)
+
+	return mux
+}
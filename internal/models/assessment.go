@@ -0,0 +1,46 @@
+package models
+
+type Assessment struct {
+	ID        int     `json:"id,omitempty" db:"id,omitempty"`
+	ClassID   int     `json:"class_id,omitempty" db:"class_id,omitempty"`
+	SubjectID int     `json:"subject_id,omitempty" db:"subject_id,omitempty"`
+	Title     string  `json:"title,omitempty" db:"title,omitempty"`
+	MaxScore  float64 `json:"max_score,omitempty" db:"max_score,omitempty"`
+	Weight    float64 `json:"weight,omitempty" db:"weight,omitempty"`
+	DueDate   string  `json:"due_date,omitempty" db:"due_date,omitempty"`
+	TermID    int     `json:"term_id,omitempty" db:"term_id,omitempty"`
+}
+
+type Score struct {
+	ID           int     `json:"id,omitempty" db:"id,omitempty"`
+	AssessmentID int     `json:"assessment_id,omitempty" db:"assessment_id,omitempty"`
+	StudentID    int     `json:"student_id,omitempty" db:"student_id,omitempty"`
+	Score        float64 `json:"score" db:"score"`
+}
+
+// A student's score together with the assessment it was given for
+type StudentGrade struct {
+	Assessment Assessment `json:"assessment"`
+	Score      float64    `json:"score"`
+}
+
+// Weighted average of a student's scores in one subject and term, as a
+// percentage
+type SubjectAverage struct {
+	SubjectID       int     `json:"subject_id"`
+	TermID          int     `json:"term_id"`
+	WeightedAverage float64 `json:"weighted_average"`
+}
+
+// Student x assessment matrix. Scores[i] lines up with Assessments[i] and is
+// nil when the student has no score for it yet.
+type Gradebook struct {
+	ClassID     int            `json:"class_id"`
+	Assessments []Assessment   `json:"assessments"`
+	Rows        []GradebookRow `json:"rows"`
+}
+
+type GradebookRow struct {
+	Student Student    `json:"student"`
+	Scores  []*float64 `json:"scores"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// Homework posted to a class
+type Assignment struct {
+	ID          int    `json:"id,omitempty" db:"id,omitempty"`
+	ClassID     int    `json:"class_id,omitempty" db:"class_id,omitempty"`
+	SubjectID   int    `json:"subject_id,omitempty" db:"subject_id,omitempty"`
+	Title       string `json:"title,omitempty" db:"title,omitempty"`
+	Description string `json:"description,omitempty" db:"description,omitempty"`
+	DueDate     string `json:"due_date,omitempty" db:"due_date,omitempty"`
+}
+
+// A student's hand-in for an assignment. Grade stays null until the teacher
+// has marked it. DownloadURL is a signed link to the file that expires.
+type Submission struct {
+	ID           int      `json:"id,omitempty" db:"id,omitempty"`
+	AssignmentID int      `json:"assignment_id,omitempty" db:"assignment_id,omitempty"`
+	StudentID    int      `json:"student_id,omitempty" db:"student_id,omitempty"`
+	SubmittedAt  string   `json:"submitted_at,omitempty" db:"submitted_at,omitempty"`
+	Late         bool     `json:"late" db:"late"`
+	Grade        *float64 `json:"grade" db:"grade"`
+	Feedback     string   `json:"feedback" db:"feedback"`
+	FileKey      string   `json:"-" db:"file_key"`
+	FileName     string   `json:"file_name,omitempty" db:"file_name,omitempty"`
+	ContentType  string   `json:"content_type,omitempty" db:"content_type,omitempty"`
+	Size         int64    `json:"size,omitempty" db:"size,omitempty"`
+	DownloadURL  string   `json:"download_url,omitempty" db:"-"`
+}
+
+// Body of PATCH /submissions/{id}
+type SubmissionGrade struct {
+	Grade    *float64 `json:"grade"`
+	Feedback *string  `json:"feedback"`
+}
@@ -0,0 +1,31 @@
+package models
+
+type Attendance struct {
+	ID         int    `json:"id,omitempty" db:"id,omitempty"`
+	StudentID  int    `json:"student_id,omitempty" db:"student_id,omitempty"`
+	Date       string `json:"date,omitempty" db:"date,omitempty"`
+	Period     int    `json:"period" db:"period"`
+	Status     string `json:"status,omitempty" db:"status,omitempty"`
+	Note       string `json:"note,omitempty" db:"note,omitempty"`
+	RecordedBy int    `json:"recorded_by,omitempty" db:"recorded_by,omitempty"`
+}
+
+// Register for a whole class on one date and period
+type AttendanceRegister struct {
+	Date       string       `json:"date"`
+	Period     int          `json:"period"`
+	RecordedBy int          `json:"recorded_by"`
+	Records    []Attendance `json:"records"`
+}
+
+// Attendance of one student over a date range. Excused records do not count
+// against the percentage and late counts as attended.
+type AttendanceSummary struct {
+	StudentID  int     `json:"student_id"`
+	Present    int     `json:"present"`
+	Absent     int     `json:"absent"`
+	Late       int     `json:"late"`
+	Excused    int     `json:"excused"`
+	Total      int     `json:"total"`
+	Percentage float64 `json:"percentage"`
+}
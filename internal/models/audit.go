@@ -0,0 +1,25 @@
+package models
+
+import "encoding/json"
+
+// One change to an entity. Before and After hold the changed fields, Before
+// is null on create and After is null on delete.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  int             `json:"entity_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	RequestID string          `json:"request_id"`
+	IP        string          `json:"ip"`
+	CreatedAt string          `json:"created_at"`
+}
+
+// Who made the request behind a change, written with every audit entry
+type AuditInfo struct {
+	Actor     string
+	RequestID string
+	IP        string
+}
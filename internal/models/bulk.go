@@ -0,0 +1,42 @@
+package models
+
+import "encoding/json"
+
+// The outcome of one item of a bulk request. Index is its position in the
+// request and Action what was done with it. Err is set by sqlconnect when
+// the item failed, the handler turns it into Status and Error.
+type BulkItemResult struct {
+	Index    int            `json:"index"`
+	Status   int            `json:"status"`
+	ID       int            `json:"id,omitempty"`
+	Action   string         `json:"action,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Data     interface{}    `json:"data,omitempty"`
+	Waitlist *WaitlistEntry `json:"waitlist,omitempty"`
+	Err      error          `json:"-"`
+}
+
+// Actions of a BulkItemResult
+const (
+	BulkCreated   = "created"
+	BulkUpdated   = "updated"
+	BulkUnchanged = "unchanged"
+	BulkDeleted   = "deleted"
+)
+
+// An item of a bulk DELETE. It is either the id alone or an object such as
+// {"id": 3, "version": 2}, where version is the one the delete is based on.
+type BulkDelete struct {
+	ID      int `json:"id"`
+	Version int `json:"version,omitempty"`
+}
+
+func (d *BulkDelete) UnmarshalJSON(b []byte) error {
+	var id int
+	if json.Unmarshal(b, &id) == nil {
+		*d = BulkDelete{ID: id}
+		return nil
+	}
+	type bulkDelete BulkDelete
+	return json.Unmarshal(b, (*bulkDelete)(d))
+}
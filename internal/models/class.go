@@ -0,0 +1,12 @@
+package models
+
+type Class struct {
+	ID                int    `json:"id,omitempty" db:"id,omitempty"`
+	Name              string `json:"name,omitempty" db:"name,omitempty"`
+	Grade             int    `json:"grade,omitempty" db:"grade,omitempty"`
+	Section           string `json:"section,omitempty" db:"section,omitempty"`
+	Room              string `json:"room,omitempty" db:"room,omitempty"`
+	Capacity          int    `json:"capacity,omitempty" db:"capacity,omitempty"`
+	HomeroomTeacherID int    `json:"homeroom_teacher_id,omitempty" db:"homeroom_teacher_id,omitempty"`
+	AcademicYearID    int    `json:"academic_year_id,omitempty" db:"academic_year_id,omitempty"`
+}
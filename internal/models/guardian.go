@@ -0,0 +1,23 @@
+package models
+
+type Guardian struct {
+	ID    int    `json:"id,omitempty" db:"id,omitempty"`
+	Name  string `json:"name,omitempty" db:"name,omitempty"`
+	Phone string `json:"phone,omitempty" db:"phone,omitempty"`
+	Email string `json:"email,omitempty" db:"email,omitempty"`
+}
+
+// Link between a student and one of their guardians
+type StudentGuardian struct {
+	StudentID    int    `json:"student_id,omitempty" db:"student_id,omitempty"`
+	GuardianID   int    `json:"guardian_id,omitempty" db:"guardian_id,omitempty"`
+	Relationship string `json:"relationship,omitempty" db:"relationship,omitempty"`
+	IsPrimary    bool   `json:"is_primary" db:"is_primary"`
+}
+
+// A guardian as listed for one student
+type StudentContact struct {
+	Guardian
+	Relationship string `json:"relationship,omitempty"`
+	IsPrimary    bool   `json:"is_primary"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// Profile photo of a student or teacher. The thumbnail is always a JPEG.
+type Photo struct {
+	OwnerID      int    `json:"owner_id"`
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	UpdatedAt    string `json:"updated_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// A student's membership of a class. EndedOn is empty for the current class,
+// FromClassID is 0 for a student's first class.
+type Enrollment struct {
+	ID          int    `json:"id,omitempty" db:"id,omitempty"`
+	StudentID   int    `json:"student_id,omitempty" db:"student_id,omitempty"`
+	ClassID     int    `json:"class_id,omitempty" db:"class_id,omitempty"`
+	StartedOn   string `json:"started_on,omitempty" db:"started_on,omitempty"`
+	EndedOn     string `json:"ended_on,omitempty" db:"ended_on,omitempty"`
+	FromClassID int    `json:"from_class_id,omitempty" db:"from_class_id,omitempty"`
+	Reason      string `json:"reason,omitempty" db:"reason,omitempty"`
+	Actor       string `json:"actor,omitempty" db:"actor,omitempty"`
+}
+
+type PromotionRequest struct {
+	FromClassID      int   `json:"from_class_id"`
+	ToAcademicYearID int   `json:"to_academic_year_id"`
+	ToClassID        int   `json:"to_class_id,omitempty"`
+	Exclude          []int `json:"exclude,omitempty"`
+	DryRun           bool  `json:"dry_run"`
+}
+
+// Students that did not fit into the class they were promoted into stay in
+// their old class and are on the new class's waitlist.
+type PromotionResult struct {
+	DryRun       bool            `json:"dry_run"`
+	FromClass    Class           `json:"from_class"`
+	ToClass      Class           `json:"to_class"`
+	CreatedClass bool            `json:"created_class"`
+	Promoted     []Student       `json:"promoted"`
+	Waitlisted   []WaitlistEntry `json:"waitlisted"`
+	HeldBack     []Student       `json:"held_back"`
+}
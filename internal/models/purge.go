@@ -0,0 +1,8 @@
+package models
+
+// What one run of the purge of soft deleted rows removed
+type PurgeResult struct {
+	Teachers int64    `json:"teachers"`
+	Students int64    `json:"students"`
+	BlobKeys []string `json:"-"`
+}
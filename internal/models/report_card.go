@@ -0,0 +1,33 @@
+package models
+
+type ReportComment struct {
+	ID        int    `json:"id,omitempty" db:"id,omitempty"`
+	StudentID int    `json:"student_id,omitempty" db:"student_id,omitempty"`
+	TeacherID int    `json:"teacher_id,omitempty" db:"teacher_id,omitempty"`
+	TermID    int    `json:"term_id,omitempty" db:"term_id,omitempty"`
+	Comment   string `json:"comment,omitempty" db:"comment,omitempty"`
+}
+
+// Everything printed on a student's report card for one term
+type ReportCard struct {
+	Student    Student             `json:"student"`
+	ClassName  string              `json:"class_name"`
+	TermID     int                 `json:"term_id"`
+	Term       string              `json:"term"`
+	Subjects   []ReportCardSubject `json:"subjects"`
+	Attendance AttendanceSummary   `json:"attendance"`
+	Comments   []ReportCardComment `json:"comments"`
+}
+
+// Weighted average in a subject as a percentage, over Assessments scores
+type ReportCardSubject struct {
+	Subject     string  `json:"subject"`
+	Assessments int     `json:"assessments"`
+	Average     float64 `json:"average"`
+}
+
+type ReportCardComment struct {
+	Teacher string `json:"teacher"`
+	Subject string `json:"subject"`
+	Comment string `json:"comment"`
+}
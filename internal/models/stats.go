@@ -0,0 +1,59 @@
+package models
+
+// Every statistic is a list of rows, one per value of the group_by
+// dimension. Group holds that value, e.g. the class name or "2024-03".
+
+type StudentCountStat struct {
+	Group    string `json:"group"`
+	Students int    `json:"students"`
+}
+
+type TeacherCountStat struct {
+	Group    string `json:"group"`
+	Teachers int    `json:"teachers"`
+}
+
+// Ratio is null when no teacher is assigned to the group
+type RatioStat struct {
+	Group    string   `json:"group"`
+	Students int      `json:"students"`
+	Teachers int      `json:"teachers"`
+	Ratio    *float64 `json:"ratio"`
+}
+
+// Classes without a capacity take any number of students and are left out
+// of the fill rate, which is null when no class in the group has a capacity
+type FillRateStat struct {
+	Group      string   `json:"group"`
+	Classes    int      `json:"classes"`
+	Students   int      `json:"students"`
+	Capacity   int      `json:"capacity"`
+	Waitlisted int      `json:"waitlisted"`
+	FillRate   *float64 `json:"fill_rate"`
+}
+
+// Counted the same way as AttendanceSummary
+type AttendanceStat struct {
+	Group      string  `json:"group"`
+	Present    int     `json:"present"`
+	Absent     int     `json:"absent"`
+	Late       int     `json:"late"`
+	Excused    int     `json:"excused"`
+	Total      int     `json:"total"`
+	Percentage float64 `json:"percentage"`
+}
+
+// Average is the weighted score in percent, as on the report card
+type GradeStat struct {
+	Group   string   `json:"group"`
+	Scores  int      `json:"scores"`
+	Average *float64 `json:"average"`
+}
+
+// The parameters every statistic takes. From and To are YYYY-MM-DD and
+// empty when not given.
+type StatsFilter struct {
+	GroupBy string `json:"group_by"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+}
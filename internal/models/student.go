@@ -1,9 +1,15 @@
 package models
 
 type Student struct {
-	ID        int    `json:"id,omitempty" db:"id,omitempty`
+	ID        int    `json:"id,omitempty" db:"id,omitempty"`
 	FirstName string `json:"first_name,omitempty" db:"first_name,omitempty"`
 	LastName  string `json:"last_name,omitempty" db:"last_name,omitempty"`
 	Email     string `json:"email,omitempty" db:"email,omitempty"`
-	Class     string `json:"class,omitempty" db:"class,omitempty"`
+	ClassID   int    `json:"class_id,omitempty" db:"class_id,omitempty"`
+	// None of these has a db tag, so the generated queries never write them.
+	// DeletedAt is only set on deleted rows, Version goes up on every change
+	// and UpdatedAt is when the last one happened.
+	DeletedAt *string `json:"deleted_at,omitempty"`
+	Version   int     `json:"version,omitempty"`
+	UpdatedAt string  `json:"updated_at,omitempty"`
 }
@@ -0,0 +1,7 @@
+package models
+
+type Subject struct {
+	ID   int    `json:"id,omitempty" db:"id,omitempty"`
+	Name string `json:"name,omitempty" db:"name,omitempty"`
+	Code string `json:"code,omitempty" db:"code,omitempty"`
+}
@@ -0,0 +1,9 @@
+package models
+
+type TeachingAssignment struct {
+	ID        int `json:"id,omitempty" db:"id,omitempty"`
+	TeacherID int `json:"teacher_id,omitempty" db:"teacher_id,omitempty"`
+	ClassID   int `json:"class_id,omitempty" db:"class_id,omitempty"`
+	SubjectID int `json:"subject_id,omitempty" db:"subject_id,omitempty"`
+	TermID    int `json:"term_id,omitempty" db:"term_id,omitempty"`
+}
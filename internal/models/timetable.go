@@ -0,0 +1,24 @@
+package models
+
+// Weekday is ISO, 1 is Monday and 7 is Sunday. Times are HH:MM.
+type TimetableSlot struct {
+	ID        int    `json:"id,omitempty" db:"id,omitempty"`
+	ClassID   int    `json:"class_id,omitempty" db:"class_id,omitempty"`
+	SubjectID int    `json:"subject_id,omitempty" db:"subject_id,omitempty"`
+	TeacherID int    `json:"teacher_id,omitempty" db:"teacher_id,omitempty"`
+	Room      string `json:"room,omitempty" db:"room,omitempty"`
+	Weekday   int    `json:"weekday,omitempty" db:"weekday,omitempty"`
+	StartTime string `json:"start_time,omitempty" db:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty" db:"end_time,omitempty"`
+	TermID    int    `json:"term_id,omitempty" db:"term_id,omitempty"`
+}
+
+// A slot with the names and term dates needed to show or export it
+type TimetableEntry struct {
+	TimetableSlot
+	ClassName     string `json:"class_name"`
+	SubjectName   string `json:"subject_name"`
+	TeacherName   string `json:"teacher_name"`
+	TermStartDate string `json:"term_start_date"`
+	TermEndDate   string `json:"term_end_date"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// Body of POST /students/{id}/transfer. FromClassID must be the student's
+// current class, so two people cannot transfer the same student at once.
+type TransferRequest struct {
+	FromClassID   int    `json:"from_class_id"`
+	ToClassID     int    `json:"to_class_id"`
+	EffectiveDate string `json:"effective_date"`
+	Reason        string `json:"reason"`
+}
+
+// One class in a student's history
+type EnrollmentHistory struct {
+	Enrollment
+	ClassName     string `json:"class_name"`
+	FromClassName string `json:"from_class_name,omitempty"`
+}
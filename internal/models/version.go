@@ -0,0 +1,19 @@
+package models
+
+// One stored state of a row. Version is the row's version column at the
+// time, the one its ETag was made from. ValidTo is null for the state the
+// row is in now.
+type RecordVersion struct {
+	Version   int         `json:"version"`
+	ValidFrom string      `json:"valid_from"`
+	ValidTo   *string     `json:"valid_to"`
+	Data      interface{} `json:"data"`
+}
+
+// The fields that differ between two versions of a row
+type VersionDiff struct {
+	From   int                    `json:"from"`
+	To     int                    `json:"to"`
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+}
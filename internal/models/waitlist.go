@@ -0,0 +1,11 @@
+package models
+
+// A student waiting for a seat in a full class. Position 1 gets the next
+// free seat.
+type WaitlistEntry struct {
+	ID        int    `json:"id"`
+	ClassID   int    `json:"class_id"`
+	StudentID int    `json:"student_id"`
+	Position  int    `json:"position"`
+	QueuedAt  string `json:"queued_at"`
+}
@@ -0,0 +1,45 @@
+-- Classes become a first-class entity. Students reference a class by id and
+-- teachers own classes as homeroom teachers, replacing the free-text `class`
+-- columns on both tables.
+
+CREATE TABLE IF NOT EXISTS classes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    grade INT NOT NULL,
+    section VARCHAR(10) NOT NULL,
+    room VARCHAR(50) NOT NULL DEFAULT '',
+    capacity INT NOT NULL DEFAULT 30,
+    homeroom_teacher_id INT NULL,
+    UNIQUE KEY uq_classes_name (name),
+    CONSTRAINT fk_classes_homeroom_teacher FOREIGN KEY (homeroom_teacher_id) REFERENCES teachers (id) ON DELETE SET NULL
+);
+
+-- Backfill one class per distinct string found on students and teachers.
+-- "9A" becomes grade 9, section "A".
+INSERT IGNORE INTO classes (name, grade, section)
+SELECT DISTINCT TRIM(class),
+       CAST(COALESCE(NULLIF(REGEXP_SUBSTR(TRIM(class), '^[0-9]+'), ''), '0') AS UNSIGNED),
+       REGEXP_REPLACE(TRIM(class), '^[0-9]+', '')
+FROM (
+    SELECT class FROM students
+    UNION
+    SELECT class FROM teachers
+) AS existing
+WHERE class IS NOT NULL AND TRIM(class) <> '';
+
+UPDATE classes c
+JOIN teachers t ON TRIM(t.class) = c.name
+SET c.homeroom_teacher_id = t.id
+WHERE c.homeroom_teacher_id IS NULL;
+
+ALTER TABLE students ADD COLUMN class_id INT NULL AFTER email;
+
+UPDATE students s
+JOIN classes c ON TRIM(s.class) = c.name
+SET s.class_id = c.id;
+
+ALTER TABLE students
+    ADD CONSTRAINT fk_students_class FOREIGN KEY (class_id) REFERENCES classes (id) ON DELETE SET NULL;
+
+ALTER TABLE students DROP COLUMN class;
+ALTER TABLE teachers DROP COLUMN class;
@@ -0,0 +1,35 @@
+-- Subjects and the teacher/class/subject/term join that replaces the single
+-- `subject` string on teachers as the source of who teaches what, and where.
+
+CREATE TABLE IF NOT EXISTS subjects (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    code VARCHAR(20) NOT NULL DEFAULT '',
+    UNIQUE KEY uq_subjects_name (name)
+);
+
+CREATE TABLE IF NOT EXISTS teaching_assignments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    teacher_id INT NOT NULL,
+    class_id INT NOT NULL,
+    subject_id INT NOT NULL,
+    term VARCHAR(20) NOT NULL DEFAULT '',
+    -- A subject is taught to a class by one teacher per term
+    UNIQUE KEY uq_teaching_assignments_class_subject_term (class_id, subject_id, term),
+    KEY idx_teaching_assignments_teacher (teacher_id),
+    CONSTRAINT fk_teaching_assignments_teacher FOREIGN KEY (teacher_id) REFERENCES teachers (id) ON DELETE CASCADE,
+    CONSTRAINT fk_teaching_assignments_class FOREIGN KEY (class_id) REFERENCES classes (id) ON DELETE CASCADE,
+    CONSTRAINT fk_teaching_assignments_subject FOREIGN KEY (subject_id) REFERENCES subjects (id) ON DELETE CASCADE
+);
+
+-- Backfill subjects from teachers and assign each teacher their subject in
+-- their homeroom classes.
+INSERT IGNORE INTO subjects (name)
+SELECT DISTINCT TRIM(subject) FROM teachers
+WHERE subject IS NOT NULL AND TRIM(subject) <> '';
+
+INSERT IGNORE INTO teaching_assignments (teacher_id, class_id, subject_id)
+SELECT t.id, c.id, s.id
+FROM teachers t
+JOIN classes c ON c.homeroom_teacher_id = t.id
+JOIN subjects s ON s.name = TRIM(t.subject);
@@ -0,0 +1,26 @@
+-- Assessments given to a class in a subject, and the score each student got.
+
+CREATE TABLE IF NOT EXISTS assessments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    class_id INT NOT NULL,
+    subject_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    max_score DECIMAL(8,2) NOT NULL,
+    weight DECIMAL(6,2) NOT NULL DEFAULT 1,
+    due_date DATE NOT NULL,
+    term VARCHAR(20) NOT NULL DEFAULT '',
+    KEY idx_assessments_class_subject (class_id, subject_id),
+    CONSTRAINT fk_assessments_class FOREIGN KEY (class_id) REFERENCES classes (id) ON DELETE CASCADE,
+    CONSTRAINT fk_assessments_subject FOREIGN KEY (subject_id) REFERENCES subjects (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS scores (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    assessment_id INT NOT NULL,
+    student_id INT NOT NULL,
+    score DECIMAL(8,2) NOT NULL,
+    UNIQUE KEY uq_scores_assessment_student (assessment_id, student_id),
+    KEY idx_scores_student (student_id),
+    CONSTRAINT fk_scores_assessment FOREIGN KEY (assessment_id) REFERENCES assessments (id) ON DELETE CASCADE,
+    CONSTRAINT fk_scores_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE
+);
@@ -0,0 +1,17 @@
+-- Attendance registers. Period 0 is the daily register, 1..n are lessons.
+-- The unique key makes re-submitting a register update it instead of
+-- duplicating it.
+
+CREATE TABLE IF NOT EXISTS attendance (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    student_id INT NOT NULL,
+    date DATE NOT NULL,
+    period INT NOT NULL DEFAULT 0,
+    status ENUM('present', 'absent', 'late', 'excused') NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    recorded_by INT NULL,
+    UNIQUE KEY uq_attendance_student_date_period (student_id, date, period),
+    KEY idx_attendance_date (date),
+    CONSTRAINT fk_attendance_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_attendance_recorded_by FOREIGN KEY (recorded_by) REFERENCES teachers (id) ON DELETE SET NULL
+);
@@ -0,0 +1,43 @@
+-- School years and their terms, classes tied to a year, and an enrollment
+-- history so a student's earlier classes stay queryable after promotion.
+
+CREATE TABLE IF NOT EXISTS academic_years (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(20) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    UNIQUE KEY uq_academic_years_name (name)
+);
+
+CREATE TABLE IF NOT EXISTS terms (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    academic_year_id INT NOT NULL,
+    name VARCHAR(20) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    UNIQUE KEY uq_terms_year_name (academic_year_id, name),
+    CONSTRAINT fk_terms_academic_year FOREIGN KEY (academic_year_id) REFERENCES academic_years (id) ON DELETE CASCADE
+);
+
+-- The same class name comes back every year
+ALTER TABLE classes
+    ADD COLUMN academic_year_id INT NULL,
+    DROP INDEX uq_classes_name,
+    ADD UNIQUE KEY uq_classes_name_year (name, academic_year_id),
+    ADD CONSTRAINT fk_classes_academic_year FOREIGN KEY (academic_year_id) REFERENCES academic_years (id) ON DELETE SET NULL;
+
+-- ended_on is NULL for the class a student is in now
+CREATE TABLE IF NOT EXISTS enrollments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    student_id INT NOT NULL,
+    class_id INT NOT NULL,
+    started_on DATE NOT NULL,
+    ended_on DATE NULL,
+    KEY idx_enrollments_student (student_id),
+    KEY idx_enrollments_class (class_id),
+    CONSTRAINT fk_enrollments_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_enrollments_class FOREIGN KEY (class_id) REFERENCES classes (id) ON DELETE CASCADE
+);
+
+INSERT INTO enrollments (student_id, class_id, started_on)
+SELECT id, class_id, CURDATE() FROM students WHERE class_id IS NOT NULL;
@@ -0,0 +1,22 @@
+-- Weekly timetable. weekday is ISO (1 = Monday ... 7 = Sunday). Double
+-- booking of a teacher, class or room is rejected by the api since
+-- overlapping time ranges cannot be expressed as a unique key.
+
+CREATE TABLE IF NOT EXISTS timetable_slots (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    class_id INT NOT NULL,
+    subject_id INT NOT NULL,
+    teacher_id INT NOT NULL,
+    room VARCHAR(50) NOT NULL DEFAULT '',
+    weekday TINYINT NOT NULL,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    term_id INT NOT NULL,
+    KEY idx_timetable_slots_term_weekday (term_id, weekday),
+    CONSTRAINT fk_timetable_slots_class FOREIGN KEY (class_id) REFERENCES classes (id) ON DELETE CASCADE,
+    CONSTRAINT fk_timetable_slots_subject FOREIGN KEY (subject_id) REFERENCES subjects (id) ON DELETE CASCADE,
+    CONSTRAINT fk_timetable_slots_teacher FOREIGN KEY (teacher_id) REFERENCES teachers (id) ON DELETE CASCADE,
+    CONSTRAINT fk_timetable_slots_term FOREIGN KEY (term_id) REFERENCES terms (id) ON DELETE CASCADE,
+    CONSTRAINT chk_timetable_slots_times CHECK (start_time < end_time),
+    CONSTRAINT chk_timetable_slots_weekday CHECK (weekday BETWEEN 1 AND 7)
+);
@@ -0,0 +1,21 @@
+-- Guardians and the students they are contacts for. Relationship and the
+-- primary contact flag belong to the link, since one guardian can be the
+-- primary contact for one child and not for another.
+
+CREATE TABLE IF NOT EXISTS guardians (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    phone VARCHAR(50) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS student_guardians (
+    student_id INT NOT NULL,
+    guardian_id INT NOT NULL,
+    relationship VARCHAR(50) NOT NULL DEFAULT '',
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (student_id, guardian_id),
+    KEY idx_student_guardians_guardian (guardian_id),
+    CONSTRAINT fk_student_guardians_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_student_guardians_guardian FOREIGN KEY (guardian_id) REFERENCES guardians (id) ON DELETE CASCADE
+);
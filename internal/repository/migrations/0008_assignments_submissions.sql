@@ -0,0 +1,32 @@
+-- Homework posted to a class and the students' submissions. The uploaded
+-- file lives in the blob store, file_key is where to find it. A student has
+-- one submission per assignment, submitting again replaces it.
+
+CREATE TABLE IF NOT EXISTS assignments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    class_id INT NOT NULL,
+    subject_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    due_date DATE NOT NULL,
+    KEY idx_assignments_class_due (class_id, due_date),
+    CONSTRAINT fk_assignments_class FOREIGN KEY (class_id) REFERENCES classes (id) ON DELETE CASCADE,
+    CONSTRAINT fk_assignments_subject FOREIGN KEY (subject_id) REFERENCES subjects (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS submissions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    assignment_id INT NOT NULL,
+    student_id INT NOT NULL,
+    submitted_at DATETIME NOT NULL,
+    late BOOLEAN NOT NULL DEFAULT FALSE,
+    grade DECIMAL(6,2) NULL,
+    feedback TEXT NOT NULL,
+    file_key VARCHAR(255) NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    UNIQUE KEY uq_submissions_assignment_student (assignment_id, student_id),
+    CONSTRAINT fk_submissions_assignment FOREIGN KEY (assignment_id) REFERENCES assignments (id) ON DELETE CASCADE,
+    CONSTRAINT fk_submissions_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE
+);
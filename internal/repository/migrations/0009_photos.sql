@@ -0,0 +1,24 @@
+-- Profile photos. The image and its thumbnail live in the blob store, the
+-- keys change on every upload so they double as the ETag.
+
+CREATE TABLE IF NOT EXISTS student_photos (
+    student_id INT PRIMARY KEY,
+    photo_key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CONSTRAINT fk_student_photos_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS teacher_photos (
+    teacher_id INT PRIMARY KEY,
+    photo_key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CONSTRAINT fk_teacher_photos_teacher FOREIGN KEY (teacher_id) REFERENCES teachers (id) ON DELETE CASCADE
+);
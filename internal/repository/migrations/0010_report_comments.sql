@@ -0,0 +1,13 @@
+-- Teacher comments printed on report cards, one per teacher, student and
+-- term. term matches assessments.term.
+
+CREATE TABLE IF NOT EXISTS report_comments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    student_id INT NOT NULL,
+    teacher_id INT NOT NULL,
+    term VARCHAR(50) NOT NULL,
+    comment TEXT NOT NULL,
+    UNIQUE KEY uq_report_comments_student_teacher_term (student_id, teacher_id, term),
+    CONSTRAINT fk_report_comments_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE,
+    CONSTRAINT fk_report_comments_teacher FOREIGN KEY (teacher_id) REFERENCES teachers (id) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- Students waiting for a seat in a full class, served first come first
+-- served. A student waits for one class at a time, asking for another class
+-- moves them to the back of that class's queue.
+
+CREATE TABLE IF NOT EXISTS class_waitlist (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    class_id INT NOT NULL,
+    student_id INT NOT NULL,
+    queued_at DATETIME NOT NULL,
+    UNIQUE KEY uq_class_waitlist_student (student_id),
+    KEY idx_class_waitlist_class (class_id, id),
+    CONSTRAINT fk_class_waitlist_class FOREIGN KEY (class_id) REFERENCES classes (id) ON DELETE CASCADE,
+    CONSTRAINT fk_class_waitlist_student FOREIGN KEY (student_id) REFERENCES students (id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+-- Enrollments double as the class history of a student. Every row records
+-- the class the student came from, why they moved and who moved them.
+
+ALTER TABLE enrollments
+    ADD COLUMN from_class_id INT NULL,
+    ADD COLUMN reason VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN actor VARCHAR(100) NOT NULL DEFAULT '',
+    ADD CONSTRAINT fk_enrollments_from_class FOREIGN KEY (from_class_id) REFERENCES classes (id) ON DELETE SET NULL;
@@ -0,0 +1,11 @@
+-- Deleting a teacher or student only sets deleted_at. Rows stay restorable
+-- until the scheduled purge removes those deleted longer ago than the
+-- retention window.
+
+ALTER TABLE teachers
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD KEY idx_teachers_deleted_at (deleted_at);
+
+ALTER TABLE students
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD KEY idx_students_deleted_at (deleted_at);
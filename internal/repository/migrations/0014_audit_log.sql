@@ -0,0 +1,20 @@
+-- Every change to a teacher or student, written in the transaction of the
+-- change itself. before_data and after_data only hold the fields that
+-- changed, or the whole row on create and delete. There are no foreign keys
+-- so the history outlives purged rows.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    actor VARCHAR(100) NOT NULL DEFAULT '',
+    action VARCHAR(20) NOT NULL,
+    entity VARCHAR(50) NOT NULL,
+    entity_id INT NOT NULL,
+    before_data JSON NULL,
+    after_data JSON NULL,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    KEY idx_audit_log_entity (entity, entity_id),
+    KEY idx_audit_log_actor (actor),
+    KEY idx_audit_log_created_at (created_at)
+);
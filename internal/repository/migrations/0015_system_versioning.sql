@@ -0,0 +1,9 @@
+-- MariaDB keeps every earlier state of a teacher or student row, whatever
+-- code path changed it, and answers FOR SYSTEM_TIME queries from them.
+-- History outlives purged rows until it is removed with DELETE HISTORY.
+--
+-- Migrations that ALTER these tables from here on must run
+-- SET @@system_versioning_alter_history = KEEP; first.
+
+ALTER TABLE teachers ADD SYSTEM VERSIONING;
+ALTER TABLE students ADD SYSTEM VERSIONING;
@@ -0,0 +1,7 @@
+-- A counter bumped on every change to a teacher or student. It is the ETag
+-- of the row, and a write based on an older version is refused.
+
+SET @@system_versioning_alter_history = KEEP;
+
+ALTER TABLE teachers ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE students ADD COLUMN version INT NOT NULL DEFAULT 1;
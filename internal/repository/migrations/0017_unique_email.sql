@@ -0,0 +1,14 @@
+-- At most one teacher and one student per email among those not deleted,
+-- so PUT /teachers/ and PUT /students/ can match rows on it. A deleted row
+-- keeps its email but drops out of active_email, which lets the email be
+-- used again. Duplicates have to be cleaned up before this runs.
+
+SET @@system_versioning_alter_history = KEEP;
+
+ALTER TABLE teachers
+    ADD COLUMN active_email VARCHAR(255) AS (IF(deleted_at IS NULL, email, NULL)) PERSISTENT,
+    ADD UNIQUE KEY uq_teachers_active_email (active_email);
+
+ALTER TABLE students
+    ADD COLUMN active_email VARCHAR(255) AS (IF(deleted_at IS NULL, email, NULL)) PERSISTENT,
+    ADD UNIQUE KEY uq_students_active_email (active_email);
@@ -0,0 +1,28 @@
+-- Teaching assignments point at their term by id, like timetable slots, so
+-- a term has one identity across the schema. An assignment without a term
+-- runs all year and has a NULL term_id. Term names are looked up among the
+-- terms of the academic year of the assignment's class. A name that is not
+-- found there stops the migration, such terms have to be added first.
+
+ALTER TABLE teaching_assignments ADD COLUMN term_id INT NULL AFTER subject_id;
+
+UPDATE teaching_assignments ta
+JOIN classes c ON c.id = ta.class_id
+JOIN terms t ON t.name = ta.term AND t.academic_year_id = c.academic_year_id
+SET ta.term_id = t.id
+WHERE ta.term <> '';
+
+ALTER TABLE teaching_assignments
+    ADD CONSTRAINT chk_teaching_assignments_term_found CHECK (term = '' OR term_id IS NOT NULL);
+
+-- NULLs never clash in a unique key, term_key makes all year count as one
+-- term so a subject still has one teacher per class and term
+ALTER TABLE teaching_assignments
+    ADD COLUMN term_key INT AS (COALESCE(term_id, 0)) PERSISTENT,
+    ADD UNIQUE KEY uq_teaching_assignments_class_subject_term_id (class_id, subject_id, term_key);
+
+ALTER TABLE teaching_assignments
+    DROP CONSTRAINT chk_teaching_assignments_term_found,
+    DROP INDEX uq_teaching_assignments_class_subject_term,
+    DROP COLUMN term,
+    ADD CONSTRAINT fk_teaching_assignments_term FOREIGN KEY (term_id) REFERENCES terms (id) ON DELETE CASCADE;
@@ -0,0 +1,45 @@
+-- Assessments and report comments point at their term by id, like timetable
+-- slots and teaching assignments. Term names are looked up among the terms
+-- of the academic year of the assessment's class, then among all terms,
+-- newest first, which is how report cards used to find a term's dates. A
+-- name that is not found stops the migration, such terms have to be added
+-- first.
+--
+-- An assessment without a term keeps a NULL term_id, and deleting a term
+-- keeps its assessments and their scores. A report comment always has a
+-- term and goes with it.
+
+ALTER TABLE assessments ADD COLUMN term_id INT NULL AFTER due_date;
+
+UPDATE assessments a
+JOIN classes c ON c.id = a.class_id
+SET a.term_id = COALESCE(
+    (SELECT t.id FROM terms t WHERE t.name = a.term AND t.academic_year_id = c.academic_year_id),
+    (SELECT t.id FROM terms t WHERE t.name = a.term ORDER BY t.start_date DESC LIMIT 1))
+WHERE a.term <> '';
+
+ALTER TABLE assessments
+    ADD CONSTRAINT chk_assessments_term_found CHECK (term = '' OR term_id IS NOT NULL);
+
+ALTER TABLE assessments
+    DROP CONSTRAINT chk_assessments_term_found,
+    DROP COLUMN term,
+    ADD CONSTRAINT fk_assessments_term FOREIGN KEY (term_id) REFERENCES terms (id) ON DELETE SET NULL;
+
+ALTER TABLE report_comments ADD COLUMN term_id INT NULL AFTER teacher_id;
+
+UPDATE report_comments rc
+SET rc.term_id = (SELECT t.id FROM terms t WHERE t.name = rc.term ORDER BY t.start_date DESC LIMIT 1);
+
+ALTER TABLE report_comments
+    ADD CONSTRAINT chk_report_comments_term_found CHECK (term_id IS NOT NULL);
+
+ALTER TABLE report_comments
+    ADD UNIQUE KEY uq_report_comments_student_teacher_term_id (student_id, teacher_id, term_id);
+
+ALTER TABLE report_comments
+    DROP CONSTRAINT chk_report_comments_term_found,
+    DROP INDEX uq_report_comments_student_teacher_term,
+    DROP COLUMN term,
+    MODIFY term_id INT NOT NULL,
+    ADD CONSTRAINT fk_report_comments_term FOREIGN KEY (term_id) REFERENCES terms (id) ON DELETE CASCADE;
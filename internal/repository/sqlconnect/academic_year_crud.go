@@ -0,0 +1,325 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+func GetAcademicYearsFromDb() ([]models.AcademicYear, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, name, start_date, end_date FROM academic_years ORDER BY start_date")
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	years := make([]models.AcademicYear, 0)
+	for rows.Next() {
+		var year models.AcademicYear
+		err = rows.Scan(&year.ID, &year.Name, &year.StartDate, &year.EndDate)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		years = append(years, year)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return years, nil
+}
+
+func GetOneAcademicYear(id int) (models.AcademicYear, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.AcademicYear{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var year models.AcademicYear
+	err = db.QueryRow("SELECT id, name, start_date, end_date FROM academic_years WHERE id = ?", id).Scan(&year.ID, &year.Name, &year.StartDate, &year.EndDate)
+	if err == sql.ErrNoRows {
+		return models.AcademicYear{}, utils.ErrorHandler(err, "error academic year not found")
+	} else if err != nil {
+		return models.AcademicYear{}, utils.ErrorHandler(err, "error getting academic year from database")
+	}
+	return year, nil
+}
+
+func AddAcademicYears(newYears []models.AcademicYear) ([]models.AcademicYear, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare(utils.GenerateInsertQuery("academic_years", models.AcademicYear{}))
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	addedYears := make([]models.AcademicYear, len(newYears))
+	for i, newYear := range newYears {
+		res, err := stmt.Exec(newYear.Name, newYear.StartDate, newYear.EndDate)
+		if err != nil {
+			tx.Rollback()
+			if isDuplicateEntry(err) {
+				return nil, utils.ConflictHandler(err, "academic year already exists: "+newYear.Name)
+			}
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newYear.ID = int(lastId)
+		addedYears[i] = newYear
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedYears, nil
+}
+
+func UpdateAcademicYear(id int, updateYear models.AcademicYear) (models.AcademicYear, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.AcademicYear{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var exists int
+	err = db.QueryRow("SELECT COUNT(*) FROM academic_years WHERE id = ?", id).Scan(&exists)
+	if err != nil {
+		return models.AcademicYear{}, utils.ErrorHandler(err, "error retrieving academic year from database")
+	}
+	if exists == 0 {
+		return models.AcademicYear{}, utils.ErrorHandler(sql.ErrNoRows, "academic year not found")
+	}
+
+	// Shrinking a year must not leave its terms outside of it
+	var outside int
+	err = db.QueryRow("SELECT COUNT(*) FROM terms WHERE academic_year_id = ? AND (start_date < ? OR end_date > ?)", id, updateYear.StartDate, updateYear.EndDate).Scan(&outside)
+	if err != nil {
+		return models.AcademicYear{}, utils.ErrorHandler(err, "error retrieving terms from database")
+	}
+	if outside > 0 {
+		return models.AcademicYear{}, utils.InvalidHandler(nil, "academic year would no longer contain all of its terms")
+	}
+
+	updateYear.ID = id
+	_, err = db.Exec("UPDATE academic_years SET name = ?, start_date = ?, end_date = ? WHERE id = ?", updateYear.Name, updateYear.StartDate, updateYear.EndDate, id)
+	if isDuplicateEntry(err) {
+		return models.AcademicYear{}, utils.ConflictHandler(err, "academic year already exists: "+updateYear.Name)
+	} else if err != nil {
+		return models.AcademicYear{}, utils.ErrorHandler(err, "error updating academic year")
+	}
+	return updateYear, nil
+}
+
+func DeleteOneAcademicYear(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM academic_years WHERE id = ?", id)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return utils.ErrorHandler(err, "academic year was not found")
+	}
+	return nil
+}
+
+func GetTermsFromDb(query string, args []interface{}) ([]models.Term, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, academic_year_id, name, start_date, end_date FROM terms WHERE 1=1"+query+" ORDER BY start_date", args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	terms := make([]models.Term, 0)
+	for rows.Next() {
+		var term models.Term
+		err = rows.Scan(&term.ID, &term.AcademicYearID, &term.Name, &term.StartDate, &term.EndDate)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		terms = append(terms, term)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return terms, nil
+}
+
+func GetOneTerm(id int) (models.Term, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Term{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var term models.Term
+	err = db.QueryRow("SELECT id, academic_year_id, name, start_date, end_date FROM terms WHERE id = ?", id).Scan(&term.ID, &term.AcademicYearID, &term.Name, &term.StartDate, &term.EndDate)
+	if err == sql.ErrNoRows {
+		return models.Term{}, utils.ErrorHandler(err, "error term not found")
+	} else if err != nil {
+		return models.Term{}, utils.ErrorHandler(err, "error getting term from database")
+	}
+	return term, nil
+}
+
+// A term has to lie within its academic year
+func checkTermInYear(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, term models.Term) error {
+	var inside int
+	err := q.QueryRow("SELECT COUNT(*) FROM academic_years WHERE id = ? AND start_date <= ? AND end_date >= ?", term.AcademicYearID, term.StartDate, term.EndDate).Scan(&inside)
+	if err != nil {
+		return utils.ErrorHandler(err, "error retrieving academic year from database")
+	}
+	if inside == 0 {
+		return utils.InvalidHandler(nil, fmt.Sprintf("term %s is not within academic year %d", term.Name, term.AcademicYearID))
+	}
+	return nil
+}
+
+func AddTerms(newTerms []models.Term) ([]models.Term, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare(utils.GenerateInsertQuery("terms", models.Term{}))
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	addedTerms := make([]models.Term, len(newTerms))
+	for i, newTerm := range newTerms {
+		err = checkTermInYear(tx, newTerm)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		res, err := stmt.Exec(newTerm.AcademicYearID, newTerm.Name, newTerm.StartDate, newTerm.EndDate)
+		if err != nil {
+			tx.Rollback()
+			if isDuplicateEntry(err) {
+				return nil, utils.ConflictHandler(err, "term already exists in this academic year: "+newTerm.Name)
+			}
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newTerm.ID = int(lastId)
+		addedTerms[i] = newTerm
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedTerms, nil
+}
+
+func UpdateTerm(id int, updateTerm models.Term) (models.Term, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Term{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var exists int
+	err = db.QueryRow("SELECT COUNT(*) FROM terms WHERE id = ?", id).Scan(&exists)
+	if err != nil {
+		return models.Term{}, utils.ErrorHandler(err, "error retrieving term from database")
+	}
+	if exists == 0 {
+		return models.Term{}, utils.ErrorHandler(sql.ErrNoRows, "term not found")
+	}
+
+	err = checkTermInYear(db, updateTerm)
+	if err != nil {
+		return models.Term{}, err
+	}
+
+	updateTerm.ID = id
+	_, err = db.Exec("UPDATE terms SET academic_year_id = ?, name = ?, start_date = ?, end_date = ? WHERE id = ?",
+		updateTerm.AcademicYearID, updateTerm.Name, updateTerm.StartDate, updateTerm.EndDate, id)
+	if isDuplicateEntry(err) {
+		return models.Term{}, utils.ConflictHandler(err, "term already exists in this academic year: "+updateTerm.Name)
+	} else if err != nil {
+		return models.Term{}, utils.ErrorHandler(err, "error updating term")
+	}
+	return updateTerm, nil
+}
+
+func DeleteOneTerm(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM terms WHERE id = ?", id)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return utils.ErrorHandler(err, "term was not found")
+	}
+	return nil
+}
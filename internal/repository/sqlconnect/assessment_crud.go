@@ -0,0 +1,264 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+const assessmentColumns = "id, class_id, subject_id, title, max_score, weight, due_date, COALESCE(term_id, 0)"
+
+func scanAssessment(row interface{ Scan(...interface{}) error }, assessment *models.Assessment) error {
+	return row.Scan(
+		&assessment.ID,
+		&assessment.ClassID,
+		&assessment.SubjectID,
+		&assessment.Title,
+		&assessment.MaxScore,
+		&assessment.Weight,
+		&assessment.DueDate,
+		&assessment.TermID,
+	)
+}
+
+func GetAssessmentsFromDb(query string, args []interface{}) ([]models.Assessment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+assessmentColumns+" FROM assessments WHERE 1=1"+query, args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	assessments := make([]models.Assessment, 0)
+	for rows.Next() {
+		var assessment models.Assessment
+		err = scanAssessment(rows, &assessment)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		assessments = append(assessments, assessment)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return assessments, nil
+}
+
+func GetOneAssessment(id int) (models.Assessment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Assessment{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var assessment models.Assessment
+	err = scanAssessment(db.QueryRow("SELECT "+assessmentColumns+" FROM assessments WHERE id = ?", id), &assessment)
+	if err == sql.ErrNoRows {
+		return models.Assessment{}, utils.ErrorHandler(err, "error assessment not found")
+	} else if err != nil {
+		return models.Assessment{}, utils.ErrorHandler(err, "error getting assessment from database")
+	}
+	return assessment, nil
+}
+
+func AddAssessments(newAssessments []models.Assessment) ([]models.Assessment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare(utils.GenerateInsertQuery("assessments", models.Assessment{}))
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	addedAssessments := make([]models.Assessment, len(newAssessments))
+	for i, newAssessment := range newAssessments {
+		res, err := stmt.Exec(newAssessment.ClassID, newAssessment.SubjectID, newAssessment.Title, newAssessment.MaxScore,
+			newAssessment.Weight, newAssessment.DueDate, nullableId(newAssessment.TermID))
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newAssessment.ID = int(lastId)
+		addedAssessments[i] = newAssessment
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedAssessments, nil
+}
+
+func UpdateAssessment(id int, updateAssessment models.Assessment) (models.Assessment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Assessment{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	updateAssessment.ID = id
+	err = execAssessmentUpdate(db, updateAssessment)
+	if err != nil {
+		return models.Assessment{}, err
+	}
+	return updateAssessment, nil
+}
+
+func PatchOneAssessment(id int, updates map[string]interface{}) (models.Assessment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Assessment{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var existingAssessment models.Assessment
+	err = utils.PatchAssessmentModel(db, id, &existingAssessment, updates)
+	if err != nil {
+		return models.Assessment{}, utils.ErrorHandler(err, "error patching model")
+	}
+
+	err = execAssessmentUpdate(db, existingAssessment)
+	if err != nil {
+		return models.Assessment{}, err
+	}
+	return existingAssessment, nil
+}
+
+func execAssessmentUpdate(db *sql.DB, assessment models.Assessment) error {
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM assessments WHERE id = ?", assessment.ID).Scan(&exists)
+	if err != nil {
+		return utils.ErrorHandler(err, "error retrieving assessment from database")
+	}
+	if exists == 0 {
+		return utils.ErrorHandler(sql.ErrNoRows, "assessment not found")
+	}
+
+	// Lowering the maximum would leave existing scores above it
+	var highestScore float64
+	err = db.QueryRow("SELECT COALESCE(MAX(score), 0) FROM scores WHERE assessment_id = ?", assessment.ID).Scan(&highestScore)
+	if err != nil {
+		return utils.ErrorHandler(err, "error retrieving scores from database")
+	}
+	if highestScore > assessment.MaxScore {
+		return utils.InvalidHandler(nil, fmt.Sprintf("max_score cannot be below an existing score of %v", highestScore))
+	}
+
+	_, err = db.Exec("UPDATE assessments SET class_id = ?, subject_id = ?, title = ?, max_score = ?, weight = ?, due_date = ?, term_id = ? WHERE id = ?",
+		assessment.ClassID, assessment.SubjectID, assessment.Title, assessment.MaxScore, assessment.Weight, assessment.DueDate, nullableId(assessment.TermID), assessment.ID)
+	if err != nil {
+		return utils.ErrorHandler(err, "error updating assessment")
+	}
+	return nil
+}
+
+func DeleteOneAssessment(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM assessments WHERE id = ?", id)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return utils.ErrorHandler(err, "assessment was not found")
+	}
+	return nil
+}
+
+// Records scores for one assessment in a single transaction. A student that
+// already has a score gets it overwritten, so the whole batch can be resent.
+func AddScores(assessmentId int, scores []models.Score) ([]models.Score, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	var classId int
+	var maxScore float64
+	err = tx.QueryRow("SELECT class_id, max_score FROM assessments WHERE id = ?", assessmentId).Scan(&classId, &maxScore)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "assessment not found")
+	} else if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error retrieving assessment")
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO scores (assessment_id, student_id, score) VALUES (?,?,?)
+		ON DUPLICATE KEY UPDATE score = VALUES(score)`)
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	savedScores := make([]models.Score, len(scores))
+	for i, score := range scores {
+		if score.Score < 0 || score.Score > maxScore {
+			tx.Rollback()
+			return nil, utils.InvalidHandler(nil, fmt.Sprintf("score for student %d must be between 0 and %v", score.StudentID, maxScore))
+		}
+
+		var studentClassId int
+		err = tx.QueryRow("SELECT COALESCE(class_id, 0) FROM students WHERE id = ?", score.StudentID).Scan(&studentClassId)
+		if err == sql.ErrNoRows || (err == nil && studentClassId != classId) {
+			tx.Rollback()
+			return nil, utils.InvalidHandler(err, fmt.Sprintf("student %d is not in the assessed class", score.StudentID))
+		} else if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error retrieving student")
+		}
+
+		_, err = stmt.Exec(assessmentId, score.StudentID, score.Score)
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error inserting score into database")
+		}
+		score.AssessmentID = assessmentId
+		savedScores[i] = score
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return savedScores, nil
+}
@@ -0,0 +1,349 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+	"time"
+)
+
+const assignmentColumns = "id, class_id, subject_id, title, description, due_date"
+
+const submissionColumns = "id, assignment_id, student_id, submitted_at, late, grade, feedback, file_key, file_name, content_type, size"
+
+func scanAssignment(row interface{ Scan(...interface{}) error }, assignment *models.Assignment) error {
+	return row.Scan(&assignment.ID, &assignment.ClassID, &assignment.SubjectID, &assignment.Title, &assignment.Description, &assignment.DueDate)
+}
+
+func scanSubmission(row interface{ Scan(...interface{}) error }, submission *models.Submission) error {
+	var grade sql.NullFloat64
+	err := row.Scan(&submission.ID, &submission.AssignmentID, &submission.StudentID, &submission.SubmittedAt, &submission.Late,
+		&grade, &submission.Feedback, &submission.FileKey, &submission.FileName, &submission.ContentType, &submission.Size)
+	if err != nil {
+		return err
+	}
+	submission.Grade = nil
+	if grade.Valid {
+		submission.Grade = &grade.Float64
+	}
+	return nil
+}
+
+func GetAssignmentsFromDb(query string, args []interface{}) ([]models.Assignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+assignmentColumns+" FROM assignments WHERE 1=1"+query+" ORDER BY due_date", args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	assignments := make([]models.Assignment, 0)
+	for rows.Next() {
+		var assignment models.Assignment
+		err = scanAssignment(rows, &assignment)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		assignments = append(assignments, assignment)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return assignments, nil
+}
+
+func GetOneAssignment(id int) (models.Assignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Assignment{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var assignment models.Assignment
+	err = scanAssignment(db.QueryRow("SELECT "+assignmentColumns+" FROM assignments WHERE id = ?", id), &assignment)
+	if err == sql.ErrNoRows {
+		return models.Assignment{}, utils.ErrorHandler(err, "error assignment not found")
+	} else if err != nil {
+		return models.Assignment{}, utils.ErrorHandler(err, "error getting assignment from database")
+	}
+	return assignment, nil
+}
+
+func AddAssignments(newAssignments []models.Assignment) ([]models.Assignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare(utils.GenerateInsertQuery("assignments", models.Assignment{}))
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	addedAssignments := make([]models.Assignment, len(newAssignments))
+	for i, newAssignment := range newAssignments {
+		res, err := stmt.Exec(utils.GetStructValues(newAssignment)...)
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newAssignment.ID = int(lastId)
+		addedAssignments[i] = newAssignment
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedAssignments, nil
+}
+
+func UpdateAssignment(id int, updateAssignment models.Assignment) (models.Assignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Assignment{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var exists int
+	err = db.QueryRow("SELECT COUNT(*) FROM assignments WHERE id = ?", id).Scan(&exists)
+	if err != nil {
+		return models.Assignment{}, utils.ErrorHandler(err, "error retrieving assignment from database")
+	}
+	if exists == 0 {
+		return models.Assignment{}, utils.ErrorHandler(sql.ErrNoRows, "assignment not found")
+	}
+
+	updateAssignment.ID = id
+	_, err = db.Exec("UPDATE assignments SET class_id = ?, subject_id = ?, title = ?, description = ?, due_date = ? WHERE id = ?",
+		updateAssignment.ClassID, updateAssignment.SubjectID, updateAssignment.Title, updateAssignment.Description, updateAssignment.DueDate, id)
+	if err != nil {
+		return models.Assignment{}, utils.ErrorHandler(err, "error updating assignment")
+	}
+	return updateAssignment, nil
+}
+
+// Deletes the assignment and its submissions. Returns the blob keys of the
+// submitted files, which the caller removes from the blob store.
+func DeleteOneAssignment(id int) ([]string, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	rows, err := tx.Query("SELECT file_key FROM submissions WHERE assignment_id = ? FOR UPDATE", id)
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error retrieving submissions")
+	}
+	var fileKeys []string
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		fileKeys = append(fileKeys, key)
+	}
+	rows.Close()
+
+	result, err := tx.Exec("DELETE FROM assignments WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "assignment was not found")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return fileKeys, nil
+}
+
+func GetSubmissionsByAssignmentIdFromDb(assignmentId int) ([]models.Submission, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+submissionColumns+" FROM submissions WHERE assignment_id = ? ORDER BY submitted_at", assignmentId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	submissions := make([]models.Submission, 0)
+	for rows.Next() {
+		var submission models.Submission
+		err = scanSubmission(rows, &submission)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		submissions = append(submissions, submission)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return submissions, nil
+}
+
+func GetOneSubmission(id int) (models.Submission, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Submission{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var submission models.Submission
+	err = scanSubmission(db.QueryRow("SELECT "+submissionColumns+" FROM submissions WHERE id = ?", id), &submission)
+	if err == sql.ErrNoRows {
+		return models.Submission{}, utils.ErrorHandler(err, "error submission not found")
+	} else if err != nil {
+		return models.Submission{}, utils.ErrorHandler(err, "error getting submission from database")
+	}
+	return submission, nil
+}
+
+// Stores a student's submission, replacing an earlier one for the same
+// assignment. A replaced submission loses its grade and feedback, since the
+// new file has not been marked yet. Returns the saved submission and the
+// blob key of the file it replaced, if any.
+func SaveSubmission(submission models.Submission) (models.Submission, string, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Submission{}, "", utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Submission{}, "", utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	var classId int
+	var dueDate string
+	err = tx.QueryRow("SELECT class_id, due_date FROM assignments WHERE id = ?", submission.AssignmentID).Scan(&classId, &dueDate)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.Submission{}, "", utils.ErrorHandler(err, "assignment not found")
+	} else if err != nil {
+		tx.Rollback()
+		return models.Submission{}, "", utils.ErrorHandler(err, "error retrieving assignment")
+	}
+
+	var studentClassId int
+	err = tx.QueryRow("SELECT COALESCE(class_id, 0) FROM students WHERE id = ?", submission.StudentID).Scan(&studentClassId)
+	if err == sql.ErrNoRows || (err == nil && studentClassId != classId) {
+		tx.Rollback()
+		return models.Submission{}, "", utils.InvalidHandler(err, fmt.Sprintf("student %d is not in the assignment's class", submission.StudentID))
+	} else if err != nil {
+		tx.Rollback()
+		return models.Submission{}, "", utils.ErrorHandler(err, "error retrieving student")
+	}
+
+	var oldKey string
+	err = tx.QueryRow("SELECT file_key FROM submissions WHERE assignment_id = ? AND student_id = ? FOR UPDATE", submission.AssignmentID, submission.StudentID).Scan(&oldKey)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return models.Submission{}, "", utils.ErrorHandler(err, "error retrieving submission")
+	}
+
+	// Work handed in on the due date is on time
+	due, err := time.ParseInLocation(time.DateOnly, dueDate, time.Local)
+	if err != nil {
+		tx.Rollback()
+		return models.Submission{}, "", utils.ErrorHandler(err, "invalid assignment due date")
+	}
+	now := time.Now()
+	submission.SubmittedAt = now.Format(time.DateTime)
+	submission.Late = !now.Before(due.AddDate(0, 0, 1))
+	submission.Grade = nil
+	submission.Feedback = ""
+
+	_, err = tx.Exec(`INSERT INTO submissions (assignment_id, student_id, submitted_at, late, grade, feedback, file_key, file_name, content_type, size)
+		VALUES (?,?,?,?,NULL,'',?,?,?,?)
+		ON DUPLICATE KEY UPDATE submitted_at = VALUES(submitted_at), late = VALUES(late), grade = NULL, feedback = '',
+		file_key = VALUES(file_key), file_name = VALUES(file_name), content_type = VALUES(content_type), size = VALUES(size)`,
+		submission.AssignmentID, submission.StudentID, submission.SubmittedAt, submission.Late,
+		submission.FileKey, submission.FileName, submission.ContentType, submission.Size)
+	if err != nil {
+		tx.Rollback()
+		return models.Submission{}, "", utils.ErrorHandler(err, "error saving submission")
+	}
+
+	err = tx.QueryRow("SELECT id FROM submissions WHERE assignment_id = ? AND student_id = ?", submission.AssignmentID, submission.StudentID).Scan(&submission.ID)
+	if err != nil {
+		tx.Rollback()
+		return models.Submission{}, "", utils.ErrorHandler(err, "error retrieving submission")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Submission{}, "", utils.ErrorHandler(err, "error committing transaction")
+	}
+	return submission, oldKey, nil
+}
+
+// Sets the grade and/or feedback of a submission, fields left nil are kept
+func GradeSubmission(id int, grade models.SubmissionGrade) (models.Submission, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Submission{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	_, err = db.Exec("UPDATE submissions SET grade = IF(?, ?, grade), feedback = COALESCE(?, feedback) WHERE id = ?",
+		grade.Grade != nil, grade.Grade, grade.Feedback, id)
+	if err != nil {
+		return models.Submission{}, utils.ErrorHandler(err, "error grading submission")
+	}
+	var submission models.Submission
+	err = scanSubmission(db.QueryRow("SELECT "+submissionColumns+" FROM submissions WHERE id = ?", id), &submission)
+	if err == sql.ErrNoRows {
+		return models.Submission{}, utils.ErrorHandler(err, "submission not found")
+	} else if err != nil {
+		return models.Submission{}, utils.ErrorHandler(err, "error retrieving submission")
+	}
+	return submission, nil
+}
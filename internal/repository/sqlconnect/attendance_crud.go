@@ -0,0 +1,171 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// Late counts as attended and excused records are left out. A student whose
+// records are all excused has 100%.
+const attendancePercentage = `COALESCE(100 * SUM(status IN ('present', 'late')) / NULLIF(COUNT(*) - SUM(status = 'excused'), 0), 100)`
+
+func GetAttendanceFromDb(query string, args []interface{}) ([]models.Attendance, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, student_id, date, period, status, note, COALESCE(recorded_by, 0) FROM attendance WHERE 1=1"+query+" ORDER BY date, period, student_id", args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	records := make([]models.Attendance, 0)
+	for rows.Next() {
+		var record models.Attendance
+		err = rows.Scan(&record.ID, &record.StudentID, &record.Date, &record.Period, &record.Status, &record.Note, &record.RecordedBy)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		records = append(records, record)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return records, nil
+}
+
+// Saves a class register in one transaction. Every record is keyed by
+// student, date and period, so submitting the same register again updates
+// the existing rows rather than adding new ones.
+func SaveAttendanceRegister(classId int, register models.AttendanceRegister) ([]models.Attendance, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	inClass := make(map[int]bool)
+	rows, err := tx.Query("SELECT id FROM students WHERE class_id = ?", classId)
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error retrieving class students")
+	}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		inClass[id] = true
+	}
+	rows.Close()
+
+	stmt, err := tx.Prepare(`INSERT INTO attendance (student_id, date, period, status, note, recorded_by) VALUES (?,?,?,?,?,?)
+		ON DUPLICATE KEY UPDATE status = VALUES(status), note = VALUES(note), recorded_by = VALUES(recorded_by)`)
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	saved := make([]models.Attendance, len(register.Records))
+	for i, record := range register.Records {
+		if !inClass[record.StudentID] {
+			tx.Rollback()
+			return nil, utils.InvalidHandler(nil, fmt.Sprintf("student %d is not in class %d", record.StudentID, classId))
+		}
+
+		record.Date = register.Date
+		record.Period = register.Period
+		record.RecordedBy = register.RecordedBy
+		_, err = stmt.Exec(record.StudentID, record.Date, record.Period, record.Status, record.Note, nullableId(record.RecordedBy))
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error saving attendance")
+		}
+		saved[i] = record
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return saved, nil
+}
+
+// Per student attendance over the records matching query. If below is not
+// nil only students with a percentage under it are returned.
+func GetAttendanceSummariesFromDb(query string, args []interface{}, below *float64) ([]models.AttendanceSummary, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	summaryQuery := `SELECT student_id,
+		SUM(status = 'present'), SUM(status = 'absent'), SUM(status = 'late'), SUM(status = 'excused'),
+		COUNT(*), ` + attendancePercentage + ` AS percentage
+		FROM attendance WHERE 1=1` + query + ` GROUP BY student_id`
+	if below != nil {
+		summaryQuery += " HAVING percentage < ?"
+		args = append(args, *below)
+	}
+	summaryQuery += " ORDER BY percentage, student_id"
+
+	rows, err := db.Query(summaryQuery, args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	summaries := make([]models.AttendanceSummary, 0)
+	for rows.Next() {
+		var s models.AttendanceSummary
+		err = rows.Scan(&s.StudentID, &s.Present, &s.Absent, &s.Late, &s.Excused, &s.Total, &s.Percentage)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		summaries = append(summaries, s)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return summaries, nil
+}
+
+func DeleteOneAttendance(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM attendance WHERE id = ?", id)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return utils.ErrorHandler(sql.ErrNoRows, "attendance record was not found")
+	}
+	return nil
+}
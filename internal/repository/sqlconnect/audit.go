@@ -0,0 +1,97 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"encoding/json"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+	"time"
+)
+
+// Records a change in the transaction that makes it, so the change and its
+// audit entry are committed or rolled back together. before is nil for a
+// create and after is nil for a delete.
+func writeAudit(tx *sql.Tx, info models.AuditInfo, action string, entity string, id int, before interface{}, after interface{}) error {
+	beforeData, afterData, err := auditDiff(before, after)
+	if err != nil {
+		return utils.ErrorHandler(err, "error building audit entry")
+	}
+
+	_, err = tx.Exec(`INSERT INTO audit_log (actor, action, entity, entity_id, before_data, after_data, request_id, ip, created_at)
+		VALUES (?,?,?,?,?,?,?,?,?)`,
+		info.Actor, action, entity, id, beforeData, afterData, info.RequestID, info.IP, time.Now().Format(time.DateTime))
+	if err != nil {
+		return utils.ErrorHandler(err, "error writing audit log")
+	}
+	return nil
+}
+
+// Turns both sides into JSON objects of the fields that differ. A side that
+// is nil stays NULL and the other side is kept whole.
+func auditDiff(before interface{}, after interface{}) (interface{}, interface{}, error) {
+	beforeFields, afterFields, err := utils.DiffFields(before, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	beforeData, err := marshalFields(beforeFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterData, err := marshalFields(afterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeData, afterData, nil
+}
+
+// nil becomes NULL rather than "null"
+func marshalFields(fields map[string]interface{}) (interface{}, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Audit entries matching query, newest first, and how many there are in all
+func GetAuditLogFromDb(query string, args []interface{}, limit int, offset int) ([]models.AuditEntry, int, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, 0, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var total int
+	err = db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE 1=1"+query, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, utils.ErrorHandler(err, "error counting audit log")
+	}
+
+	rows, err := db.Query(`SELECT id, actor, action, entity, entity_id, before_data, after_data, request_id, ip, created_at
+		FROM audit_log WHERE 1=1`+query+` ORDER BY id DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	entries := make([]models.AuditEntry, 0)
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after []byte
+		err = rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Entity, &entry.EntityID,
+			&before, &after, &entry.RequestID, &entry.IP, &entry.CreatedAt)
+		if err != nil {
+			return nil, 0, utils.ErrorHandler(err, "error scanning database results")
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, 0, utils.ErrorHandler(err, "error with row")
+	}
+	return entries, total, nil
+}
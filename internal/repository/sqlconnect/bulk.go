@@ -0,0 +1,111 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// Runs the n items of a bulk request. An atomic batch shares one transaction
+// and stops at the first item that fails, which is returned as the error.
+// Otherwise every item gets a transaction of its own and a failure only ends
+// up in that item's result. done, when set, runs before each commit.
+func runBulk(n int, atomic bool, item func(tx *sql.Tx, i int) (models.BulkItemResult, error), done func(tx *sql.Tx) error) ([]models.BulkItemResult, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	results := make([]models.BulkItemResult, n)
+	if !atomic {
+		for i := range results {
+			results[i] = runBulkItem(db, i, item, done)
+		}
+		return results, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+	for i := range results {
+		result, err := item(tx, i)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		result.Index = i
+		results[i] = result
+	}
+	if done != nil {
+		err = done(tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return results, nil
+}
+
+func runBulkItem(db *sql.DB, i int, item func(tx *sql.Tx, i int) (models.BulkItemResult, error), done func(tx *sql.Tx) error) models.BulkItemResult {
+	tx, err := db.Begin()
+	if err != nil {
+		return models.BulkItemResult{Index: i, Err: utils.ErrorHandler(err, "error starting transaction")}
+	}
+
+	result, err := item(tx, i)
+	if err == nil && done != nil {
+		err = done(tx)
+	}
+	if err != nil {
+		tx.Rollback()
+		return models.BulkItemResult{Index: i, ID: result.ID, Err: err}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.BulkItemResult{Index: i, ID: result.ID, Err: utils.ErrorHandler(err, "error committing transaction")}
+	}
+	result.Index = i
+	return result
+}
+
+// Reads the id and the optional version of a bulk patch item and leaves the
+// fields to change as a merge patch
+func bulkPatch(update map[string]interface{}) (int, int, utils.Patch, error) {
+	idFloat, ok := update["id"].(float64)
+	if !ok || idFloat != float64(int(idFloat)) {
+		return 0, 0, utils.Patch{}, utils.InvalidHandler(nil, "id is required and must be an integer")
+	}
+
+	fields := make(map[string]interface{}, len(update))
+	var version int
+	for key, value := range update {
+		switch key {
+		case "id":
+		case "version":
+			versionFloat, ok := value.(float64)
+			if !ok || versionFloat != float64(int(versionFloat)) {
+				return 0, 0, utils.Patch{}, utils.InvalidHandler(nil, "version must be an integer")
+			}
+			version = int(versionFloat)
+		default:
+			fields[key] = value
+		}
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return 0, 0, utils.Patch{}, utils.ErrorHandler(err, "error encoding patch")
+	}
+	patch, err := utils.MergePatch(body)
+	return int(idFloat), version, patch, err
+}
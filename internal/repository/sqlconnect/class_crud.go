@@ -0,0 +1,324 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+const classColumns = "id, name, grade, section, room, capacity, COALESCE(homeroom_teacher_id, 0), COALESCE(academic_year_id, 0)"
+
+// An id of 0 means there is no such row (e.g. a class without a homeroom
+// teacher), which is stored as NULL.
+func nullableId(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// A NULL column such as deleted_at comes back as nil
+func nullableString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func scanClass(row interface{ Scan(...interface{}) error }, class *models.Class) error {
+	return row.Scan(&class.ID, &class.Name, &class.Grade, &class.Section, &class.Room, &class.Capacity, &class.HomeroomTeacherID, &class.AcademicYearID)
+}
+
+func GetClassesFromDb(query string, args []interface{}) ([]models.Class, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+classColumns+" FROM classes WHERE 1=1"+query, args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	classes := make([]models.Class, 0)
+	for rows.Next() {
+		var class models.Class
+		err = scanClass(rows, &class)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		classes = append(classes, class)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return classes, nil
+}
+
+func GetOneClass(id int) (models.Class, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Class{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var class models.Class
+	err = scanClass(db.QueryRow("SELECT "+classColumns+" FROM classes WHERE id = ?", id), &class)
+	if err == sql.ErrNoRows {
+		return models.Class{}, utils.NotFoundHandler(err, "error class not found")
+	} else if err != nil {
+		return models.Class{}, utils.ErrorHandler(err, "error getting class from database")
+	}
+	return class, nil
+}
+
+func AddClasses(newClasses []models.Class) ([]models.Class, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO classes (name, grade, section, room, capacity, homeroom_teacher_id, academic_year_id) VALUES (?,?,?,?,?,?,?)")
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	addedClasses := make([]models.Class, len(newClasses))
+	for i, newClass := range newClasses {
+		res, err := stmt.Exec(newClass.Name, newClass.Grade, newClass.Section, newClass.Room, newClass.Capacity, nullableId(newClass.HomeroomTeacherID), nullableId(newClass.AcademicYearID))
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newClass.ID = int(lastId)
+		addedClasses[i] = newClass
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedClasses, nil
+}
+
+func UpdateClass(id int, updateClass models.Class, info models.AuditInfo) (models.Class, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Class{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Class{}, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	updateClass.ID = id
+	err = execClassUpdate(tx, updateClass, info)
+	if err != nil {
+		tx.Rollback()
+		return models.Class{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Class{}, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return updateClass, nil
+}
+
+// The updates are applied to the class locked in the transaction, so a
+// concurrent write can't slip in between the read and the update.
+func PatchOneClass(id int, updates map[string]interface{}, info models.AuditInfo) (models.Class, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Class{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Class{}, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	var existingClass models.Class
+	err = utils.PatchClassModel(tx, id, &existingClass, updates)
+	if err != nil {
+		tx.Rollback()
+		return models.Class{}, err
+	}
+
+	err = execClassUpdate(tx, existingClass, info)
+	if err != nil {
+		tx.Rollback()
+		return models.Class{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Class{}, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return existingClass, nil
+}
+
+// Raising the capacity hands the new seats to the class's waitlist
+func execClassUpdate(tx *sql.Tx, class models.Class, info models.AuditInfo) error {
+	result, err := tx.Exec("UPDATE classes SET name = ?, grade = ?, section = ?, room = ?, capacity = ?, homeroom_teacher_id = ?, academic_year_id = ? WHERE id = ?",
+		class.Name, class.Grade, class.Section, class.Room, class.Capacity, nullableId(class.HomeroomTeacherID), nullableId(class.AcademicYearID), class.ID)
+	if err != nil {
+		return utils.ErrorHandler(err, "error updating class")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+	if rowsAffected == 0 {
+		var exists int
+		err = tx.QueryRow("SELECT COUNT(*) FROM classes WHERE id = ?", class.ID).Scan(&exists)
+		if err != nil {
+			return utils.ErrorHandler(err, "error retrieving class")
+		}
+		if exists == 0 {
+			return utils.NotFoundHandler(sql.ErrNoRows, "class not found")
+		}
+	}
+
+	return fillFreeSeats(tx, class.ID, info)
+}
+
+// A class is only deleted once it is empty. Deleting it would otherwise
+// drop its students out of any class and take their enrollments and places
+// on its waitlist with it.
+func DeleteOneClass(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "error starting transaction")
+	}
+	defer tx.Rollback()
+
+	var lockedId int
+	err = tx.QueryRow("SELECT id FROM classes WHERE id = ? FOR UPDATE", id).Scan(&lockedId)
+	if err == sql.ErrNoRows {
+		return utils.NotFoundHandler(err, "class was not found")
+	} else if err != nil {
+		return utils.ErrorHandler(err, "error retrieving class")
+	}
+
+	var students, waiting int
+	err = tx.QueryRow("SELECT (SELECT COUNT(*) FROM students WHERE class_id = ?), (SELECT COUNT(*) FROM class_waitlist WHERE class_id = ?)", id, id).
+		Scan(&students, &waiting)
+	if err != nil {
+		return utils.ErrorHandler(err, "error counting students of class")
+	}
+	if students > 0 {
+		return utils.ConflictHandler(nil, fmt.Sprintf("class still has %d students, move them to another class first", students))
+	}
+	if waiting > 0 {
+		return utils.ConflictHandler(nil, fmt.Sprintf("class still has %d students on its waitlist", waiting))
+	}
+
+	_, err = tx.Exec("DELETE FROM classes WHERE id = ?", id)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "error committing transaction")
+	}
+	return nil
+}
+
+func GetStudentsByClassIdFromDb(classId int) ([]models.Student, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	return queryClassStudents(db, classId)
+}
+
+func queryClassStudents(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, classId int) ([]models.Student, error) {
+	rows, err := q.Query("SELECT id, first_name, last_name, email, class_id FROM students WHERE class_id = ? AND deleted_at IS NULL", classId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	students := make([]models.Student, 0)
+	for rows.Next() {
+		var student models.Student
+		err := rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.ClassID)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		students = append(students, student)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return students, nil
+}
+
+// Teachers of a class: its homeroom teacher and everyone assigned to teach it
+func GetTeachersByClassIdFromDb(classId int) ([]models.Teacher, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	query := `SELECT id, first_name, last_name, email, subject FROM teachers WHERE deleted_at IS NULL AND id IN (
+		SELECT teacher_id FROM teaching_assignments WHERE class_id = ?
+		UNION
+		SELECT homeroom_teacher_id FROM classes WHERE id = ?
+	)`
+	rows, err := db.Query(query, classId, classId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	teachers := make([]models.Teacher, 0)
+	for rows.Next() {
+		var teacher models.Teacher
+		err := rows.Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Subject)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		teachers = append(teachers, teacher)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return teachers, nil
+}
@@ -0,0 +1,172 @@
+package sqlconnect
+
+import (
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// Weighted average per subject and term as a percentage. Each score counts
+// as score / max_score, weighted by the assessment's weight.
+const subjectAveragesQuery = `SELECT a.subject_id, COALESCE(a.term_id, 0),
+	SUM(s.score / a.max_score * a.weight) / SUM(a.weight) * 100
+	FROM scores s JOIN assessments a ON a.id = s.assessment_id
+	WHERE s.student_id = ?`
+
+func GetStudentGradesFromDb(studentId int, termId string) ([]models.StudentGrade, []models.SubjectAverage, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	args := []interface{}{studentId}
+	termFilter := ""
+	if termId != "" {
+		termFilter = " AND a.term_id = ?"
+		args = append(args, termId)
+	}
+
+	query := `SELECT a.id, a.class_id, a.subject_id, a.title, a.max_score, a.weight, a.due_date, COALESCE(a.term_id, 0), s.score
+	FROM scores s JOIN assessments a ON a.id = s.assessment_id
+	WHERE s.student_id = ?` + termFilter + ` ORDER BY a.due_date, a.id`
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	grades := make([]models.StudentGrade, 0)
+	for rows.Next() {
+		var grade models.StudentGrade
+		a := &grade.Assessment
+		err = rows.Scan(&a.ID, &a.ClassID, &a.SubjectID, &a.Title, &a.MaxScore, &a.Weight, &a.DueDate, &a.TermID, &grade.Score)
+		if err != nil {
+			return nil, nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		grades = append(grades, grade)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, nil, utils.ErrorHandler(err, "error with row")
+	}
+
+	avgRows, err := db.Query(subjectAveragesQuery+termFilter+" GROUP BY a.subject_id, a.term_id ORDER BY a.term_id, a.subject_id", args...)
+	if err != nil {
+		return nil, nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer avgRows.Close()
+
+	averages := make([]models.SubjectAverage, 0)
+	for avgRows.Next() {
+		var average models.SubjectAverage
+		err = avgRows.Scan(&average.SubjectID, &average.TermID, &average.WeightedAverage)
+		if err != nil {
+			return nil, nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		averages = append(averages, average)
+	}
+	err = avgRows.Err()
+	if err != nil {
+		return nil, nil, utils.ErrorHandler(err, "error with row")
+	}
+	return grades, averages, nil
+}
+
+// Builds the gradebook of a class, optionally limited to one subject and/or
+// term. Students without a score for an assessment get a nil cell. It is read
+// in one transaction, so the roster and the scores agree with each other.
+func GetGradebookFromDb(classId int, subjectId string, termId string) (models.Gradebook, error) {
+	gradebook := models.Gradebook{ClassID: classId}
+
+	db, err := ConnectDb()
+	if err != nil {
+		return gradebook, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return gradebook, utils.ErrorHandler(err, "error starting transaction")
+	}
+	defer tx.Rollback()
+
+	query := " AND class_id = ?"
+	args := []interface{}{classId}
+	if subjectId != "" {
+		query += " AND subject_id = ?"
+		args = append(args, subjectId)
+	}
+	if termId != "" {
+		query += " AND term_id = ?"
+		args = append(args, termId)
+	}
+
+	rows, err := tx.Query("SELECT "+assessmentColumns+" FROM assessments WHERE 1=1"+query+" ORDER BY due_date, id", args...)
+	if err != nil {
+		return gradebook, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	column := make(map[int]int)
+	gradebook.Assessments = make([]models.Assessment, 0)
+	for rows.Next() {
+		var assessment models.Assessment
+		err = scanAssessment(rows, &assessment)
+		if err != nil {
+			return gradebook, utils.ErrorHandler(err, "error with scanning row")
+		}
+		column[assessment.ID] = len(gradebook.Assessments)
+		gradebook.Assessments = append(gradebook.Assessments, assessment)
+	}
+	err = rows.Err()
+	if err != nil {
+		return gradebook, utils.ErrorHandler(err, "error with row")
+	}
+
+	students, err := queryClassStudents(tx, classId)
+	if err != nil {
+		return gradebook, err
+	}
+
+	row := make(map[int]int)
+	gradebook.Rows = make([]models.GradebookRow, len(students))
+	for i, student := range students {
+		row[student.ID] = i
+		gradebook.Rows[i] = models.GradebookRow{
+			Student: student,
+			Scores:  make([]*float64, len(gradebook.Assessments)),
+		}
+	}
+
+	scoreRows, err := tx.Query(`SELECT s.assessment_id, s.student_id, s.score FROM scores s
+		JOIN students st ON st.id = s.student_id
+		JOIN assessments a ON a.id = s.assessment_id
+		WHERE st.class_id = ? AND a.class_id = ?`, classId, classId)
+	if err != nil {
+		return gradebook, utils.ErrorHandler(err, "error with query")
+	}
+	defer scoreRows.Close()
+
+	for scoreRows.Next() {
+		var assessmentId, studentId int
+		var score float64
+		err = scoreRows.Scan(&assessmentId, &studentId, &score)
+		if err != nil {
+			return gradebook, utils.ErrorHandler(err, "error with scanning row")
+		}
+		c, ok := column[assessmentId]
+		if !ok {
+			continue
+		}
+		r, ok := row[studentId]
+		if !ok {
+			continue
+		}
+		gradebook.Rows[r].Scores[c] = &score
+	}
+	err = scoreRows.Err()
+	if err != nil {
+		return gradebook, utils.ErrorHandler(err, "error with row")
+	}
+	return gradebook, nil
+}
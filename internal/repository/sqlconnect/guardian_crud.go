@@ -0,0 +1,317 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+func GetGuardiansFromDb(query string, args []interface{}) ([]models.Guardian, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, name, phone, email FROM guardians WHERE 1=1"+query, args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	guardians := make([]models.Guardian, 0)
+	for rows.Next() {
+		var guardian models.Guardian
+		err = rows.Scan(&guardian.ID, &guardian.Name, &guardian.Phone, &guardian.Email)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		guardians = append(guardians, guardian)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return guardians, nil
+}
+
+func GetOneGuardian(id int) (models.Guardian, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Guardian{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var guardian models.Guardian
+	err = db.QueryRow("SELECT id, name, phone, email FROM guardians WHERE id = ?", id).Scan(&guardian.ID, &guardian.Name, &guardian.Phone, &guardian.Email)
+	if err == sql.ErrNoRows {
+		return models.Guardian{}, utils.ErrorHandler(err, "error guardian not found")
+	} else if err != nil {
+		return models.Guardian{}, utils.ErrorHandler(err, "error getting guardian from database")
+	}
+	return guardian, nil
+}
+
+func AddGuardians(newGuardians []models.Guardian) ([]models.Guardian, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare(utils.GenerateInsertQuery("guardians", models.Guardian{}))
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	addedGuardians := make([]models.Guardian, len(newGuardians))
+	for i, newGuardian := range newGuardians {
+		res, err := stmt.Exec(newGuardian.Name, newGuardian.Phone, newGuardian.Email)
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newGuardian.ID = int(lastId)
+		addedGuardians[i] = newGuardian
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedGuardians, nil
+}
+
+func UpdateGuardian(id int, updateGuardian models.Guardian) (models.Guardian, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Guardian{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var exists int
+	err = db.QueryRow("SELECT COUNT(*) FROM guardians WHERE id = ?", id).Scan(&exists)
+	if err != nil {
+		return models.Guardian{}, utils.ErrorHandler(err, "error retrieving guardian from database")
+	}
+	if exists == 0 {
+		return models.Guardian{}, utils.ErrorHandler(sql.ErrNoRows, "guardian not found")
+	}
+
+	updateGuardian.ID = id
+	_, err = db.Exec("UPDATE guardians SET name = ?, phone = ?, email = ? WHERE id = ?", updateGuardian.Name, updateGuardian.Phone, updateGuardian.Email, id)
+	if err != nil {
+		return models.Guardian{}, utils.ErrorHandler(err, "error updating guardian")
+	}
+	return updateGuardian, nil
+}
+
+// A guardian cannot be deleted while they are the only primary contact of
+// any student
+func DeleteOneGuardian(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	rows, err := tx.Query("SELECT student_id FROM student_guardians WHERE guardian_id = ? AND is_primary FOR UPDATE", id)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "error retrieving guardian links")
+	}
+	var studentIds []int
+	for rows.Next() {
+		var studentId int
+		err = rows.Scan(&studentId)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return utils.ErrorHandler(err, "error scanning database results")
+		}
+		studentIds = append(studentIds, studentId)
+	}
+	rows.Close()
+
+	for _, studentId := range studentIds {
+		err = checkOtherPrimary(tx, studentId, id)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM guardians WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "guardian was not found")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "error committing transaction")
+	}
+	return nil
+}
+
+// Fails unless the student has a primary contact other than guardianId. Rows
+// are locked so concurrent changes cannot both remove "the other" primary.
+func checkOtherPrimary(tx *sql.Tx, studentId int, guardianId int) error {
+	var others int
+	err := tx.QueryRow("SELECT COUNT(*) FROM student_guardians WHERE student_id = ? AND guardian_id <> ? AND is_primary FOR UPDATE", studentId, guardianId).Scan(&others)
+	if err != nil {
+		return utils.ErrorHandler(err, "error retrieving guardian links")
+	}
+	if others == 0 {
+		return utils.ConflictHandler(nil, fmt.Sprintf("guardian %d is the only primary contact of student %d, assign another primary contact first", guardianId, studentId))
+	}
+	return nil
+}
+
+func GetGuardiansByStudentIdFromDb(studentId int) ([]models.StudentContact, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT g.id, g.name, g.phone, g.email, sg.relationship, sg.is_primary
+		FROM student_guardians sg JOIN guardians g ON g.id = sg.guardian_id
+		WHERE sg.student_id = ? ORDER BY sg.is_primary DESC, g.name`, studentId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	contacts := make([]models.StudentContact, 0)
+	for rows.Next() {
+		var c models.StudentContact
+		err = rows.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.Relationship, &c.IsPrimary)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		contacts = append(contacts, c)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return contacts, nil
+}
+
+// Adds or updates the link between a student and a guardian. Unsetting the
+// primary flag is refused when it would leave the student without a primary
+// contact.
+func SaveStudentGuardian(link models.StudentGuardian) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	var wasPrimary bool
+	err = tx.QueryRow("SELECT is_primary FROM student_guardians WHERE student_id = ? AND guardian_id = ? FOR UPDATE", link.StudentID, link.GuardianID).Scan(&wasPrimary)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "error retrieving guardian link")
+	}
+
+	if wasPrimary && !link.IsPrimary {
+		err = checkOtherPrimary(tx, link.StudentID, link.GuardianID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO student_guardians (student_id, guardian_id, relationship, is_primary) VALUES (?,?,?,?)
+		ON DUPLICATE KEY UPDATE relationship = VALUES(relationship), is_primary = VALUES(is_primary)`,
+		link.StudentID, link.GuardianID, link.Relationship, link.IsPrimary)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "error saving guardian link")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "error committing transaction")
+	}
+	return nil
+}
+
+func DeleteStudentGuardian(studentId int, guardianId int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	var isPrimary bool
+	err = tx.QueryRow("SELECT is_primary FROM student_guardians WHERE student_id = ? AND guardian_id = ? FOR UPDATE", studentId, guardianId).Scan(&isPrimary)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "guardian is not linked to student")
+	} else if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "error retrieving guardian link")
+	}
+
+	if isPrimary {
+		err = checkOtherPrimary(tx, studentId, guardianId)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM student_guardians WHERE student_id = ? AND guardian_id = ?", studentId, guardianId)
+	if err != nil {
+		tx.Rollback()
+		return utils.ErrorHandler(err, "error deleting guardian link")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "error committing transaction")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package sqlconnect
+
+import (
+	"strings"
+
+	"restapi/pkg/utils"
+)
+
+// Which of emails rows of table that are not deleted already use, in lower
+// case. table is teachers or students.
+func TakenEmails(table string, emails []string) (map[string]bool, error) {
+	taken := make(map[string]bool)
+	if len(emails) == 0 {
+		return taken, nil
+	}
+
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	args := make([]interface{}, len(emails))
+	for i, email := range emails {
+		args[i] = email
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(emails)), ",")
+	rows, err := db.Query("SELECT email FROM "+table+" WHERE deleted_at IS NULL AND email IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error retrieving emails")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email string
+		err = rows.Scan(&email)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning emails")
+		}
+		taken[strings.ToLower(email)] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error retrieving emails")
+	}
+	return taken, nil
+}
+
+// The free seats of each of the classes, -1 for a class without a limit.
+// Ids that are not a class are left out.
+func FreeSeats(classIds []int) (map[int]int, error) {
+	seats := make(map[int]int)
+	if len(classIds) == 0 {
+		return seats, nil
+	}
+
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	args := make([]interface{}, len(classIds))
+	for i, id := range classIds {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(classIds)), ",")
+	rows, err := db.Query(`SELECT c.id, c.capacity, (SELECT COUNT(*) FROM students s WHERE s.class_id = c.id)
+		FROM classes c WHERE c.id IN (`+placeholders+")", args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error retrieving classes")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, capacity, students int
+		err = rows.Scan(&id, &capacity, &students)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning classes")
+		}
+		if capacity == 0 {
+			seats[id] = -1
+		} else {
+			seats[id] = max(capacity-students, 0)
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error retrieving classes")
+	}
+	return seats, nil
+}
@@ -0,0 +1,93 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+	"time"
+)
+
+// Photo tables by owner, owner is "student" or "teacher"
+var photoTables = map[string]struct{ table, column, owners string }{
+	"student": {"student_photos", "student_id", "students"},
+	"teacher": {"teacher_photos", "teacher_id", "teachers"},
+}
+
+func GetPhotoFromDb(owner string, ownerId int) (models.Photo, error) {
+	t, ok := photoTables[owner]
+	if !ok {
+		return models.Photo{}, utils.ErrorHandler(fmt.Errorf("unknown photo owner %q", owner), "invalid photo owner")
+	}
+
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Photo{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var photo models.Photo
+	err = db.QueryRow("SELECT "+t.column+", photo_key, thumbnail_key, content_type, width, height, updated_at FROM "+t.table+" WHERE "+t.column+" = ?", ownerId).
+		Scan(&photo.OwnerID, &photo.Key, &photo.ThumbnailKey, &photo.ContentType, &photo.Width, &photo.Height, &photo.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return models.Photo{}, utils.ErrorHandler(err, owner+" has no photo")
+	} else if err != nil {
+		return models.Photo{}, utils.ErrorHandler(err, "error getting photo from database")
+	}
+	return photo, nil
+}
+
+// Saves the photo of a student or teacher, replacing the previous one.
+// Returns the replaced photo so its blobs can be removed, or a zero Photo
+// when there was none.
+func SavePhoto(owner string, photo models.Photo) (models.Photo, models.Photo, error) {
+	t, ok := photoTables[owner]
+	if !ok {
+		return models.Photo{}, models.Photo{}, utils.ErrorHandler(fmt.Errorf("unknown photo owner %q", owner), "invalid photo owner")
+	}
+
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Photo{}, models.Photo{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Photo{}, models.Photo{}, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	var exists int
+	err = tx.QueryRow("SELECT COUNT(*) FROM "+t.owners+" WHERE id = ?", photo.OwnerID).Scan(&exists)
+	if err != nil {
+		tx.Rollback()
+		return models.Photo{}, models.Photo{}, utils.ErrorHandler(err, "error retrieving "+owner+" from database")
+	}
+	if exists == 0 {
+		tx.Rollback()
+		return models.Photo{}, models.Photo{}, utils.ErrorHandler(sql.ErrNoRows, owner+" not found")
+	}
+
+	var old models.Photo
+	err = tx.QueryRow("SELECT photo_key, thumbnail_key FROM "+t.table+" WHERE "+t.column+" = ? FOR UPDATE", photo.OwnerID).Scan(&old.Key, &old.ThumbnailKey)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return models.Photo{}, models.Photo{}, utils.ErrorHandler(err, "error retrieving photo")
+	}
+
+	photo.UpdatedAt = time.Now().Format(time.DateTime)
+	_, err = tx.Exec("INSERT INTO "+t.table+" ("+t.column+`, photo_key, thumbnail_key, content_type, width, height, updated_at) VALUES (?,?,?,?,?,?,?)
+		ON DUPLICATE KEY UPDATE photo_key = VALUES(photo_key), thumbnail_key = VALUES(thumbnail_key), content_type = VALUES(content_type),
+		width = VALUES(width), height = VALUES(height), updated_at = VALUES(updated_at)`,
+		photo.OwnerID, photo.Key, photo.ThumbnailKey, photo.ContentType, photo.Width, photo.Height, photo.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		return models.Photo{}, models.Photo{}, utils.ErrorHandler(err, "error saving photo")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Photo{}, models.Photo{}, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return photo, old, nil
+}
@@ -0,0 +1,248 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// Moves the students of a class into the next grade for a new academic year.
+// Everything happens in one transaction. For a dry run the transaction is
+// rolled back at the end, so the result is an exact preview of what would
+// change (a class that would be created is returned without an id).
+//
+// Students leave their old class with an ended enrollment, so the old
+// membership stays queryable. The class they move into keeps to its
+// capacity: once it is full, the rest of the students stay where they are
+// and are put on its waitlist, as placeStudent does.
+func PromoteClass(req models.PromotionRequest, info models.AuditInfo) (models.PromotionResult, error) {
+	result := models.PromotionResult{DryRun: req.DryRun}
+
+	db, err := ConnectDb()
+	if err != nil {
+		return result, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, utils.ErrorHandler(err, "error starting transaction")
+	}
+	defer tx.Rollback()
+
+	err = scanClass(tx.QueryRow("SELECT "+classColumns+" FROM classes WHERE id = ? FOR UPDATE", req.FromClassID), &result.FromClass)
+	if err == sql.ErrNoRows {
+		return result, utils.InvalidHandler(err, "class to promote from not found")
+	} else if err != nil {
+		return result, utils.ErrorHandler(err, "error retrieving class")
+	}
+
+	var newYear models.AcademicYear
+	err = tx.QueryRow("SELECT id, name, start_date, end_date FROM academic_years WHERE id = ?", req.ToAcademicYearID).Scan(&newYear.ID, &newYear.Name, &newYear.StartDate, &newYear.EndDate)
+	if err == sql.ErrNoRows {
+		return result, utils.InvalidHandler(err, "academic year to promote into not found")
+	} else if err != nil {
+		return result, utils.ErrorHandler(err, "error retrieving academic year")
+	}
+	if result.FromClass.AcademicYearID == newYear.ID {
+		return result, utils.InvalidHandler(nil, "class already belongs to that academic year")
+	}
+
+	result.ToClass, result.CreatedClass, err = findOrCreateNextClass(tx, req, result.FromClass)
+	if err != nil {
+		return result, err
+	}
+
+	excluded := make(map[int]bool)
+	for _, id := range req.Exclude {
+		excluded[id] = true
+	}
+
+	rows, err := tx.Query("SELECT id, first_name, last_name, email, class_id FROM students WHERE class_id = ? FOR UPDATE", req.FromClassID)
+	if err != nil {
+		return result, utils.ErrorHandler(err, "error retrieving students")
+	}
+	var promoting []models.Student
+	result.Promoted = make([]models.Student, 0)
+	result.Waitlisted = make([]models.WaitlistEntry, 0)
+	result.HeldBack = make([]models.Student, 0)
+	for rows.Next() {
+		var student models.Student
+		err = rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.ClassID)
+		if err != nil {
+			rows.Close()
+			return result, utils.ErrorHandler(err, "error scanning database results")
+		}
+		if excluded[student.ID] {
+			result.HeldBack = append(result.HeldBack, student)
+		} else {
+			promoting = append(promoting, student)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return result, utils.ErrorHandler(err, "error retrieving students")
+	}
+
+	for _, student := range promoting {
+		hasSeat, err := classHasSeat(tx, result.ToClass.ID)
+		if err != nil {
+			return result, err
+		}
+		if !hasSeat {
+			entry, err := enqueueStudent(tx, student.ID, result.ToClass.ID)
+			if err != nil {
+				return result, err
+			}
+			result.Waitlisted = append(result.Waitlisted, *entry)
+			continue
+		}
+
+		err = moveStudentToClass(tx, student.ID, result.ToClass.ID, newYear.StartDate, "promotion to "+newYear.Name, info)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.Exec("DELETE FROM class_waitlist WHERE student_id = ?", student.ID)
+		if err != nil {
+			return result, utils.ErrorHandler(err, "error removing student from waitlist")
+		}
+		student.ClassID = result.ToClass.ID
+		result.Promoted = append(result.Promoted, student)
+	}
+
+	if req.DryRun {
+		// Waitlist entries of a dry run are never stored
+		for i := range result.Waitlisted {
+			result.Waitlisted[i].ID = 0
+		}
+		if result.CreatedClass {
+			result.ToClass.ID = 0
+			for i := range result.Promoted {
+				result.Promoted[i].ClassID = 0
+			}
+			for i := range result.Waitlisted {
+				result.Waitlisted[i].ClassID = 0
+			}
+		}
+		return result, nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return result, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return result, nil
+}
+
+// Uses the requested class, or the class one grade up in the same section of
+// the new year, creating it when it does not exist yet.
+func findOrCreateNextClass(tx *sql.Tx, req models.PromotionRequest, from models.Class) (models.Class, bool, error) {
+	var next models.Class
+	if req.ToClassID != 0 {
+		err := scanClass(tx.QueryRow("SELECT "+classColumns+" FROM classes WHERE id = ?", req.ToClassID), &next)
+		if err == sql.ErrNoRows {
+			return next, false, utils.InvalidHandler(err, "class to promote into not found")
+		} else if err != nil {
+			return next, false, utils.ErrorHandler(err, "error retrieving class")
+		}
+		if next.AcademicYearID != req.ToAcademicYearID {
+			return next, false, utils.InvalidHandler(nil, "class to promote into is not in the new academic year")
+		}
+		return next, false, nil
+	}
+
+	err := scanClass(tx.QueryRow("SELECT "+classColumns+" FROM classes WHERE grade = ? AND section = ? AND academic_year_id = ?",
+		from.Grade+1, from.Section, req.ToAcademicYearID), &next)
+	if err == nil {
+		return next, false, nil
+	} else if err != sql.ErrNoRows {
+		return next, false, utils.ErrorHandler(err, "error retrieving class")
+	}
+
+	next = models.Class{
+		Name:           fmt.Sprintf("%d%s", from.Grade+1, from.Section),
+		Grade:          from.Grade + 1,
+		Section:        from.Section,
+		Capacity:       from.Capacity,
+		AcademicYearID: req.ToAcademicYearID,
+	}
+	res, err := tx.Exec("INSERT INTO classes (name, grade, section, room, capacity, academic_year_id) VALUES (?,?,?,?,?,?)",
+		next.Name, next.Grade, next.Section, next.Room, next.Capacity, next.AcademicYearID)
+	if err != nil {
+		return next, false, utils.ErrorHandler(err, "error creating class for the new year")
+	}
+	lastId, err := res.LastInsertId()
+	if err != nil {
+		return next, false, utils.ErrorHandler(err, "error getting last insert id")
+	}
+	next.ID = int(lastId)
+	return next, true, nil
+}
+
+// Ends the student's current enrollment on the given date and starts one in
+// the new class. reason and the actor of info are kept in the student's
+// history, the move is recorded in the audit log.
+func moveStudentToClass(tx *sql.Tx, studentId int, classId int, on string, reason string, info models.AuditInfo) error {
+	var fromClassId int
+	err := tx.QueryRow("SELECT COALESCE(class_id, 0) FROM students WHERE id = ?", studentId).Scan(&fromClassId)
+	if err != nil {
+		return utils.ErrorHandler(err, "error retrieving student")
+	}
+
+	_, err = tx.Exec("UPDATE enrollments SET ended_on = ? WHERE student_id = ? AND ended_on IS NULL", on, studentId)
+	if err != nil {
+		return utils.ErrorHandler(err, "error ending enrollment")
+	}
+
+	_, err = tx.Exec("INSERT INTO enrollments (student_id, class_id, started_on, from_class_id, reason, actor) VALUES (?,?,?,?,?,?)",
+		studentId, classId, on, nullableId(fromClassId), reason, info.Actor)
+	if err != nil {
+		return utils.ErrorHandler(err, "error adding enrollment")
+	}
+
+	_, err = tx.Exec("UPDATE students SET class_id = ?, version = version + 1 WHERE id = ?", classId, studentId)
+	if err != nil {
+		return utils.ErrorHandler(err, "error moving student")
+	}
+	return writeAudit(tx, info, "move", "student", studentId,
+		models.Student{ID: studentId, ClassID: fromClassId}, models.Student{ID: studentId, ClassID: classId})
+}
+
+const enrollmentColumns = "id, student_id, class_id, started_on, COALESCE(ended_on, ''), COALESCE(from_class_id, 0), reason, actor"
+
+func scanEnrollment(row interface{ Scan(...interface{}) error }, enrollment *models.Enrollment) error {
+	return row.Scan(&enrollment.ID, &enrollment.StudentID, &enrollment.ClassID, &enrollment.StartedOn, &enrollment.EndedOn,
+		&enrollment.FromClassID, &enrollment.Reason, &enrollment.Actor)
+}
+
+// Everyone who has ever been in the class, current students included
+func GetEnrollmentsByClassIdFromDb(classId int) ([]models.Enrollment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+enrollmentColumns+" FROM enrollments WHERE class_id = ? ORDER BY started_on, student_id", classId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	enrollments := make([]models.Enrollment, 0)
+	for rows.Next() {
+		var enrollment models.Enrollment
+		err = scanEnrollment(rows, &enrollment)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return enrollments, nil
+}
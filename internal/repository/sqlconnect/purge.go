@@ -0,0 +1,145 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+	"time"
+)
+
+// Permanently removes the teachers and students soft deleted before cutoff,
+// together with everything that cascades from them. Their photos and
+// submitted files are in the blob store, their keys are returned for the
+// caller to remove. Every purged row is recorded in the audit log with info.
+func PurgeDeleted(cutoff time.Time, info models.AuditInfo) (models.PurgeResult, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.PurgeResult{}, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.PurgeResult{}, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	before := cutoff.Format(time.DateTime)
+	result := models.PurgeResult{BlobKeys: make([]string, 0)}
+	keyQueries := []string{
+		"SELECT photo_key FROM teacher_photos p JOIN teachers t ON t.id = p.teacher_id WHERE t.deleted_at < ?",
+		"SELECT thumbnail_key FROM teacher_photos p JOIN teachers t ON t.id = p.teacher_id WHERE t.deleted_at < ?",
+		"SELECT photo_key FROM student_photos p JOIN students s ON s.id = p.student_id WHERE s.deleted_at < ?",
+		"SELECT thumbnail_key FROM student_photos p JOIN students s ON s.id = p.student_id WHERE s.deleted_at < ?",
+		"SELECT file_key FROM submissions sub JOIN students s ON s.id = sub.student_id WHERE s.deleted_at < ?",
+	}
+	for _, query := range keyQueries {
+		rows, err := tx.Query(query, before)
+		if err != nil {
+			tx.Rollback()
+			return models.PurgeResult{}, utils.ErrorHandler(err, "error with query")
+		}
+		for rows.Next() {
+			var key string
+			err = rows.Scan(&key)
+			if err != nil {
+				rows.Close()
+				tx.Rollback()
+				return models.PurgeResult{}, utils.ErrorHandler(err, "error with scanning row")
+			}
+			result.BlobKeys = append(result.BlobKeys, key)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			tx.Rollback()
+			return models.PurgeResult{}, utils.ErrorHandler(err, "error with row")
+		}
+	}
+
+	err = auditPurge(tx, info, "teacher", "SELECT id, first_name, last_name, email, subject, deleted_at, version FROM teachers WHERE deleted_at < ? FOR UPDATE",
+		before, func(rows *sql.Rows) (int, interface{}, error) {
+			var teacher models.Teacher
+			var deletedAt string
+			err := rows.Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Subject, &deletedAt, &teacher.Version)
+			teacher.DeletedAt = &deletedAt
+			return teacher.ID, teacher, err
+		})
+	if err != nil {
+		tx.Rollback()
+		return models.PurgeResult{}, err
+	}
+	err = auditPurge(tx, info, "student", "SELECT id, first_name, last_name, email, deleted_at, version FROM students WHERE deleted_at < ? FOR UPDATE",
+		before, func(rows *sql.Rows) (int, interface{}, error) {
+			var student models.Student
+			var deletedAt string
+			err := rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &deletedAt, &student.Version)
+			student.DeletedAt = &deletedAt
+			return student.ID, student, err
+		})
+	if err != nil {
+		tx.Rollback()
+		return models.PurgeResult{}, err
+	}
+
+	res, err := tx.Exec("DELETE FROM teachers WHERE deleted_at < ?", before)
+	if err != nil {
+		tx.Rollback()
+		return models.PurgeResult{}, utils.ErrorHandler(err, "error purging teachers")
+	}
+	result.Teachers, err = res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return models.PurgeResult{}, utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	res, err = tx.Exec("DELETE FROM students WHERE deleted_at < ?", before)
+	if err != nil {
+		tx.Rollback()
+		return models.PurgeResult{}, utils.ErrorHandler(err, "error purging students")
+	}
+	result.Students, err = res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return models.PurgeResult{}, utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.PurgeResult{}, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return result, nil
+}
+
+// Writes a "purge" audit entry for each row query finds, before they are
+// gone. The rows are read first, the connection cannot write while they are
+// open.
+func auditPurge(tx *sql.Tx, info models.AuditInfo, entity string, query string, before string, scan func(rows *sql.Rows) (int, interface{}, error)) error {
+	rows, err := tx.Query(query, before)
+	if err != nil {
+		return utils.ErrorHandler(err, "error with query")
+	}
+	var ids []int
+	var purged []interface{}
+	for rows.Next() {
+		id, row, err := scan(rows)
+		if err != nil {
+			rows.Close()
+			return utils.ErrorHandler(err, "error with scanning row")
+		}
+		ids = append(ids, id)
+		purged = append(purged, row)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return utils.ErrorHandler(err, "error with row")
+	}
+
+	for i, id := range ids {
+		err = writeAudit(tx, info, "purge", entity, id, purged[i], nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// Collects a student's report card for a term. Attendance is counted within
+// the dates of the term.
+func GetReportCardFromDb(studentId int, termId int) (models.ReportCard, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.ReportCard{}, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	card := models.ReportCard{TermID: termId}
+	var startDate, endDate string
+	err = db.QueryRow("SELECT name, start_date, end_date FROM terms WHERE id = ?", termId).Scan(&card.Term, &startDate, &endDate)
+	if err == sql.ErrNoRows {
+		return models.ReportCard{}, utils.InvalidHandler(err, "term not found")
+	} else if err != nil {
+		return models.ReportCard{}, utils.ErrorHandler(err, "error retrieving term")
+	}
+
+	s := &card.Student
+	err = db.QueryRow(`SELECT s.id, s.first_name, s.last_name, s.email, COALESCE(s.class_id, 0), COALESCE(c.name, '')
+		FROM students s LEFT JOIN classes c ON c.id = s.class_id WHERE s.id = ? AND s.deleted_at IS NULL`, studentId).
+		Scan(&s.ID, &s.FirstName, &s.LastName, &s.Email, &s.ClassID, &card.ClassName)
+	if err == sql.ErrNoRows {
+		return models.ReportCard{}, utils.ErrorHandler(err, "student not found")
+	} else if err != nil {
+		return models.ReportCard{}, utils.ErrorHandler(err, "error retrieving student")
+	}
+
+	rows, err := db.Query(`SELECT sub.name, COUNT(*), SUM(s.score / a.max_score * a.weight) / SUM(a.weight) * 100
+		FROM scores s JOIN assessments a ON a.id = s.assessment_id JOIN subjects sub ON sub.id = a.subject_id
+		WHERE s.student_id = ? AND a.term_id = ?
+		GROUP BY sub.id, sub.name ORDER BY sub.name`, studentId, termId)
+	if err != nil {
+		return models.ReportCard{}, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	card.Subjects = make([]models.ReportCardSubject, 0)
+	for rows.Next() {
+		var subject models.ReportCardSubject
+		err = rows.Scan(&subject.Subject, &subject.Assessments, &subject.Average)
+		if err != nil {
+			return models.ReportCard{}, utils.ErrorHandler(err, "error with scanning row")
+		}
+		card.Subjects = append(card.Subjects, subject)
+	}
+	err = rows.Err()
+	if err != nil {
+		return models.ReportCard{}, utils.ErrorHandler(err, "error with row")
+	}
+
+	a := &card.Attendance
+	a.StudentID = studentId
+	var present, absent, late, excused sql.NullInt64
+	err = db.QueryRow(`SELECT SUM(status = 'present'), SUM(status = 'absent'), SUM(status = 'late'), SUM(status = 'excused'),
+		COUNT(*), `+attendancePercentage+` FROM attendance WHERE student_id = ? AND date BETWEEN ? AND ?`,
+		studentId, startDate, endDate).Scan(&present, &absent, &late, &excused, &a.Total, &a.Percentage)
+	if err != nil {
+		return models.ReportCard{}, utils.ErrorHandler(err, "error retrieving attendance")
+	}
+	a.Present, a.Absent, a.Late, a.Excused = int(present.Int64), int(absent.Int64), int(late.Int64), int(excused.Int64)
+
+	commentRows, err := db.Query(`SELECT CONCAT(t.first_name, ' ', t.last_name), t.subject, rc.comment
+		FROM report_comments rc JOIN teachers t ON t.id = rc.teacher_id AND t.deleted_at IS NULL
+		WHERE rc.student_id = ? AND rc.term_id = ? ORDER BY t.subject, t.last_name`, studentId, termId)
+	if err != nil {
+		return models.ReportCard{}, utils.ErrorHandler(err, "error with query")
+	}
+	defer commentRows.Close()
+
+	card.Comments = make([]models.ReportCardComment, 0)
+	for commentRows.Next() {
+		var comment models.ReportCardComment
+		err = commentRows.Scan(&comment.Teacher, &comment.Subject, &comment.Comment)
+		if err != nil {
+			return models.ReportCard{}, utils.ErrorHandler(err, "error with scanning row")
+		}
+		card.Comments = append(card.Comments, comment)
+	}
+	err = commentRows.Err()
+	if err != nil {
+		return models.ReportCard{}, utils.ErrorHandler(err, "error with row")
+	}
+	return card, nil
+}
+
+// Adds or replaces a teacher's comment for a student and term
+func SaveReportComment(comment models.ReportComment) (models.ReportComment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.ReportComment{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`INSERT INTO report_comments (student_id, teacher_id, term_id, comment) VALUES (?,?,?,?)
+		ON DUPLICATE KEY UPDATE comment = VALUES(comment)`,
+		comment.StudentID, comment.TeacherID, comment.TermID, comment.Comment)
+	if err != nil {
+		return models.ReportComment{}, utils.ErrorHandler(err, "error saving report comment")
+	}
+
+	err = db.QueryRow("SELECT id FROM report_comments WHERE student_id = ? AND teacher_id = ? AND term_id = ?",
+		comment.StudentID, comment.TeacherID, comment.TermID).Scan(&comment.ID)
+	if err != nil {
+		return models.ReportComment{}, utils.ErrorHandler(err, "error retrieving report comment")
+	}
+	return comment, nil
+}
@@ -2,10 +2,11 @@ package sqlconnect
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 )
 
@@ -26,3 +27,9 @@ func ConnectDb() (*sql.DB, error) {
 	fmt.Println("Connected to mariadb")
 	return db, nil
 }
+
+// Reports if err is mariadb's "Duplicate entry" error for a unique key
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
@@ -0,0 +1,323 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+	"sort"
+	"strings"
+)
+
+// The dimensions a statistic can be grouped by, keyed by the group_by value.
+// Only these expressions are put into the queries, group_by itself never is.
+const classLabel = "CONCAT(c.name, COALESCE(CONCAT(' (', y.name, ')'), ''))"
+
+var (
+	classGroups = map[string]string{
+		"class":         classLabel,
+		"grade":         "c.grade",
+		"academic_year": "y.name",
+	}
+	teacherGroups = map[string]string{
+		"subject":       "sub.name",
+		"term":          "tm.name",
+		"class":         classLabel,
+		"grade":         "c.grade",
+		"academic_year": "y.name",
+	}
+	attendanceGroups = map[string]string{
+		"class":         classLabel,
+		"grade":         "c.grade",
+		"academic_year": "y.name",
+		"student":       "CONCAT(s.first_name, ' ', s.last_name, ' (', s.id, ')')",
+		"day":           "a.date",
+		"week":          "DATE_FORMAT(a.date, '%x-W%v')",
+		"month":         "DATE_FORMAT(a.date, '%Y-%m')",
+	}
+	gradeGroups = map[string]string{
+		"class":         classLabel,
+		"grade":         "c.grade",
+		"academic_year": "y.name",
+		"subject":       "sub.name",
+		"term":          "tm.name",
+		"student":       "CONCAT(s.first_name, ' ', s.last_name, ' (', s.id, ')')",
+		"month":         "DATE_FORMAT(a.due_date, '%Y-%m')",
+	}
+)
+
+func groupExpr(groups map[string]string, groupBy string) (string, error) {
+	expr, ok := groups[groupBy]
+	if !ok {
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", utils.InvalidHandler(nil, "group_by must be one of "+strings.Join(names, ", "))
+	}
+	return expr, nil
+}
+
+// Limits column to the date range of the filter
+func statsDateRange(column string, f models.StatsFilter) (string, []interface{}) {
+	query := ""
+	var args []interface{}
+	if f.From != "" {
+		query += " AND " + column + " >= ?"
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		query += " AND " + column + " <= ?"
+		args = append(args, f.To)
+	}
+	return query, args
+}
+
+// Joins the students of class c as m.student_id. Without a date range these
+// are the students in the class now, with one every student enrolled in the
+// class at some point in the range.
+func classMembers(f models.StatsFilter) (string, []interface{}) {
+	if f.From == "" && f.To == "" {
+		return " LEFT JOIN (SELECT id AS student_id, class_id FROM students) m ON m.class_id = c.id", nil
+	}
+	from, to := f.From, f.To
+	if from == "" {
+		from = "0001-01-01"
+	}
+	if to == "" {
+		to = "9999-12-31"
+	}
+	return " LEFT JOIN enrollments m ON m.class_id = c.id AND m.started_on <= ? AND (m.ended_on IS NULL OR m.ended_on > ?)",
+		[]interface{}{to, from}
+}
+
+// Keeps teaching assignments whose term overlaps the date range. Assignments
+// without a term run all year and are always kept.
+func teachingTermRange(f models.StatsFilter) (string, []interface{}) {
+	if f.From == "" && f.To == "" {
+		return "", nil
+	}
+	from, to := f.From, f.To
+	if from == "" {
+		from = "0001-01-01"
+	}
+	if to == "" {
+		to = "9999-12-31"
+	}
+	return " AND (ta.term_id IS NULL OR ta.term_id IN (SELECT id FROM terms WHERE start_date <= ? AND end_date >= ?))",
+		[]interface{}{to, from}
+}
+
+// Runs a statistics query and hands every row to scan
+func queryStats(query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return utils.ErrorHandler(err, "error scanning database results")
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return utils.ErrorHandler(err, "error with row")
+	}
+	return nil
+}
+
+func nullableFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+func GetStudentCountStats(f models.StatsFilter) ([]models.StudentCountStat, error) {
+	group, err := groupExpr(classGroups, f.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	members, args := classMembers(f)
+
+	stats := make([]models.StudentCountStat, 0)
+	err = queryStats(`SELECT `+group+`, COUNT(DISTINCT m.student_id)
+		FROM classes c LEFT JOIN academic_years y ON y.id = c.academic_year_id`+members+`
+		GROUP BY 1 ORDER BY 1`, args, func(rows *sql.Rows) error {
+		var stat models.StudentCountStat
+		var label sql.NullString
+		err := rows.Scan(&label, &stat.Students)
+		stat.Group = label.String
+		stats = append(stats, stat)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Counts the distinct teachers with a teaching assignment in each group
+func GetTeacherCountStats(f models.StatsFilter) ([]models.TeacherCountStat, error) {
+	group, err := groupExpr(teacherGroups, f.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	terms, args := teachingTermRange(f)
+
+	stats := make([]models.TeacherCountStat, 0)
+	err = queryStats(`SELECT `+group+`, COUNT(DISTINCT ta.teacher_id)
+		FROM teaching_assignments ta JOIN subjects sub ON sub.id = ta.subject_id LEFT JOIN terms tm ON tm.id = ta.term_id
+		JOIN teachers t ON t.id = ta.teacher_id AND t.deleted_at IS NULL
+		JOIN classes c ON c.id = ta.class_id LEFT JOIN academic_years y ON y.id = c.academic_year_id
+		WHERE 1=1`+terms+` GROUP BY 1 ORDER BY 1`, args, func(rows *sql.Rows) error {
+		var stat models.TeacherCountStat
+		var label sql.NullString
+		err := rows.Scan(&label, &stat.Teachers)
+		stat.Group = label.String
+		stats = append(stats, stat)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Students per teacher, where the teachers of a group are those with a
+// teaching assignment in one of its classes
+func GetStudentTeacherRatioStats(f models.StatsFilter) ([]models.RatioStat, error) {
+	group, err := groupExpr(classGroups, f.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	members, args := classMembers(f)
+	terms, termArgs := teachingTermRange(f)
+	args = append(args, termArgs...)
+
+	stats := make([]models.RatioStat, 0)
+	err = queryStats(`SELECT st.grp, st.students, COALESCE(te.teachers, 0), st.students / NULLIF(te.teachers, 0)
+		FROM (SELECT `+group+` AS grp, COUNT(DISTINCT m.student_id) AS students
+			FROM classes c LEFT JOIN academic_years y ON y.id = c.academic_year_id`+members+`
+			GROUP BY 1) st
+		LEFT JOIN (SELECT `+group+` AS grp, COUNT(DISTINCT ta.teacher_id) AS teachers
+			FROM classes c LEFT JOIN academic_years y ON y.id = c.academic_year_id
+			JOIN teaching_assignments ta ON ta.class_id = c.id
+			JOIN teachers t ON t.id = ta.teacher_id AND t.deleted_at IS NULL
+			WHERE 1=1`+terms+` GROUP BY 1) te ON te.grp <=> st.grp
+		ORDER BY 1`, args, func(rows *sql.Rows) error {
+		var stat models.RatioStat
+		var label sql.NullString
+		var ratio sql.NullFloat64
+		err := rows.Scan(&label, &stat.Students, &stat.Teachers, &ratio)
+		stat.Group = label.String
+		stat.Ratio = nullableFloat(ratio)
+		stats = append(stats, stat)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// How full the classes are now. Capacity and the waitlist only exist for
+// the present, so there is no date range.
+func GetClassFillRateStats(f models.StatsFilter) ([]models.FillRateStat, error) {
+	if f.From != "" || f.To != "" {
+		return nil, utils.InvalidHandler(nil, "class fill rates are always current, from and to are not supported")
+	}
+	group, err := groupExpr(classGroups, f.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]models.FillRateStat, 0)
+	err = queryStats(`SELECT `+group+`, COUNT(*), COALESCE(SUM(n.students), 0), SUM(c.capacity), COALESCE(SUM(w.waiting), 0),
+		100 * SUM(IF(c.capacity > 0, COALESCE(n.students, 0), 0)) / NULLIF(SUM(c.capacity), 0)
+		FROM classes c LEFT JOIN academic_years y ON y.id = c.academic_year_id
+		LEFT JOIN (SELECT class_id, COUNT(*) AS students FROM students WHERE class_id IS NOT NULL GROUP BY class_id) n ON n.class_id = c.id
+		LEFT JOIN (SELECT class_id, COUNT(*) AS waiting FROM class_waitlist GROUP BY class_id) w ON w.class_id = c.id
+		GROUP BY 1 ORDER BY 1`, nil, func(rows *sql.Rows) error {
+		var stat models.FillRateStat
+		var label sql.NullString
+		var fillRate sql.NullFloat64
+		err := rows.Scan(&label, &stat.Classes, &stat.Students, &stat.Capacity, &stat.Waitlisted, &fillRate)
+		stat.Group = label.String
+		stat.FillRate = nullableFloat(fillRate)
+		stats = append(stats, stat)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Attendance records in the date range. Class, grade and year are those of
+// the class the student is in now.
+func GetAttendanceStats(f models.StatsFilter) ([]models.AttendanceStat, error) {
+	group, err := groupExpr(attendanceGroups, f.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	dates, args := statsDateRange("a.date", f)
+
+	stats := make([]models.AttendanceStat, 0)
+	err = queryStats(`SELECT `+group+`,
+		SUM(status = 'present'), SUM(status = 'absent'), SUM(status = 'late'), SUM(status = 'excused'),
+		COUNT(*), `+attendancePercentage+`
+		FROM attendance a JOIN students s ON s.id = a.student_id
+		LEFT JOIN classes c ON c.id = s.class_id LEFT JOIN academic_years y ON y.id = c.academic_year_id
+		WHERE s.deleted_at IS NULL`+dates+` GROUP BY 1 ORDER BY 1`, args, func(rows *sql.Rows) error {
+		var stat models.AttendanceStat
+		var label sql.NullString
+		err := rows.Scan(&label, &stat.Present, &stat.Absent, &stat.Late, &stat.Excused, &stat.Total, &stat.Percentage)
+		stat.Group = label.String
+		stats = append(stats, stat)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Scores of assessments due in the date range, grouped by the class the
+// assessment was given to
+func GetGradeStats(f models.StatsFilter) ([]models.GradeStat, error) {
+	group, err := groupExpr(gradeGroups, f.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+	dates, args := statsDateRange("a.due_date", f)
+
+	stats := make([]models.GradeStat, 0)
+	err = queryStats(`SELECT `+group+`, COUNT(*), 100 * SUM(sc.score / a.max_score * a.weight) / NULLIF(SUM(a.weight), 0)
+		FROM scores sc JOIN assessments a ON a.id = sc.assessment_id
+		JOIN subjects sub ON sub.id = a.subject_id LEFT JOIN terms tm ON tm.id = a.term_id JOIN students s ON s.id = sc.student_id
+		JOIN classes c ON c.id = a.class_id LEFT JOIN academic_years y ON y.id = c.academic_year_id
+		WHERE s.deleted_at IS NULL`+dates+` GROUP BY 1 ORDER BY 1`, args, func(rows *sql.Rows) error {
+		var stat models.GradeStat
+		var label sql.NullString
+		var average sql.NullFloat64
+		err := rows.Scan(&label, &stat.Scores, &average)
+		stat.Group = label.String
+		stat.Average = nullableFloat(average)
+		stats = append(stats, stat)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -6,226 +6,422 @@ import (
 	"net/http"
 	"restapi/internal/models"
 	"restapi/pkg/utils"
+	"time"
 )
 
-func GetOneStudent(w http.ResponseWriter, id int) (models.Student, error) {
+// A deleted student is only found when includeDeleted is set
+func GetOneStudent(w http.ResponseWriter, id int, includeDeleted bool) (models.Student, error) {
 	db, err := ConnectDb()
 	if err != nil {
 		return models.Student{}, utils.ErrorHandler(err, "error trying to open SQL database")
 	}
 	defer db.Close()
 
+	query := "SELECT id, first_name, last_name, email, COALESCE(class_id, 0), deleted_at, version, ROW_START FROM students WHERE id = ?"
+	if !includeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
 	var student models.Student
-	err = db.QueryRow("SELECT * FROM students WHERE id = ?", id).Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.Class)
+	var deletedAt sql.NullString
+	err = db.QueryRow(query, id).Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.ClassID, &deletedAt, &student.Version, &student.UpdatedAt)
+	student.DeletedAt = nullableString(deletedAt)
 	if err == sql.ErrNoRows {
 		fmt.Println(err)
-		return models.Student{}, utils.ErrorHandler(err, "error student not found")
+		return models.Student{}, utils.NotFoundHandler(err, "error student not found")
 	} else if err != nil {
 		return models.Student{}, utils.ErrorHandler(err, "error getting student from database")
 	}
 	return student, nil
 }
 
-func AddStudent(w http.ResponseWriter, newStudent []models.Student) ([]models.Student, error) {
-	db, err := ConnectDb()
+// Adds students. A student whose class is full is added without a class
+// and put on the class's waitlist, their result has the entry that says
+// where they are in the queue.
+func AddStudent(w http.ResponseWriter, newStudents []models.Student, atomic bool, info models.AuditInfo) ([]models.BulkItemResult, error) {
+	return runBulk(len(newStudents), atomic, func(tx *sql.Tx, i int) (models.BulkItemResult, error) {
+		student, entry, err := insertStudent(tx, newStudents[i], info)
+		return models.BulkItemResult{ID: student.ID, Action: models.BulkCreated, Data: student, Waitlist: entry}, err
+	}, nil)
+}
+
+func insertStudent(tx *sql.Tx, addStudent models.Student, info models.AuditInfo) (models.Student, *models.WaitlistEntry, error) {
+	//stmt, err := db.Prepare("INSERT INTO students (first_name, last_name, email, class_id) VALUES (?,?,?,?)")
+	// The student gets their seat from placeStudent below
+	res, err := tx.Exec(utils.GenerateInsertQuery("students", models.Student{}), addStudent.FirstName, addStudent.LastName, addStudent.Email, nil)
+	if isDuplicateEntry(err) {
+		return models.Student{}, nil, utils.ConflictHandler(err, "email is already used by another student: "+addStudent.Email)
+	} else if err != nil {
+		return models.Student{}, nil, utils.ErrorHandler(err, "error inserting data into database")
+	}
+	lastId, err := res.LastInsertId()
 	if err != nil {
-		http.Error(w, "error opening up database", http.StatusInternalServerError)
-		return nil, utils.ErrorHandler(err, "error opening up database")
+		return models.Student{}, nil, utils.ErrorHandler(err, "error getting last insert id")
 	}
-	defer db.Close()
+	addStudent.ID = int(lastId)
 
-	//stmt, err := db.Prepare("INSERT INTO students (first_name, last_name, email, class, subject) VALUES (?,?,?,?,?)")
-	stmt, err := db.Prepare(utils.GenerateInsertQuery("students", models.Student{}))
+	entry, err := placeStudent(tx, addStudent.ID, 0, addStudent.ClassID, info)
 	if err != nil {
-		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+		return models.Student{}, nil, err
 	}
-	defer stmt.Close()
-
-	addedStudents := make([]models.Student, len(newStudent))
-	for i, addStudent := range newStudent {
-		fmt.Println(addStudent.FirstName, addStudent.LastName, addStudent.Email, addStudent.Class)
-		res, err := stmt.Exec(addStudent.FirstName, addStudent.LastName, addStudent.Email, addStudent.Class)
-		if err != nil {
-			return nil, utils.ErrorHandler(err, "error inserting data into database")
-		}
-		lastId, err := res.LastInsertId()
-		if err != nil {
-			return nil, utils.ErrorHandler(err, "error getting last insert id")
-		}
-		addStudent.ID = int(lastId)
-		addedStudents[i] = addStudent
+	if entry != nil {
+		addStudent.ClassID = 0
+	}
+	addStudent.Version, err = studentVersion(tx, addStudent.ID)
+	if err != nil {
+		return models.Student{}, nil, err
+	}
+	err = writeAudit(tx, info, "create", "student", addStudent.ID, nil, addStudent)
+	if err != nil {
+		return models.Student{}, nil, err
 	}
-	return addedStudents, nil
+	return addStudent, entry, nil
 }
 
-func UpdateStudent(w http.ResponseWriter, id int, updateStudent models.Student) error {
-	db, err := ConnectDb()
+// Reads a student that is not deleted and locks the row until tx ends
+func lockStudent(tx *sql.Tx, id int) (models.Student, error) {
+	var student models.Student
+	err := tx.QueryRow("SELECT id, first_name, last_name, email, COALESCE(class_id, 0), version FROM students WHERE id = ? AND deleted_at IS NULL FOR UPDATE", id).
+		Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.ClassID, &student.Version)
+	return student, err
+}
+
+// Writes a student's fields and moves them to student.ClassID, or onto its
+// waitlist when that class is full. The returned student has the class they
+// are actually in, which is also what the audit entry records, and the
+// version they were saved as. A student.Version other than 0 must be the
+// stored one.
+func saveStudent(tx *sql.Tx, student models.Student, action string, info models.AuditInfo) (models.Student, *models.WaitlistEntry, error) {
+	existingStudent, err := lockStudent(tx, student.ID)
+	if err == sql.ErrNoRows {
+		return models.Student{}, nil, utils.NotFoundHandler(err, "student not found")
+	} else if err != nil {
+		return models.Student{}, nil, utils.ErrorHandler(err, "error retrieving student from database")
+	}
+	err = checkVersion("student", student.Version, existingStudent.Version)
 	if err != nil {
-		return utils.ErrorHandler(err, "error opening up database")
+		return models.Student{}, nil, err
 	}
-	defer db.Close()
+	currentClassId := existingStudent.ClassID
 
-	var existingStudent models.Student
-	err = db.QueryRow("SELECT id, first_name, last_name, email, class FROM students WHERE id = ?", id).Scan(
-		&existingStudent.ID,
-		&existingStudent.FirstName,
-		&existingStudent.LastName,
-		&existingStudent.Email,
-		&existingStudent.Class,
-	)
-	if err == sql.ErrNoRows {
-		return utils.ErrorHandler(err, "student not found")
+	_, err = tx.Exec("UPDATE students SET first_name = ?, last_name = ?, email = ?, version = version + 1 WHERE id = ?",
+		student.FirstName, student.LastName, student.Email, student.ID)
+	if isDuplicateEntry(err) {
+		return models.Student{}, nil, utils.ConflictHandler(err, "email is already used by another student: "+student.Email)
 	} else if err != nil {
-		return utils.ErrorHandler(err, "error retrieving student from database")
+		return models.Student{}, nil, utils.ErrorHandler(err, "error updating student")
 	}
 
-	updateStudent.ID = existingStudent.ID
-	updateQuery := utils.GenerateUpdateQuery("students", updateStudent)
+	var entry *models.WaitlistEntry
+	if student.ClassID == 0 {
+		student.ClassID = currentClassId
+	} else {
+		entry, err = placeStudent(tx, student.ID, currentClassId, student.ClassID, info)
+		if err != nil {
+			return models.Student{}, nil, err
+		}
+		if entry != nil {
+			student.ClassID = currentClassId
+		}
+	}
+	student.Version, err = studentVersion(tx, student.ID)
+	if err != nil {
+		return models.Student{}, nil, err
+	}
 
-	_, err = db.Exec(updateQuery)
+	err = writeAudit(tx, info, action, "student", student.ID, existingStudent, student)
 	if err != nil {
-		return utils.ErrorHandler(err, "error updating student")
+		return models.Student{}, nil, err
 	}
-	return nil
+	return student, entry, nil
 }
 
-func PatchStudent(w http.ResponseWriter, updates []map[string]interface{}) error {
+// updateStudent.Version, when set, must be the stored one
+func UpdateStudent(w http.ResponseWriter, id int, updateStudent models.Student, info models.AuditInfo) (models.Student, *models.WaitlistEntry, error) {
 	db, err := ConnectDb()
 	if err != nil {
-		return utils.ErrorHandler(err, "unable to connect to database")
+		return models.Student{}, nil, utils.ErrorHandler(err, "error opening up database")
 	}
 	defer db.Close()
 
 	tx, err := db.Begin()
 	if err != nil {
-		return utils.ErrorHandler(err, "error starting transaction")
+		return models.Student{}, nil, utils.ErrorHandler(err, "error starting transaction")
 	}
 
-	fmt.Println(updates)
-	for _, update := range updates {
-		idFloat, ok := update["id"].(float64)
-		if !ok {
-			tx.Rollback()
-			return utils.ErrorHandler(err, "invalid student id")
-		}
+	updateStudent.ID = id
+	updatedStudent, entry, err := saveStudent(tx, updateStudent, "update", info)
+	if err != nil {
+		tx.Rollback()
+		return models.Student{}, nil, err
+	}
 
-		id := int(idFloat)
+	err = tx.Commit()
+	if err != nil {
+		return models.Student{}, nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return updatedStudent, entry, nil
+}
+
+// Takes a map of fields to be patched and the id of the student. An update
+// can carry the "version" it was based on.
+func PatchStudent(w http.ResponseWriter, updates []map[string]interface{}, atomic bool, info models.AuditInfo) ([]models.BulkItemResult, error) {
+	return runBulk(len(updates), atomic, func(tx *sql.Tx, i int) (models.BulkItemResult, error) {
+		id, version, patch, err := bulkPatch(updates[i])
 		if err != nil {
-			tx.Rollback()
-			return utils.ErrorHandler(err, "error converting id to int")
+			return models.BulkItemResult{}, err
 		}
+		result := models.BulkItemResult{ID: id, Action: models.BulkUpdated}
 
-		var studentFromDb models.Student
-		err = utils.PatchStudentModel(db, "students", id, &studentFromDb, update)
+		student, err := lockStudent(tx, id)
+		if err == sql.ErrNoRows {
+			return result, utils.NotFoundHandler(err, fmt.Sprintf("student %d was not found", id))
+		} else if err != nil {
+			return result, utils.ErrorHandler(err, "error retrieving student from database")
+		}
+		err = checkVersion("student", version, student.Version)
 		if err != nil {
-			return utils.ErrorHandler(err, "error updating student struct")
+			return result, err
 		}
-
-		update := utils.GenerateUpdateQuery("students", studentFromDb)
-		_, err = tx.Exec(update)
+		err = patch.ApplyTo(&student)
 		if err != nil {
-			tx.Rollback()
-			return utils.ErrorHandler(err, "error updating student")
+			return result, err
 		}
-	}
 
-	err = tx.Commit()
-	if err != nil {
-		return utils.ErrorHandler(err, "error comitting transaction")
-	}
-	return nil
+		result.Data, result.Waitlist, err = saveStudent(tx, student, "patch", info)
+		return result, err
+	}, nil)
 }
 
-func PatchOneStudent(w http.ResponseWriter, id int, updates map[string]interface{}) (models.Student, error) {
+// The patch is applied to the student locked in the transaction, so its test
+// ops check the row it is written over. A version other than 0 must be the
+// student's current one.
+func PatchOneStudent(w http.ResponseWriter, id int, patch utils.Patch, version int, info models.AuditInfo) (models.Student, *models.WaitlistEntry, error) {
 	db, err := ConnectDb()
 	if err != nil {
-		return models.Student{}, utils.ErrorHandler(err, "error opening up database")
+		return models.Student{}, nil, utils.ErrorHandler(err, "error opening up database")
 	}
 	defer db.Close()
 
-	var existingStudent models.Student
-	err = utils.PatchStudentModel(db, "students", id, &existingStudent, updates)
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Student{}, nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	existingStudent, err := lockStudent(tx, id)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.Student{}, nil, utils.NotFoundHandler(err, "student not found")
+	} else if err != nil {
+		tx.Rollback()
+		return models.Student{}, nil, utils.ErrorHandler(err, "error retrieving student from database")
+	}
+	err = checkVersion("student", version, existingStudent.Version)
+	if err != nil {
+		tx.Rollback()
+		return models.Student{}, nil, err
+	}
+	err = patch.ApplyTo(&existingStudent)
 	if err != nil {
-		return models.Student{}, utils.ErrorHandler(err, "error patching model")
+		tx.Rollback()
+		return models.Student{}, nil, err
 	}
 
-	update := utils.GenerateUpdateQuery("students", existingStudent)
-	_, err = db.Exec(update)
+	patchedStudent, entry, err := saveStudent(tx, existingStudent, "patch", info)
 	if err != nil {
-		return models.Student{}, utils.ErrorHandler(err, "error updating student")
+		tx.Rollback()
+		return models.Student{}, nil, err
 	}
-	return existingStudent, nil
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Student{}, nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return patchedStudent, entry, nil
 }
 
-func DeleteOneStudent(w http.ResponseWriter, id int) error {
+// Soft deletes a student. They leave their class and its waitlist, and the
+// seat goes to the next student waiting for it. A version other than 0 must
+// be the student's current one.
+func DeleteOneStudent(w http.ResponseWriter, id int, version int, info models.AuditInfo) error {
 	db, err := ConnectDb()
 	if err != nil {
 		return utils.ErrorHandler(err, "error opening up db")
 	}
 	defer db.Close()
 
-	result, err := db.Exec("DELETE FROM students WHERE id = ?", id)
+	tx, err := db.Begin()
 	if err != nil {
-		return utils.ErrorHandler(err, "error deleting request from database")
+		return utils.ErrorHandler(err, "error starting transaction")
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	classId, deleted, err := softDeleteStudent(tx, id, version, time.Now(), info)
 	if err != nil {
-		return utils.ErrorHandler(err, "error getting rows affected")
+		tx.Rollback()
+		return err
+	}
+	if !deleted {
+		tx.Rollback()
+		return utils.NotFoundHandler(sql.ErrNoRows, "student was not found")
 	}
 
-	if rowsAffected == 0 {
-		return utils.ErrorHandler(err, "student was not found")
+	if classId != 0 {
+		err = fillFreeSeats(tx, classId, info)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return utils.ErrorHandler(err, "error committing transaction")
 	}
 	return nil
 }
 
-func DeleteStudentsFromDb(w http.ResponseWriter, ids []int) ([]int, error) {
+// Soft deletes the students like DeleteOneStudent. An id that does not
+// belong to a student fails its item, so does a version other than 0 that
+// is not the student's current one.
+func DeleteStudentsFromDb(w http.ResponseWriter, items []models.BulkDelete, atomic bool, info models.AuditInfo) ([]models.BulkItemResult, error) {
+	now := time.Now()
+	var freedClasses []int
+	return runBulk(len(items), atomic, func(tx *sql.Tx, i int) (models.BulkItemResult, error) {
+		result := models.BulkItemResult{ID: items[i].ID, Action: models.BulkDeleted}
+		classId, deleted, err := softDeleteStudent(tx, items[i].ID, items[i].Version, now, info)
+		if err != nil {
+			return result, err
+		}
+		if !deleted {
+			return result, utils.NotFoundHandler(nil, fmt.Sprintf("student %d was not found", items[i].ID))
+		}
+		if classId != 0 {
+			freedClasses = append(freedClasses, classId)
+		}
+		return result, nil
+	}, func(tx *sql.Tx) error {
+		// In an atomic batch seats are handed out once everyone in it is gone
+		classes := freedClasses
+		freedClasses = nil
+		for _, classId := range classes {
+			err := fillFreeSeats(tx, classId, info)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Marks the student deleted and takes them out of their class, ending the
+// enrollment on the day of the delete, and off any waitlist. Returns the
+// class they left and false when there is no such student left to delete.
+func softDeleteStudent(tx *sql.Tx, id int, version int, now time.Time, info models.AuditInfo) (int, bool, error) {
+	student, err := lockStudent(tx, id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, utils.ErrorHandler(err, "error retrieving student from database")
+	}
+	err = checkVersion("student", version, student.Version)
+	if err != nil {
+		return 0, false, err
+	}
+	classId := student.ClassID
+
+	_, err = tx.Exec("UPDATE students SET deleted_at = ?, class_id = NULL, version = version + 1 WHERE id = ?", now.Format(time.DateTime), id)
+	if err != nil {
+		return 0, false, utils.ErrorHandler(err, "error deleting student")
+	}
+	_, err = tx.Exec("UPDATE enrollments SET ended_on = ? WHERE student_id = ? AND ended_on IS NULL", now.Format(time.DateOnly), id)
+	if err != nil {
+		return 0, false, utils.ErrorHandler(err, "error ending enrollment")
+	}
+	_, err = tx.Exec("DELETE FROM class_waitlist WHERE student_id = ?", id)
+	if err != nil {
+		return 0, false, utils.ErrorHandler(err, "error removing student from waitlist")
+	}
+	err = writeAudit(tx, info, "delete", "student", id, student, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	return classId, true, nil
+}
+
+// Undoes a soft delete. The student goes back to the class they were last
+// enrolled in, or onto its waitlist when their seat has been taken since.
+func RestoreStudent(id int, info models.AuditInfo) (models.Student, *models.WaitlistEntry, error) {
 	db, err := ConnectDb()
 	if err != nil {
-		return nil, utils.ErrorHandler(err, "error opening database")
+		return models.Student{}, nil, utils.ErrorHandler(err, "error opening up db")
 	}
 	defer db.Close()
 
 	tx, err := db.Begin()
 	if err != nil {
-		return nil, utils.ErrorHandler(err, "error preparing transaction")
+		return models.Student{}, nil, utils.ErrorHandler(err, "error starting transaction")
 	}
 
-	stmt, err := tx.Prepare("DELETE FROM students WHERE id = ?")
-	if err != nil {
+	var student models.Student
+	var deletedAt sql.NullString
+	err = tx.QueryRow("SELECT id, first_name, last_name, email, deleted_at, version FROM students WHERE id = ? FOR UPDATE", id).
+		Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &deletedAt, &student.Version)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.Student{}, nil, utils.NotFoundHandler(err, "student not found")
+	} else if err != nil {
+		tx.Rollback()
+		return models.Student{}, nil, utils.ErrorHandler(err, "error retrieving student from database")
+	}
+	if !deletedAt.Valid {
 		tx.Rollback()
-		return nil, utils.ErrorHandler(err, "error preparing delete statment")
+		return models.Student{}, nil, utils.ConflictHandler(nil, "student is not deleted")
 	}
-	defer stmt.Close()
 
-	var deletedIds []int
-	for _, id := range ids {
-		result, err := stmt.Exec(id)
-		if err != nil {
-			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "error executing statement")
-		}
+	_, err = tx.Exec("UPDATE students SET deleted_at = NULL, version = version + 1 WHERE id = ?", id)
+	if isDuplicateEntry(err) {
+		tx.Rollback()
+		return models.Student{}, nil, utils.ConflictHandler(err, "email is already used by another student: "+student.Email)
+	} else if err != nil {
+		tx.Rollback()
+		return models.Student{}, nil, utils.ErrorHandler(err, "error restoring student")
+	}
 
-		rowsAffected, err := result.RowsAffected()
+	var lastClassId int
+	err = tx.QueryRow("SELECT class_id FROM enrollments WHERE student_id = ? ORDER BY started_on DESC, id DESC LIMIT 1", id).Scan(&lastClassId)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		return models.Student{}, nil, utils.ErrorHandler(err, "error retrieving enrollment")
+	}
+
+	var entry *models.WaitlistEntry
+	if lastClassId != 0 {
+		entry, err = placeStudent(tx, id, 0, lastClassId, info)
 		if err != nil {
 			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "error retrieving delete results")
+			return models.Student{}, nil, err
 		}
-
-		if rowsAffected > 0 {
-			deletedIds = append(deletedIds, id)
+		if entry == nil {
+			student.ClassID = lastClassId
 		}
 	}
 
-	err = tx.Commit()
+	deleted := student
+	deleted.ClassID = 0
+	deleted.DeletedAt = &deletedAt.String
+	student.Version, err = studentVersion(tx, id)
 	if err != nil {
-		return nil, utils.ErrorHandler(err, "error committing transaction")
+		tx.Rollback()
+		return models.Student{}, nil, err
 	}
-
-	if len(deletedIds) < 1 {
-		return nil, utils.ErrorHandler(err, "error, ids do not exist")
+	err = writeAudit(tx, info, "restore", "student", id, deleted, student)
+	if err != nil {
+		tx.Rollback()
+		return models.Student{}, nil, err
 	}
 
-	return deletedIds, nil
+	err = tx.Commit()
+	if err != nil {
+		return models.Student{}, nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return student, entry, nil
 }
@@ -0,0 +1,175 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+func GetSubjectsFromDb(query string, args []interface{}) ([]models.Subject, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, name, code FROM subjects WHERE 1=1"+query, args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	subjects := make([]models.Subject, 0)
+	for rows.Next() {
+		var subject models.Subject
+		err = rows.Scan(&subject.ID, &subject.Name, &subject.Code)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		subjects = append(subjects, subject)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return subjects, nil
+}
+
+func GetOneSubject(id int) (models.Subject, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Subject{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var subject models.Subject
+	err = db.QueryRow("SELECT id, name, code FROM subjects WHERE id = ?", id).Scan(&subject.ID, &subject.Name, &subject.Code)
+	if err == sql.ErrNoRows {
+		return models.Subject{}, utils.ErrorHandler(err, "error subject not found")
+	} else if err != nil {
+		return models.Subject{}, utils.ErrorHandler(err, "error getting subject from database")
+	}
+	return subject, nil
+}
+
+func AddSubjects(newSubjects []models.Subject) ([]models.Subject, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare(utils.GenerateInsertQuery("subjects", models.Subject{}))
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	addedSubjects := make([]models.Subject, len(newSubjects))
+	for i, newSubject := range newSubjects {
+		res, err := stmt.Exec(newSubject.Name, newSubject.Code)
+		if err != nil {
+			tx.Rollback()
+			if isDuplicateEntry(err) {
+				return nil, utils.ConflictHandler(err, "subject already exists: "+newSubject.Name)
+			}
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newSubject.ID = int(lastId)
+		addedSubjects[i] = newSubject
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedSubjects, nil
+}
+
+func UpdateSubject(id int, updateSubject models.Subject) (models.Subject, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Subject{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	updateSubject.ID = id
+	err = execSubjectUpdate(db, updateSubject)
+	if err != nil {
+		return models.Subject{}, err
+	}
+	return updateSubject, nil
+}
+
+func PatchOneSubject(id int, updates map[string]interface{}) (models.Subject, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Subject{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var existingSubject models.Subject
+	err = utils.PatchSubjectModel(db, id, &existingSubject, updates)
+	if err != nil {
+		return models.Subject{}, utils.ErrorHandler(err, "error patching model")
+	}
+
+	err = execSubjectUpdate(db, existingSubject)
+	if err != nil {
+		return models.Subject{}, err
+	}
+	return existingSubject, nil
+}
+
+func execSubjectUpdate(db *sql.DB, subject models.Subject) error {
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM subjects WHERE id = ?", subject.ID).Scan(&exists)
+	if err != nil {
+		return utils.ErrorHandler(err, "error retrieving subject from database")
+	}
+	if exists == 0 {
+		return utils.ErrorHandler(sql.ErrNoRows, "subject not found")
+	}
+
+	_, err = db.Exec("UPDATE subjects SET name = ?, code = ? WHERE id = ?", subject.Name, subject.Code, subject.ID)
+	if isDuplicateEntry(err) {
+		return utils.ConflictHandler(err, "subject already exists: "+subject.Name)
+	} else if err != nil {
+		return utils.ErrorHandler(err, "error updating subject")
+	}
+	return nil
+}
+
+func DeleteOneSubject(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM subjects WHERE id = ?", id)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return utils.ErrorHandler(err, "subject was not found")
+	}
+	return nil
+}
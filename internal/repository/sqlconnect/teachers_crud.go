@@ -7,232 +7,284 @@ import (
 	"net/http"
 	"restapi/internal/models"
 	"restapi/pkg/utils"
+	"time"
 )
 
-func DeleteTeachersFromDb(w http.ResponseWriter, ids []int) ([]int, error) {
+// Soft deletes the teachers, see RestoreTeacher and PurgeDeleted. An id that
+// does not belong to a teacher fails its item, so does a version other than
+// 0 that is not the teacher's current one.
+func DeleteTeachersFromDb(w http.ResponseWriter, items []models.BulkDelete, atomic bool, info models.AuditInfo) ([]models.BulkItemResult, error) {
+	now := time.Now()
+	return runBulk(len(items), atomic, func(tx *sql.Tx, i int) (models.BulkItemResult, error) {
+		result := models.BulkItemResult{ID: items[i].ID, Action: models.BulkDeleted}
+		deleted, err := softDeleteTeacher(tx, items[i].ID, items[i].Version, now, info)
+		if err != nil {
+			return result, err
+		}
+		if !deleted {
+			return result, utils.NotFoundHandler(nil, fmt.Sprintf("teacher %d was not found", items[i].ID))
+		}
+		return result, nil
+	}, nil)
+}
+
+// Soft deletes the teacher, see RestoreTeacher and PurgeDeleted. A version
+// other than 0 must be the teacher's current one.
+func DeleteOneTeacher(w http.ResponseWriter, id int, version int, info models.AuditInfo) error {
 	db, err := ConnectDb()
 	if err != nil {
-		return nil, utils.ErrorHandler(err, "error opening database")
+		return utils.ErrorHandler(err, "error opening up db")
 	}
 	defer db.Close()
 
 	tx, err := db.Begin()
 	if err != nil {
-		return nil, utils.ErrorHandler(err, "error preparing transaction")
+		return utils.ErrorHandler(err, "error starting transaction")
 	}
 
-	stmt, err := tx.Prepare("DELETE FROM teachers WHERE id = ?")
+	deleted, err := softDeleteTeacher(tx, id, version, time.Now(), info)
 	if err != nil {
 		tx.Rollback()
-		return nil, utils.ErrorHandler(err, "error preparing delete statment")
+		return err
 	}
-	defer stmt.Close()
-
-	var deletedIds []int
-	for _, id := range ids {
-		result, err := stmt.Exec(id)
-		if err != nil {
-			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "error executing statement")
-		}
-
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			tx.Rollback()
-			return nil, utils.ErrorHandler(err, "error retrieving delete results")
-		}
-
-		if rowsAffected > 0 {
-			deletedIds = append(deletedIds, id)
-		}
+	if !deleted {
+		tx.Rollback()
+		return utils.NotFoundHandler(sql.ErrNoRows, "teacher was not found")
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return nil, utils.ErrorHandler(err, "error committing transaction")
+		return utils.ErrorHandler(err, "error committing transaction")
 	}
-
-	if len(deletedIds) < 1 {
-		return nil, utils.ErrorHandler(err, "error, ids do not exist")
-	}
-
-	return deletedIds, nil
+	return nil
 }
 
-func DeleteOneTeacher(w http.ResponseWriter, id int) error {
-	db, err := ConnectDb()
+// Returns false when there is no such teacher left to delete
+func softDeleteTeacher(tx *sql.Tx, id int, version int, now time.Time, info models.AuditInfo) (bool, error) {
+	teacher, err := lockTeacher(tx, id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, utils.ErrorHandler(err, "error retrieving teacher from database")
+	}
+	err = checkVersion("teacher", version, teacher.Version)
 	if err != nil {
-		return utils.ErrorHandler(err, "error opening up db")
+		return false, err
 	}
-	defer db.Close()
 
-	result, err := db.Exec("DELETE FROM teachers WHERE id = ?", id)
+	_, err = tx.Exec("UPDATE teachers SET deleted_at = ?, version = version + 1 WHERE id = ?", now.Format(time.DateTime), id)
+	if err != nil {
+		return false, utils.ErrorHandler(err, "error deleting teacher")
+	}
+	err = writeAudit(tx, info, "delete", "teacher", id, teacher, nil)
 	if err != nil {
-		return utils.ErrorHandler(err, "error deleting request from database")
+		return false, err
 	}
+	return true, nil
+}
+
+// Reads a teacher that is not deleted and locks the row until tx ends
+func lockTeacher(tx *sql.Tx, id int) (models.Teacher, error) {
+	var teacher models.Teacher
+	err := tx.QueryRow("SELECT id, first_name, last_name, email, subject, version FROM teachers WHERE id = ? AND deleted_at IS NULL FOR UPDATE", id).
+		Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Subject, &teacher.Version)
+	return teacher, err
+}
 
-	rowsAffected, err := result.RowsAffected()
+// Writes the teacher over the stored one and records the change. A
+// teacher.Version other than 0 must be the stored one, the returned teacher
+// has the version it was saved as.
+func saveTeacher(tx *sql.Tx, teacher models.Teacher, action string, info models.AuditInfo) (models.Teacher, error) {
+	existingTeacher, err := lockTeacher(tx, teacher.ID)
+	if err == sql.ErrNoRows {
+		return models.Teacher{}, utils.NotFoundHandler(err, "teacher not found")
+	} else if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error retrieving teacher from database")
+	}
+	err = checkVersion("teacher", teacher.Version, existingTeacher.Version)
 	if err != nil {
-		return utils.ErrorHandler(err, "error getting rows affected")
+		return models.Teacher{}, err
 	}
 
-	if rowsAffected == 0 {
-		return utils.ErrorHandler(err, "teacher was not found")
+	_, err = tx.Exec("UPDATE teachers SET first_name = ?, last_name = ?, email = ?, subject = ?, version = version + 1 WHERE id = ?",
+		teacher.FirstName, teacher.LastName, teacher.Email, teacher.Subject, teacher.ID)
+	if isDuplicateEntry(err) {
+		return models.Teacher{}, utils.ConflictHandler(err, "email is already used by another teacher: "+teacher.Email)
+	} else if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error updating teacher")
 	}
-	return nil
+	teacher.Version = existingTeacher.Version + 1
+
+	err = writeAudit(tx, info, action, "teacher", teacher.ID, existingTeacher, teacher)
+	if err != nil {
+		return models.Teacher{}, err
+	}
+	return teacher, nil
 }
 
-func PatchOneTeacher(w http.ResponseWriter, id int, updates map[string]interface{}) (models.Teacher, error) {
+// The patch is applied to the teacher locked in the transaction, so its test
+// ops check the row it is written over. A version other than 0 must be the
+// teacher's current one.
+func PatchOneTeacher(w http.ResponseWriter, id int, patch utils.Patch, version int, info models.AuditInfo) (models.Teacher, error) {
 	db, err := ConnectDb()
 	if err != nil {
 		return models.Teacher{}, utils.ErrorHandler(err, "error opening up database")
 	}
 	defer db.Close()
 
-	var existingTeacher models.Teacher
-	err = utils.PatchTeacherModel(db, "teachers", id, &existingTeacher, updates)
+	tx, err := db.Begin()
 	if err != nil {
-		return models.Teacher{}, utils.ErrorHandler(err, "error patching model")
+		return models.Teacher{}, utils.ErrorHandler(err, "error starting transaction")
 	}
 
-	update := utils.GenerateUpdateQuery("teachers", existingTeacher)
-	_, err = db.Exec(update)
+	existingTeacher, err := lockTeacher(tx, id)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.Teacher{}, utils.NotFoundHandler(err, "teacher not found")
+	} else if err != nil {
+		tx.Rollback()
+		return models.Teacher{}, utils.ErrorHandler(err, "error retrieving teacher from database")
+	}
+	err = checkVersion("teacher", version, existingTeacher.Version)
 	if err != nil {
-		return models.Teacher{}, utils.ErrorHandler(err, "error updating teacher")
+		tx.Rollback()
+		return models.Teacher{}, err
 	}
-	return existingTeacher, nil
-}
-
-// Takes a map of fields to be patched
-func PatchTeachers(w http.ResponseWriter, updates []map[string]interface{}) error {
-	db, err := ConnectDb()
+	err = patch.ApplyTo(&existingTeacher)
 	if err != nil {
-		return utils.ErrorHandler(err, "unable to connect to database")
+		tx.Rollback()
+		return models.Teacher{}, err
 	}
-	defer db.Close()
 
-	tx, err := db.Begin()
+	patchedTeacher, err := saveTeacher(tx, existingTeacher, "patch", info)
 	if err != nil {
-		return utils.ErrorHandler(err, "error starting transaction")
+		tx.Rollback()
+		return models.Teacher{}, err
 	}
 
-	fmt.Println(updates)
-	for _, update := range updates {
-		idFloat, ok := update["id"].(float64)
-		if !ok {
-			tx.Rollback()
-			return utils.ErrorHandler(err, "invalid teacher id")
-		}
+	err = tx.Commit()
+	if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return patchedTeacher, nil
+}
 
-		id := int(idFloat)
+// Takes a map of fields to be patched and the id of the teacher. An update
+// can carry the "version" it was based on.
+func PatchTeachers(w http.ResponseWriter, updates []map[string]interface{}, atomic bool, info models.AuditInfo) ([]models.BulkItemResult, error) {
+	return runBulk(len(updates), atomic, func(tx *sql.Tx, i int) (models.BulkItemResult, error) {
+		id, version, patch, err := bulkPatch(updates[i])
 		if err != nil {
-			tx.Rollback()
-			return utils.ErrorHandler(err, "error converting id to int")
+			return models.BulkItemResult{}, err
 		}
+		result := models.BulkItemResult{ID: id, Action: models.BulkUpdated}
 
-		var teacherFromDb models.Teacher
-		err = utils.PatchTeacherModel(db, "teachers", id, &teacherFromDb, update)
+		teacher, err := lockTeacher(tx, id)
+		if err == sql.ErrNoRows {
+			return result, utils.NotFoundHandler(err, fmt.Sprintf("teacher %d was not found", id))
+		} else if err != nil {
+			return result, utils.ErrorHandler(err, "error retrieving teacher from database")
+		}
+		err = checkVersion("teacher", version, teacher.Version)
 		if err != nil {
-			return utils.ErrorHandler(err, "error updating teacher struct")
+			return result, err
 		}
-
-		update := utils.GenerateUpdateQuery("teachers", teacherFromDb)
-		_, err = tx.Exec(update)
+		err = patch.ApplyTo(&teacher)
 		if err != nil {
-			fmt.Println(teacherFromDb)
-			tx.Rollback()
-			return utils.ErrorHandler(err, "error updating teacher")
+			return result, err
 		}
-	}
 
-	err = tx.Commit()
-	if err != nil {
-		return utils.ErrorHandler(err, "error comitting transaction")
-	}
-	return nil
+		result.Data, err = saveTeacher(tx, teacher, "patch", info)
+		return result, err
+	}, nil)
 }
 
-func UpdateTeacher(w http.ResponseWriter, id int, updateTeacher models.Teacher) error {
+// updateTeacher.Version, when set, must be the stored one
+func UpdateTeacher(w http.ResponseWriter, id int, updateTeacher models.Teacher, info models.AuditInfo) (models.Teacher, error) {
 	db, err := ConnectDb()
 	if err != nil {
-		return utils.ErrorHandler(err, "error opening up database")
+		return models.Teacher{}, utils.ErrorHandler(err, "error opening up database")
 	}
 	defer db.Close()
 
-	var existingTeacher models.Teacher
-	err = db.QueryRow("SELECT id, first_name, last_name, email, class, subject FROM teachers WHERE id = ?", id).Scan(
-		&existingTeacher.ID,
-		&existingTeacher.FirstName,
-		&existingTeacher.LastName,
-		&existingTeacher.Email,
-		&existingTeacher.Class,
-		&existingTeacher.Subject,
-	)
-	if err == sql.ErrNoRows {
-		return utils.ErrorHandler(err, "teacher not found")
-	} else if err != nil {
-		return utils.ErrorHandler(err, "error retrieving teacher from database")
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error starting transaction")
 	}
 
-	updateTeacher.ID = existingTeacher.ID
-	updateQuery := utils.GenerateUpdateQuery("teachers", updateTeacher)
-
-	_, err = db.Exec(updateQuery)
+	updateTeacher.ID = id
+	updatedTeacher, err := saveTeacher(tx, updateTeacher, "update", info)
 	if err != nil {
-		return utils.ErrorHandler(err, "error updating teacher")
+		tx.Rollback()
+		return models.Teacher{}, err
 	}
-	return nil
-}
 
-func AddTeacher(w http.ResponseWriter, newTeachers []models.Teacher) ([]models.Teacher, error) {
-	db, err := ConnectDb()
+	err = tx.Commit()
 	if err != nil {
-		http.Error(w, "error opening up database", http.StatusInternalServerError)
-		return nil, utils.ErrorHandler(err, "error opening up database")
+		return models.Teacher{}, utils.ErrorHandler(err, "error committing transaction")
 	}
-	defer db.Close()
+	return updatedTeacher, nil
+}
+
+func AddTeacher(w http.ResponseWriter, newTeachers []models.Teacher, atomic bool, info models.AuditInfo) ([]models.BulkItemResult, error) {
+	return runBulk(len(newTeachers), atomic, func(tx *sql.Tx, i int) (models.BulkItemResult, error) {
+		teacher, err := insertTeacher(tx, newTeachers[i], info)
+		return models.BulkItemResult{ID: teacher.ID, Action: models.BulkCreated, Data: teacher}, err
+	}, nil)
+}
 
-	//stmt, err := db.Prepare("INSERT INTO teachers (first_name, last_name, email, class, subject) VALUES (?,?,?,?,?)")
-	stmt, err := db.Prepare(utils.GenerateInsertQuery("teachers", models.Teacher{}))
+func insertTeacher(tx *sql.Tx, newTeacher models.Teacher, info models.AuditInfo) (models.Teacher, error) {
+	//stmt, err := db.Prepare("INSERT INTO teachers (first_name, last_name, email, subject) VALUES (?,?,?,?)")
+	res, err := tx.Exec(utils.GenerateInsertQuery("teachers", models.Teacher{}), newTeacher.FirstName, newTeacher.LastName, newTeacher.Email, newTeacher.Subject)
+	if isDuplicateEntry(err) {
+		return models.Teacher{}, utils.ConflictHandler(err, "email is already used by another teacher: "+newTeacher.Email)
+	} else if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error inserting data into database")
+	}
+	lastId, err := res.LastInsertId()
 	if err != nil {
-		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+		return models.Teacher{}, utils.ErrorHandler(err, "error getting last insert id")
 	}
-	defer stmt.Close()
-
-	addedTeachers := make([]models.Teacher, len(newTeachers))
-	for i, newTeacher := range newTeachers {
-		res, err := stmt.Exec(newTeacher.FirstName, newTeacher.LastName, newTeacher.Email, newTeacher.Class, newTeacher.Subject)
-		if err != nil {
-			return nil, utils.ErrorHandler(err, "error inserting data into database")
-		}
-		lastId, err := res.LastInsertId()
-		if err != nil {
-			return nil, utils.ErrorHandler(err, "error getting last insert id")
-		}
-		newTeacher.ID = int(lastId)
-		addedTeachers[i] = newTeacher
+	newTeacher.ID = int(lastId)
+	newTeacher.Version = 1
+	err = writeAudit(tx, info, "create", "teacher", newTeacher.ID, nil, newTeacher)
+	if err != nil {
+		return models.Teacher{}, err
 	}
-	return addedTeachers, nil
+	return newTeacher, nil
 }
 
-func GetOneTeacher(w http.ResponseWriter, id int) (models.Teacher, error) {
+// A deleted teacher is only found when includeDeleted is set
+func GetOneTeacher(w http.ResponseWriter, id int, includeDeleted bool) (models.Teacher, error) {
 	db, err := ConnectDb()
 	if err != nil {
 		return models.Teacher{}, utils.ErrorHandler(err, "error trying to open SQL database")
 	}
 	defer db.Close()
 
+	query := "SELECT id, first_name, last_name, email, subject, deleted_at, version, ROW_START FROM teachers WHERE id = ?"
+	if !includeDeleted {
+		query += " AND deleted_at IS NULL"
+	}
 	var teacher models.Teacher
-	err = db.QueryRow("SELECT * FROM teachers WHERE id = ?", id).Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Class, &teacher.Subject)
+	var deletedAt sql.NullString
+	err = db.QueryRow(query, id).Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Subject, &deletedAt, &teacher.Version, &teacher.UpdatedAt)
+	teacher.DeletedAt = nullableString(deletedAt)
 	if err == sql.ErrNoRows {
 		fmt.Println(err)
-		return models.Teacher{}, utils.ErrorHandler(err, "error teachers not found")
+		return models.Teacher{}, utils.NotFoundHandler(err, "error teachers not found")
 	} else if err != nil {
 		return models.Teacher{}, utils.ErrorHandler(err, "error getting teacher from database")
 	}
 	return teacher, nil
 }
 
+// Ids of the classes a teacher teaches through an assignment or as homeroom
+// teacher. Takes the teacher id twice.
+const teacherClassesSubquery = `SELECT class_id FROM teaching_assignments WHERE teacher_id = ?
+	UNION
+	SELECT id FROM classes WHERE homeroom_teacher_id = ?`
+
 func GetStudentsByTeacherIdFromDb(teacherId string, students []models.Student) ([]models.Student, error) {
 	db, err := ConnectDb()
 	if err != nil {
@@ -240,8 +292,8 @@ func GetStudentsByTeacherIdFromDb(teacherId string, students []models.Student) (
 	}
 	defer db.Close()
 
-	query := `SELECT id, first_name, last_name, email, class FROM students WHERE class = (SELECT class FROM teachers WHERE id = ?)`
-	rows, err := db.Query(query, teacherId)
+	query := `SELECT id, first_name, last_name, email, class_id FROM students WHERE deleted_at IS NULL AND class_id IN (` + teacherClassesSubquery + `)`
+	rows, err := db.Query(query, teacherId, teacherId)
 	if err != nil {
 		log.Println(err)
 		return nil, utils.ErrorHandler(err, "error with query")
@@ -250,7 +302,7 @@ func GetStudentsByTeacherIdFromDb(teacherId string, students []models.Student) (
 
 	for rows.Next() {
 		var student models.Student
-		err := rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.Class)
+		err := rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.ClassID)
 		if err != nil {
 			return nil, utils.ErrorHandler(err, "error with scanning row")
 		}
@@ -263,17 +315,71 @@ func GetStudentsByTeacherIdFromDb(teacherId string, students []models.Student) (
 	return students, nil
 }
 
-func GetStudentCountByTeacherFromDb(teacherId string, studentCount int) error {
+func GetStudentCountByTeacherFromDb(teacherId string) (int, error) {
 	db, err := ConnectDb()
 	if err != nil {
-		return utils.ErrorHandler(err, "error opening database")
+		return 0, utils.ErrorHandler(err, "error opening database")
 	}
 	defer db.Close()
 
-	query := `SELECT COUNT(*) FROM students WHERE class = (SELECT class FROM teachers WHERE id = ?)`
-	err = db.QueryRow(query, teacherId).Scan(&studentCount)
+	var studentCount int
+	query := `SELECT COUNT(*) FROM students WHERE deleted_at IS NULL AND class_id IN (` + teacherClassesSubquery + `)`
+	err = db.QueryRow(query, teacherId, teacherId).Scan(&studentCount)
 	if err != nil {
-		return utils.ErrorHandler(err, "error querying row")
+		return 0, utils.ErrorHandler(err, "error querying row")
 	}
-	return nil
+	return studentCount, nil
+}
+
+// Undoes a soft delete
+func RestoreTeacher(id int, info models.AuditInfo) (models.Teacher, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	var teacher models.Teacher
+	var deletedAt sql.NullString
+	err = tx.QueryRow("SELECT id, first_name, last_name, email, subject, deleted_at, version FROM teachers WHERE id = ? FOR UPDATE", id).
+		Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Subject, &deletedAt, &teacher.Version)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.Teacher{}, utils.NotFoundHandler(err, "teacher not found")
+	} else if err != nil {
+		tx.Rollback()
+		return models.Teacher{}, utils.ErrorHandler(err, "error retrieving teacher from database")
+	}
+	if !deletedAt.Valid {
+		tx.Rollback()
+		return models.Teacher{}, utils.ConflictHandler(nil, "teacher is not deleted")
+	}
+
+	_, err = tx.Exec("UPDATE teachers SET deleted_at = NULL, version = version + 1 WHERE id = ?", id)
+	if isDuplicateEntry(err) {
+		tx.Rollback()
+		return models.Teacher{}, utils.ConflictHandler(err, "email is already used by another teacher: "+teacher.Email)
+	} else if err != nil {
+		tx.Rollback()
+		return models.Teacher{}, utils.ErrorHandler(err, "error restoring teacher")
+	}
+	deleted := teacher
+	deleted.DeletedAt = &deletedAt.String
+	teacher.Version++
+	err = writeAudit(tx, info, "restore", "teacher", id, deleted, teacher)
+	if err != nil {
+		tx.Rollback()
+		return models.Teacher{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return teacher, nil
 }
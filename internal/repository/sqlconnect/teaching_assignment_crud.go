@@ -0,0 +1,213 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+const duplicateAssignmentMsg = "class already has a teacher for this subject and term"
+
+func scanTeachingAssignment(row interface{ Scan(...interface{}) error }, assignment *models.TeachingAssignment) error {
+	return row.Scan(&assignment.ID, &assignment.TeacherID, &assignment.ClassID, &assignment.SubjectID, &assignment.TermID)
+}
+
+func GetTeachingAssignmentsFromDb(query string, args []interface{}) ([]models.TeachingAssignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, teacher_id, class_id, subject_id, COALESCE(term_id, 0) FROM teaching_assignments WHERE 1=1"+query, args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	assignments := make([]models.TeachingAssignment, 0)
+	for rows.Next() {
+		var assignment models.TeachingAssignment
+		err = scanTeachingAssignment(rows, &assignment)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		assignments = append(assignments, assignment)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return assignments, nil
+}
+
+func GetOneTeachingAssignment(id int) (models.TeachingAssignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.TeachingAssignment{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var assignment models.TeachingAssignment
+	err = scanTeachingAssignment(db.QueryRow("SELECT id, teacher_id, class_id, subject_id, COALESCE(term_id, 0) FROM teaching_assignments WHERE id = ?", id), &assignment)
+	if err == sql.ErrNoRows {
+		return models.TeachingAssignment{}, utils.ErrorHandler(err, "error teaching assignment not found")
+	} else if err != nil {
+		return models.TeachingAssignment{}, utils.ErrorHandler(err, "error getting teaching assignment from database")
+	}
+	return assignment, nil
+}
+
+func AddTeachingAssignments(newAssignments []models.TeachingAssignment) ([]models.TeachingAssignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare(utils.GenerateInsertQuery("teaching_assignments", models.TeachingAssignment{}))
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	addedAssignments := make([]models.TeachingAssignment, len(newAssignments))
+	for i, newAssignment := range newAssignments {
+		res, err := stmt.Exec(newAssignment.TeacherID, newAssignment.ClassID, newAssignment.SubjectID, nullableId(newAssignment.TermID))
+		if err != nil {
+			tx.Rollback()
+			if isDuplicateEntry(err) {
+				return nil, utils.ConflictHandler(err, duplicateAssignmentMsg)
+			}
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newAssignment.ID = int(lastId)
+		addedAssignments[i] = newAssignment
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedAssignments, nil
+}
+
+func UpdateTeachingAssignment(id int, updateAssignment models.TeachingAssignment) (models.TeachingAssignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.TeachingAssignment{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	updateAssignment.ID = id
+	err = execTeachingAssignmentUpdate(db, updateAssignment)
+	if err != nil {
+		return models.TeachingAssignment{}, err
+	}
+	return updateAssignment, nil
+}
+
+func PatchOneTeachingAssignment(id int, updates map[string]interface{}) (models.TeachingAssignment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.TeachingAssignment{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	var existingAssignment models.TeachingAssignment
+	err = utils.PatchTeachingAssignmentModel(db, id, &existingAssignment, updates)
+	if err != nil {
+		return models.TeachingAssignment{}, utils.ErrorHandler(err, "error patching model")
+	}
+
+	err = execTeachingAssignmentUpdate(db, existingAssignment)
+	if err != nil {
+		return models.TeachingAssignment{}, err
+	}
+	return existingAssignment, nil
+}
+
+func execTeachingAssignmentUpdate(db *sql.DB, assignment models.TeachingAssignment) error {
+	var exists int
+	err := db.QueryRow("SELECT COUNT(*) FROM teaching_assignments WHERE id = ?", assignment.ID).Scan(&exists)
+	if err != nil {
+		return utils.ErrorHandler(err, "error retrieving teaching assignment from database")
+	}
+	if exists == 0 {
+		return utils.ErrorHandler(sql.ErrNoRows, "teaching assignment not found")
+	}
+
+	_, err = db.Exec("UPDATE teaching_assignments SET teacher_id = ?, class_id = ?, subject_id = ?, term_id = ? WHERE id = ?",
+		assignment.TeacherID, assignment.ClassID, assignment.SubjectID, nullableId(assignment.TermID), assignment.ID)
+	if isDuplicateEntry(err) {
+		return utils.ConflictHandler(err, duplicateAssignmentMsg)
+	} else if err != nil {
+		return utils.ErrorHandler(err, "error updating teaching assignment")
+	}
+	return nil
+}
+
+func DeleteOneTeachingAssignment(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM teaching_assignments WHERE id = ?", id)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return utils.ErrorHandler(err, "teaching assignment was not found")
+	}
+	return nil
+}
+
+// Classes a teacher is homeroom teacher of or teaches a subject to
+func GetClassesByTeacherIdFromDb(teacherId int) ([]models.Class, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	query := `SELECT ` + classColumns + ` FROM classes WHERE id IN (` + teacherClassesSubquery + `) ORDER BY grade, section`
+	rows, err := db.Query(query, teacherId, teacherId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	classes := make([]models.Class, 0)
+	for rows.Next() {
+		var class models.Class
+		err := scanClass(rows, &class)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		classes = append(classes, class)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return classes, nil
+}
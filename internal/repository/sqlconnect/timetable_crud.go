@@ -0,0 +1,255 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+const timetableColumns = "id, class_id, subject_id, teacher_id, room, weekday, TIME_FORMAT(start_time, '%H:%i'), TIME_FORMAT(end_time, '%H:%i'), term_id"
+
+func scanTimetableSlot(row interface{ Scan(...interface{}) error }, slot *models.TimetableSlot) error {
+	return row.Scan(&slot.ID, &slot.ClassID, &slot.SubjectID, &slot.TeacherID, &slot.Room, &slot.Weekday, &slot.StartTime, &slot.EndTime, &slot.TermID)
+}
+
+func GetTimetableSlotsFromDb(query string, args []interface{}) ([]models.TimetableSlot, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT "+timetableColumns+" FROM timetable_slots WHERE 1=1"+query+" ORDER BY weekday, start_time", args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error querying db")
+	}
+	defer rows.Close()
+
+	slots := make([]models.TimetableSlot, 0)
+	for rows.Next() {
+		var slot models.TimetableSlot
+		err = scanTimetableSlot(rows, &slot)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error scanning database results")
+		}
+		slots = append(slots, slot)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return slots, nil
+}
+
+func GetOneTimetableSlot(id int) (models.TimetableSlot, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.TimetableSlot{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var slot models.TimetableSlot
+	err = scanTimetableSlot(db.QueryRow("SELECT "+timetableColumns+" FROM timetable_slots WHERE id = ?", id), &slot)
+	if err == sql.ErrNoRows {
+		return models.TimetableSlot{}, utils.ErrorHandler(err, "error timetable slot not found")
+	} else if err != nil {
+		return models.TimetableSlot{}, utils.ErrorHandler(err, "error getting timetable slot from database")
+	}
+	return slot, nil
+}
+
+// Rejects a slot that overlaps another slot of the same term and weekday
+// with the same teacher, class or room. Slots that touch (one ends when the
+// next starts) do not overlap.
+func checkTimetableConflicts(tx *sql.Tx, slot models.TimetableSlot) error {
+	var other models.TimetableSlot
+	err := scanTimetableSlot(tx.QueryRow(`SELECT `+timetableColumns+` FROM timetable_slots
+		WHERE term_id = ? AND weekday = ? AND start_time < ? AND end_time > ? AND id <> ?
+		AND (teacher_id = ? OR class_id = ? OR (room <> '' AND room = ?))
+		LIMIT 1 FOR UPDATE`,
+		slot.TermID, slot.Weekday, slot.EndTime, slot.StartTime, slot.ID,
+		slot.TeacherID, slot.ClassID, slot.Room), &other)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return utils.ErrorHandler(err, "error checking timetable conflicts")
+	}
+
+	var booked string
+	switch {
+	case other.TeacherID == slot.TeacherID:
+		booked = fmt.Sprintf("teacher %d", slot.TeacherID)
+	case other.ClassID == slot.ClassID:
+		booked = fmt.Sprintf("class %d", slot.ClassID)
+	default:
+		booked = "room " + slot.Room
+	}
+	return utils.ConflictHandler(nil, fmt.Sprintf("%s is already booked from %s to %s on weekday %d (slot %d)", booked, other.StartTime, other.EndTime, other.Weekday, other.ID))
+}
+
+func AddTimetableSlots(newSlots []models.TimetableSlot) ([]models.TimetableSlot, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	stmt, err := tx.Prepare(utils.GenerateInsertQuery("timetable_slots", models.TimetableSlot{}))
+	if err != nil {
+		tx.Rollback()
+		return nil, utils.ErrorHandler(err, "error in preparing SQL query")
+	}
+	defer stmt.Close()
+
+	// Slots are inserted one by one, so later slots in the batch are
+	// checked against earlier ones as well
+	addedSlots := make([]models.TimetableSlot, len(newSlots))
+	for i, newSlot := range newSlots {
+		err = checkTimetableConflicts(tx, newSlot)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		res, err := stmt.Exec(utils.GetStructValues(newSlot)...)
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error inserting data into database")
+		}
+		lastId, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return nil, utils.ErrorHandler(err, "error getting last insert id")
+		}
+		newSlot.ID = int(lastId)
+		addedSlots[i] = newSlot
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return addedSlots, nil
+}
+
+func UpdateTimetableSlot(id int, updateSlot models.TimetableSlot) (models.TimetableSlot, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.TimetableSlot{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.TimetableSlot{}, utils.ErrorHandler(err, "error starting transaction")
+	}
+
+	var exists int
+	err = tx.QueryRow("SELECT COUNT(*) FROM timetable_slots WHERE id = ?", id).Scan(&exists)
+	if err != nil {
+		tx.Rollback()
+		return models.TimetableSlot{}, utils.ErrorHandler(err, "error retrieving timetable slot from database")
+	}
+	if exists == 0 {
+		tx.Rollback()
+		return models.TimetableSlot{}, utils.ErrorHandler(sql.ErrNoRows, "timetable slot not found")
+	}
+
+	updateSlot.ID = id
+	err = checkTimetableConflicts(tx, updateSlot)
+	if err != nil {
+		tx.Rollback()
+		return models.TimetableSlot{}, err
+	}
+
+	_, err = tx.Exec(`UPDATE timetable_slots SET class_id = ?, subject_id = ?, teacher_id = ?, room = ?, weekday = ?, start_time = ?, end_time = ?, term_id = ?
+		WHERE id = ?`, updateSlot.ClassID, updateSlot.SubjectID, updateSlot.TeacherID, updateSlot.Room, updateSlot.Weekday,
+		updateSlot.StartTime, updateSlot.EndTime, updateSlot.TermID, id)
+	if err != nil {
+		tx.Rollback()
+		return models.TimetableSlot{}, utils.ErrorHandler(err, "error updating timetable slot")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.TimetableSlot{}, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return updateSlot, nil
+}
+
+func DeleteOneTimetableSlot(id int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM timetable_slots WHERE id = ?", id)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return utils.ErrorHandler(err, "timetable slot was not found")
+	}
+	return nil
+}
+
+// Timetable of a teacher or class (column is "teacher_id" or "class_id"),
+// optionally limited to one term
+func GetTimetableFromDb(column string, id int, termId string) ([]models.TimetableEntry, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	query := `SELECT ts.id, ts.class_id, ts.subject_id, ts.teacher_id, ts.room, ts.weekday,
+		TIME_FORMAT(ts.start_time, '%H:%i'), TIME_FORMAT(ts.end_time, '%H:%i'), ts.term_id,
+		c.name, s.name, CONCAT(t.first_name, ' ', t.last_name), tm.start_date, tm.end_date
+		FROM timetable_slots ts
+		JOIN classes c ON c.id = ts.class_id
+		JOIN subjects s ON s.id = ts.subject_id
+		JOIN teachers t ON t.id = ts.teacher_id AND t.deleted_at IS NULL
+		JOIN terms tm ON tm.id = ts.term_id
+		WHERE ts.` + column + ` = ?`
+	args := []interface{}{id}
+	if termId != "" {
+		query += " AND ts.term_id = ?"
+		args = append(args, termId)
+	}
+	query += " ORDER BY ts.weekday, ts.start_time"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	entries := make([]models.TimetableEntry, 0)
+	for rows.Next() {
+		var e models.TimetableEntry
+		err = rows.Scan(&e.ID, &e.ClassID, &e.SubjectID, &e.TeacherID, &e.Room, &e.Weekday, &e.StartTime, &e.EndTime, &e.TermID,
+			&e.ClassName, &e.SubjectName, &e.TeacherName, &e.TermStartDate, &e.TermEndDate)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		entries = append(entries, e)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return entries, nil
+}
@@ -0,0 +1,116 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// Moves a student to another class from the effective date on. The seat
+// they leave goes to the next student on the old class's waitlist. Returns
+// the new enrollment. info.Actor is recorded as who made the transfer.
+func TransferStudent(studentId int, req models.TransferRequest, info models.AuditInfo) (models.Enrollment, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Enrollment{}, utils.ErrorHandler(err, "error opening up database")
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return models.Enrollment{}, utils.ErrorHandler(err, "error starting transaction")
+	}
+	defer tx.Rollback()
+
+	var currentClassId int
+	err = tx.QueryRow("SELECT COALESCE(class_id, 0) FROM students WHERE id = ? AND deleted_at IS NULL FOR UPDATE", studentId).Scan(&currentClassId)
+	if err == sql.ErrNoRows {
+		return models.Enrollment{}, utils.NotFoundHandler(err, "student not found")
+	} else if err != nil {
+		return models.Enrollment{}, utils.ErrorHandler(err, "error retrieving student from database")
+	}
+	if currentClassId != req.FromClassID {
+		return models.Enrollment{}, utils.ConflictHandler(nil, fmt.Sprintf("student is in class %d, not class %d", currentClassId, req.FromClassID))
+	}
+
+	var startedOn string
+	err = tx.QueryRow("SELECT started_on FROM enrollments WHERE student_id = ? AND ended_on IS NULL", studentId).Scan(&startedOn)
+	if err != nil && err != sql.ErrNoRows {
+		return models.Enrollment{}, utils.ErrorHandler(err, "error retrieving enrollment")
+	}
+	// Dates compare as strings since both are YYYY-MM-DD
+	if startedOn != "" && req.EffectiveDate < startedOn {
+		return models.Enrollment{}, utils.InvalidHandler(nil, "effective_date is before the student joined their current class on "+startedOn)
+	}
+
+	hasSeat, err := classHasSeat(tx, req.ToClassID)
+	if err != nil {
+		return models.Enrollment{}, err
+	}
+	if !hasSeat {
+		return models.Enrollment{}, utils.ConflictHandler(nil, fmt.Sprintf("class %d is full", req.ToClassID))
+	}
+
+	err = moveStudentToClass(tx, studentId, req.ToClassID, req.EffectiveDate, req.Reason, info)
+	if err != nil {
+		return models.Enrollment{}, err
+	}
+	_, err = tx.Exec("DELETE FROM class_waitlist WHERE student_id = ?", studentId)
+	if err != nil {
+		return models.Enrollment{}, utils.ErrorHandler(err, "error removing student from waitlist")
+	}
+
+	if currentClassId != 0 {
+		err = fillFreeSeats(tx, currentClassId, info)
+		if err != nil {
+			return models.Enrollment{}, err
+		}
+	}
+
+	var enrollment models.Enrollment
+	err = scanEnrollment(tx.QueryRow("SELECT "+enrollmentColumns+" FROM enrollments WHERE student_id = ? AND ended_on IS NULL", studentId), &enrollment)
+	if err != nil {
+		return models.Enrollment{}, utils.ErrorHandler(err, "error retrieving enrollment")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Enrollment{}, utils.ErrorHandler(err, "error committing transaction")
+	}
+	return enrollment, nil
+}
+
+// Every class the student has been in, oldest first
+func GetStudentHistoryFromDb(studentId int) ([]models.EnrollmentHistory, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT e.id, e.student_id, e.class_id, e.started_on, COALESCE(e.ended_on, ''), COALESCE(e.from_class_id, 0),
+		e.reason, e.actor, c.name, COALESCE(f.name, '')
+		FROM enrollments e JOIN classes c ON c.id = e.class_id LEFT JOIN classes f ON f.id = e.from_class_id
+		WHERE e.student_id = ? ORDER BY e.started_on, e.id`, studentId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	history := make([]models.EnrollmentHistory, 0)
+	for rows.Next() {
+		var h models.EnrollmentHistory
+		err = rows.Scan(&h.ID, &h.StudentID, &h.ClassID, &h.StartedOn, &h.EndedOn, &h.FromClassID,
+			&h.Reason, &h.Actor, &h.ClassName, &h.FromClassName)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		history = append(history, h)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return history, nil
+}
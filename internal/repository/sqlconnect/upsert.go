@@ -0,0 +1,136 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// Saves teachers by their natural key, the columns in key. A teacher no
+// other one matches is created, one that matches is updated when a field
+// differs and left alone otherwise.
+func UpsertTeachers(w http.ResponseWriter, teachers []models.Teacher, key []string, atomic bool, info models.AuditInfo) ([]models.BulkItemResult, error) {
+	return runBulk(len(teachers), atomic, func(tx *sql.Tx, i int) (models.BulkItemResult, error) {
+		teacher := teachers[i]
+		id, err := lockByKey(tx, "teachers", "teacher", key, teacherKeyValues(teacher, key))
+		if err == sql.ErrNoRows {
+			created, err := insertTeacher(tx, teacher, info)
+			return models.BulkItemResult{ID: created.ID, Action: models.BulkCreated, Data: created}, err
+		} else if err != nil {
+			return models.BulkItemResult{}, err
+		}
+
+		existing, err := lockTeacher(tx, id)
+		if err != nil {
+			return models.BulkItemResult{ID: id}, utils.ErrorHandler(err, "error retrieving teacher from database")
+		}
+		teacher.ID = id
+		if teacher.FirstName == existing.FirstName && teacher.LastName == existing.LastName &&
+			teacher.Email == existing.Email && teacher.Subject == existing.Subject {
+			return models.BulkItemResult{ID: id, Action: models.BulkUnchanged, Data: existing}, nil
+		}
+		updated, err := saveTeacher(tx, teacher, "update", info)
+		return models.BulkItemResult{ID: id, Action: models.BulkUpdated, Data: updated}, err
+	}, nil)
+}
+
+// Like UpsertTeachers, for students. class_id is required like the other
+// fields, a student whose new class is full ends up on its waitlist.
+func UpsertStudents(w http.ResponseWriter, students []models.Student, key []string, atomic bool, info models.AuditInfo) ([]models.BulkItemResult, error) {
+	return runBulk(len(students), atomic, func(tx *sql.Tx, i int) (models.BulkItemResult, error) {
+		student := students[i]
+		id, err := lockByKey(tx, "students", "student", key, studentKeyValues(student, key))
+		if err == sql.ErrNoRows {
+			created, entry, err := insertStudent(tx, student, info)
+			return models.BulkItemResult{ID: created.ID, Action: models.BulkCreated, Data: created, Waitlist: entry}, err
+		} else if err != nil {
+			return models.BulkItemResult{}, err
+		}
+
+		existing, err := lockStudent(tx, id)
+		if err != nil {
+			return models.BulkItemResult{ID: id}, utils.ErrorHandler(err, "error retrieving student from database")
+		}
+		student.ID = id
+		if student.FirstName == existing.FirstName && student.LastName == existing.LastName &&
+			student.Email == existing.Email && student.ClassID == existing.ClassID {
+			return models.BulkItemResult{ID: id, Action: models.BulkUnchanged, Data: existing}, nil
+		}
+		updated, entry, err := saveStudent(tx, student, "update", info)
+		return models.BulkItemResult{ID: id, Action: models.BulkUpdated, Data: updated, Waitlist: entry}, err
+	}, nil)
+}
+
+// Finds the row that is not deleted whose key columns hold values and locks
+// it until tx ends. sql.ErrNoRows when there is none, a conflict when the
+// key is not unique enough to pick one. The columns must come from a fixed
+// list, they end up in the query.
+func lockByKey(tx *sql.Tx, table string, entity string, key []string, values []interface{}) (int, error) {
+	conditions := make([]string, len(key))
+	for i, column := range key {
+		conditions[i] = column + " = ?"
+	}
+	query := "SELECT id FROM " + table + " WHERE deleted_at IS NULL AND " + strings.Join(conditions, " AND ") + " LIMIT 2 FOR UPDATE"
+	rows, err := tx.Query(query, values...)
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "error retrieving "+entity+" from database")
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, utils.ErrorHandler(err, "error retrieving "+entity+" from database")
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "error retrieving "+entity+" from database")
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, sql.ErrNoRows
+	case 1:
+		return ids[0], nil
+	}
+	return 0, utils.ConflictHandler(nil, "more than one "+entity+" matches "+strings.Join(key, ", "))
+}
+
+func teacherKeyValues(teacher models.Teacher, key []string) []interface{} {
+	values := make([]interface{}, len(key))
+	for i, column := range key {
+		switch column {
+		case "email":
+			values[i] = teacher.Email
+		case "first_name":
+			values[i] = teacher.FirstName
+		case "last_name":
+			values[i] = teacher.LastName
+		case "subject":
+			values[i] = teacher.Subject
+		}
+	}
+	return values
+}
+
+func studentKeyValues(student models.Student, key []string) []interface{} {
+	values := make([]interface{}, len(key))
+	for i, column := range key {
+		switch column {
+		case "email":
+			values[i] = student.Email
+		case "first_name":
+			values[i] = student.FirstName
+		case "last_name":
+			values[i] = student.LastName
+		}
+	}
+	return values
+}
@@ -0,0 +1,169 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"fmt"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+	"time"
+)
+
+// teachers and students are system versioned, see migration 0015. A row
+// whose ROW_END lies in the future is the current one. Versions created and
+// replaced within one transaction have ROW_START = ROW_END and are skipped.
+const versionPeriod = `ROW_START, IF(ROW_END > NOW(6), NULL, ROW_END)`
+
+// The student as they were at asOf, deleted or not
+func GetStudentAsOf(id int, asOf time.Time) (models.Student, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Student{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var student models.Student
+	var deletedAt sql.NullString
+	err = db.QueryRow("SELECT id, first_name, last_name, email, COALESCE(class_id, 0), deleted_at, version FROM students FOR SYSTEM_TIME AS OF TIMESTAMP ? WHERE id = ?",
+		asOf.Local().Format("2006-01-02 15:04:05.000000"), id).
+		Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.ClassID, &deletedAt, &student.Version)
+	if err == sql.ErrNoRows {
+		return models.Student{}, utils.NotFoundHandler(err, "error student not found at that time")
+	} else if err != nil {
+		return models.Student{}, utils.ErrorHandler(err, "error getting student from database")
+	}
+	student.DeletedAt = nullableString(deletedAt)
+	return student, nil
+}
+
+// The teacher as they were at asOf, deleted or not
+func GetTeacherAsOf(id int, asOf time.Time) (models.Teacher, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error trying to open SQL database")
+	}
+	defer db.Close()
+
+	var teacher models.Teacher
+	var deletedAt sql.NullString
+	err = db.QueryRow("SELECT id, first_name, last_name, email, subject, deleted_at, version FROM teachers FOR SYSTEM_TIME AS OF TIMESTAMP ? WHERE id = ?",
+		asOf.Local().Format("2006-01-02 15:04:05.000000"), id).
+		Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Subject, &deletedAt, &teacher.Version)
+	if err == sql.ErrNoRows {
+		return models.Teacher{}, utils.NotFoundHandler(err, "error teacher not found at that time")
+	} else if err != nil {
+		return models.Teacher{}, utils.ErrorHandler(err, "error getting teacher from database")
+	}
+	teacher.DeletedAt = nullableString(deletedAt)
+	return teacher, nil
+}
+
+// Every state the student has been in, oldest first
+func GetStudentVersionsFromDb(id int) ([]models.RecordVersion, error) {
+	return queryVersions("SELECT id, first_name, last_name, email, COALESCE(class_id, 0), deleted_at, version, "+versionPeriod+
+		" FROM students FOR SYSTEM_TIME ALL WHERE id = ? AND ROW_START < ROW_END ORDER BY ROW_START", id,
+		func(rows *sql.Rows, version *models.RecordVersion) error {
+			var student models.Student
+			var deletedAt sql.NullString
+			var validTo sql.NullString
+			err := rows.Scan(&student.ID, &student.FirstName, &student.LastName, &student.Email, &student.ClassID, &deletedAt,
+				&student.Version, &version.ValidFrom, &validTo)
+			student.DeletedAt = nullableString(deletedAt)
+			version.Version = student.Version
+			version.ValidTo = nullableString(validTo)
+			version.Data = student
+			return err
+		})
+}
+
+// Every state the teacher has been in, oldest first
+func GetTeacherVersionsFromDb(id int) ([]models.RecordVersion, error) {
+	return queryVersions("SELECT id, first_name, last_name, email, subject, deleted_at, version, "+versionPeriod+
+		" FROM teachers FOR SYSTEM_TIME ALL WHERE id = ? AND ROW_START < ROW_END ORDER BY ROW_START", id,
+		func(rows *sql.Rows, version *models.RecordVersion) error {
+			var teacher models.Teacher
+			var deletedAt sql.NullString
+			var validTo sql.NullString
+			err := rows.Scan(&teacher.ID, &teacher.FirstName, &teacher.LastName, &teacher.Email, &teacher.Subject, &deletedAt,
+				&teacher.Version, &version.ValidFrom, &validTo)
+			teacher.DeletedAt = nullableString(deletedAt)
+			version.Version = teacher.Version
+			version.ValidTo = nullableString(validTo)
+			version.Data = teacher
+			return err
+		})
+}
+
+func queryVersions(query string, id int, scan func(rows *sql.Rows, version *models.RecordVersion) error) ([]models.RecordVersion, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query, id)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	versions := make([]models.RecordVersion, 0)
+	for rows.Next() {
+		var version models.RecordVersion
+		err = scan(rows, &version)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		versions = append(versions, version)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	if len(versions) == 0 {
+		return nil, utils.NotFoundHandler(sql.ErrNoRows, "no versions found")
+	}
+	return versions, nil
+}
+
+// Besides the system versions, teachers and students have a version column
+// that goes up with every change, see migration 0016. A write based on the
+// expected version is refused once the row has moved past it, 0 expects
+// nothing.
+func checkVersion(entity string, expected int, current int) error {
+	if expected != 0 && expected != current {
+		return utils.StaleHandler(nil, fmt.Sprintf("%s has changed since version %d", entity, expected))
+	}
+	return nil
+}
+
+// The version of a student locked by tx. Moving a student to another class
+// also bumps it, so it is read back rather than worked out.
+func studentVersion(tx *sql.Tx, id int) (int, error) {
+	var version int
+	err := tx.QueryRow("SELECT version FROM students WHERE id = ?", id).Scan(&version)
+	if err != nil {
+		return 0, utils.ErrorHandler(err, "error retrieving student version")
+	}
+	return version, nil
+}
+
+// How many rows of a teachers or students list there are and when the table
+// last changed, for the list's ETag and Last-Modified. where is the list
+// query's WHERE clause. The time is taken over the table's history, deleted
+// and purged rows included, so a row leaving the list moves it forward too.
+func GetListState(table string, where string, args []interface{}) (int, string, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return 0, "", utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	var count int
+	var lastModified sql.NullString
+	err = db.QueryRow("SELECT COUNT(*), (SELECT MAX(IF(ROW_END > NOW(6), ROW_START, ROW_END)) FROM "+table+" FOR SYSTEM_TIME ALL) FROM "+table+where,
+		args...).Scan(&count, &lastModified)
+	if err != nil {
+		return 0, "", utils.ErrorHandler(err, "error querying row")
+	}
+	return count, lastModified.String, nil
+}
@@ -0,0 +1,196 @@
+package sqlconnect
+
+import (
+	"database/sql"
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+	"time"
+)
+
+// Reports if the class has a free seat. A capacity of 0 means no limit. The
+// class row is locked, so concurrent placements into the same class queue up
+// behind each other instead of both taking the last seat.
+func classHasSeat(tx *sql.Tx, classId int) (bool, error) {
+	var capacity int
+	err := tx.QueryRow("SELECT capacity FROM classes WHERE id = ? FOR UPDATE", classId).Scan(&capacity)
+	if err == sql.ErrNoRows {
+		return false, utils.InvalidHandler(err, "class not found")
+	} else if err != nil {
+		return false, utils.ErrorHandler(err, "error retrieving class")
+	}
+	if capacity == 0 {
+		return true, nil
+	}
+
+	var students int
+	err = tx.QueryRow("SELECT COUNT(*) FROM students WHERE class_id = ?", classId).Scan(&students)
+	if err != nil {
+		return false, utils.ErrorHandler(err, "error counting class students")
+	}
+	return students < capacity, nil
+}
+
+// Puts a student into a class, or onto the class's waitlist when it is full.
+// fromClassId is the class the student is in now (0 for none), the seat it
+// leaves behind goes to the next student waiting for it. Returns the
+// waitlist entry when the student has to wait. Every move is audited with
+// info.
+func placeStudent(tx *sql.Tx, studentId int, fromClassId int, toClassId int, info models.AuditInfo) (*models.WaitlistEntry, error) {
+	if toClassId == fromClassId {
+		return nil, nil
+	}
+
+	hasSeat, err := classHasSeat(tx, toClassId)
+	if err != nil {
+		return nil, err
+	}
+	if !hasSeat {
+		return enqueueStudent(tx, studentId, toClassId)
+	}
+
+	reason := "class change"
+	if fromClassId == 0 {
+		reason = "enrolled"
+	}
+	err = moveStudentToClass(tx, studentId, toClassId, time.Now().Format(time.DateOnly), reason, info)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("DELETE FROM class_waitlist WHERE student_id = ?", studentId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error removing student from waitlist")
+	}
+
+	if fromClassId != 0 {
+		err = fillFreeSeats(tx, fromClassId, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func enqueueStudent(tx *sql.Tx, studentId int, classId int) (*models.WaitlistEntry, error) {
+	var waitingFor int
+	err := tx.QueryRow("SELECT class_id FROM class_waitlist WHERE student_id = ? FOR UPDATE", studentId).Scan(&waitingFor)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, utils.ErrorHandler(err, "error retrieving waitlist")
+	}
+
+	// Already waiting for this class keeps the place in the queue
+	if err == sql.ErrNoRows || waitingFor != classId {
+		_, err = tx.Exec("DELETE FROM class_waitlist WHERE student_id = ?", studentId)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error removing student from waitlist")
+		}
+		_, err = tx.Exec("INSERT INTO class_waitlist (class_id, student_id, queued_at) VALUES (?,?,?)",
+			classId, studentId, time.Now().Format(time.DateTime))
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error adding student to waitlist")
+		}
+	}
+
+	entry := models.WaitlistEntry{}
+	err = tx.QueryRow(`SELECT w.id, w.class_id, w.student_id, w.queued_at,
+		(SELECT COUNT(*) FROM class_waitlist o WHERE o.class_id = w.class_id AND o.id <= w.id)
+		FROM class_waitlist w WHERE w.student_id = ?`, studentId).
+		Scan(&entry.ID, &entry.ClassID, &entry.StudentID, &entry.QueuedAt, &entry.Position)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error retrieving waitlist entry")
+	}
+	return &entry, nil
+}
+
+// Gives the free seats of a class to the students waiting longest. A student
+// that moves up frees a seat in their old class, which is filled the same
+// way. The moves are audited with info, that of the change that freed the
+// seats.
+func fillFreeSeats(tx *sql.Tx, classId int, info models.AuditInfo) error {
+	queue := []int{classId}
+	for len(queue) > 0 {
+		classId, queue = queue[0], queue[1:]
+		for {
+			hasSeat, err := classHasSeat(tx, classId)
+			if err != nil {
+				return err
+			}
+			if !hasSeat {
+				break
+			}
+
+			var studentId, fromClassId int
+			err = tx.QueryRow(`SELECT w.student_id, COALESCE(s.class_id, 0) FROM class_waitlist w JOIN students s ON s.id = w.student_id
+				WHERE w.class_id = ? ORDER BY w.id LIMIT 1 FOR UPDATE`, classId).Scan(&studentId, &fromClassId)
+			if err == sql.ErrNoRows {
+				break
+			} else if err != nil {
+				return utils.ErrorHandler(err, "error retrieving waitlist")
+			}
+
+			err = moveStudentToClass(tx, studentId, classId, time.Now().Format(time.DateOnly), "seat freed up for waitlist", info)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("DELETE FROM class_waitlist WHERE student_id = ?", studentId)
+			if err != nil {
+				return utils.ErrorHandler(err, "error removing student from waitlist")
+			}
+			if fromClassId != 0 {
+				queue = append(queue, fromClassId)
+			}
+		}
+	}
+	return nil
+}
+
+func GetWaitlistByClassIdFromDb(classId int) ([]models.WaitlistEntry, error) {
+	db, err := ConnectDb()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, class_id, student_id, queued_at FROM class_waitlist WHERE class_id = ? ORDER BY id", classId)
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with query")
+	}
+	defer rows.Close()
+
+	entries := make([]models.WaitlistEntry, 0)
+	for rows.Next() {
+		entry := models.WaitlistEntry{Position: len(entries) + 1}
+		err = rows.Scan(&entry.ID, &entry.ClassID, &entry.StudentID, &entry.QueuedAt)
+		if err != nil {
+			return nil, utils.ErrorHandler(err, "error with scanning row")
+		}
+		entries = append(entries, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, utils.ErrorHandler(err, "error with row")
+	}
+	return entries, nil
+}
+
+func DeleteWaitlistEntry(classId int, studentId int) error {
+	db, err := ConnectDb()
+	if err != nil {
+		return utils.ErrorHandler(err, "error opening up db")
+	}
+	defer db.Close()
+
+	result, err := db.Exec("DELETE FROM class_waitlist WHERE class_id = ? AND student_id = ?", classId, studentId)
+	if err != nil {
+		return utils.ErrorHandler(err, "error deleting request from database")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrorHandler(err, "error getting rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return utils.ErrorHandler(err, "student is not on the waitlist")
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package sqlconnect
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"restapi/internal/models"
+	"restapi/pkg/utils"
+)
+
+// fakeSchool stands in for the database behind placeStudent. It answers the
+// queries of the waitlist, moveStudentToClass and writeAudit from its maps
+// and fails on any other query.
+type fakeSchool struct {
+	capacity map[int]int
+	classOf  map[int]int
+	waitlist []fakeWaitEntry
+	nextId   int
+	moves    []string
+}
+
+type fakeWaitEntry struct {
+	id        int
+	classId   int
+	studentId int
+}
+
+func (s *fakeSchool) Connect(context.Context) (driver.Conn, error) { return fakeConn{s}, nil }
+func (s *fakeSchool) Driver() driver.Driver                        { return nil }
+
+func (s *fakeSchool) removeWaiting(studentId int) {
+	kept := s.waitlist[:0]
+	for _, entry := range s.waitlist {
+		if entry.studentId != studentId {
+			kept = append(kept, entry)
+		}
+	}
+	s.waitlist = kept
+}
+
+func (s *fakeSchool) exec(query string, args []driver.Value) error {
+	switch {
+	case strings.HasPrefix(query, "DELETE FROM class_waitlist WHERE student_id = ?"):
+		s.removeWaiting(int(args[0].(int64)))
+	case strings.HasPrefix(query, "INSERT INTO class_waitlist"):
+		s.nextId++
+		s.waitlist = append(s.waitlist, fakeWaitEntry{id: s.nextId, classId: int(args[0].(int64)), studentId: int(args[1].(int64))})
+	case strings.HasPrefix(query, "INSERT INTO enrollments"):
+		s.moves = append(s.moves, fmt.Sprintf("%d to %d: %s", args[0], args[1], args[4]))
+	case strings.HasPrefix(query, "UPDATE students SET class_id = ?"):
+		s.classOf[int(args[1].(int64))] = int(args[0].(int64))
+	case strings.HasPrefix(query, "UPDATE enrollments SET ended_on"),
+		strings.HasPrefix(query, "INSERT INTO audit_log"):
+	default:
+		return fmt.Errorf("unexpected query %q", query)
+	}
+	return nil
+}
+
+// Each query returns one row or none
+func (s *fakeSchool) query(query string, args []driver.Value) ([]driver.Value, error) {
+	id := int(args[0].(int64))
+	switch {
+	case strings.HasPrefix(query, "SELECT capacity FROM classes"):
+		capacity, ok := s.capacity[id]
+		if !ok {
+			return nil, nil
+		}
+		return []driver.Value{int64(capacity)}, nil
+	case strings.HasPrefix(query, "SELECT COUNT(*) FROM students WHERE class_id = ?"):
+		count := 0
+		for _, classId := range s.classOf {
+			if classId == id {
+				count++
+			}
+		}
+		return []driver.Value{int64(count)}, nil
+	case strings.HasPrefix(query, "SELECT COALESCE(class_id, 0) FROM students WHERE id = ?"):
+		return []driver.Value{int64(s.classOf[id])}, nil
+	case strings.HasPrefix(query, "SELECT class_id FROM class_waitlist"):
+		for _, entry := range s.waitlist {
+			if entry.studentId == id {
+				return []driver.Value{int64(entry.classId)}, nil
+			}
+		}
+		return nil, nil
+	case strings.HasPrefix(query, "SELECT w.id, w.class_id, w.student_id, w.queued_at"):
+		position := make(map[int]int)
+		for _, entry := range s.waitlist {
+			position[entry.classId]++
+			if entry.studentId == id {
+				return []driver.Value{int64(entry.id), int64(entry.classId), int64(entry.studentId), "2024-01-15 09:30:00", int64(position[entry.classId])}, nil
+			}
+		}
+		return nil, nil
+	case strings.HasPrefix(query, "SELECT w.student_id, COALESCE(s.class_id, 0)"):
+		for _, entry := range s.waitlist {
+			if entry.classId == id {
+				return []driver.Value{int64(entry.studentId), int64(s.classOf[entry.studentId])}, nil
+			}
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+type fakeConn struct{ school *fakeSchool }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{school: c.school, query: strings.Join(strings.Fields(query), " ")}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	school *fakeSchool
+	query  string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), s.school.exec(s.query, args)
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	row, err := s.school.query(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{row: row}, nil
+}
+
+type fakeRows struct {
+	row  []driver.Value
+	done bool
+}
+
+// database/sql only needs the number of columns
+func (r *fakeRows) Columns() []string { return make([]string, len(r.row)) }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.row == nil || r.done {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.row)
+	return nil
+}
+
+func TestPlaceStudent(t *testing.T) {
+	tests := []struct {
+		name         string
+		school       fakeSchool
+		studentId    int
+		from         int
+		to           int
+		wantEntry    *models.WaitlistEntry
+		wantErr      error
+		wantClassOf  map[int]int
+		wantWaitlist []fakeWaitEntry
+		wantMoves    []string
+	}{
+		{
+			name:        "free seat",
+			school:      fakeSchool{capacity: map[int]int{1: 2}, classOf: map[int]int{1: 1, 5: 0}},
+			studentId:   5,
+			to:          1,
+			wantClassOf: map[int]int{1: 1, 5: 1},
+			wantMoves:   []string{"5 to 1: enrolled"},
+		},
+		{
+			name:        "no limit",
+			school:      fakeSchool{capacity: map[int]int{1: 0}, classOf: map[int]int{1: 1, 2: 1, 5: 0}},
+			studentId:   5,
+			to:          1,
+			wantClassOf: map[int]int{1: 1, 2: 1, 5: 1},
+			wantMoves:   []string{"5 to 1: enrolled"},
+		},
+		{
+			name:         "full class",
+			school:       fakeSchool{capacity: map[int]int{1: 1}, classOf: map[int]int{1: 1, 5: 0}},
+			studentId:    5,
+			to:           1,
+			wantEntry:    &models.WaitlistEntry{ID: 1, ClassID: 1, StudentID: 5, Position: 1, QueuedAt: "2024-01-15 09:30:00"},
+			wantClassOf:  map[int]int{1: 1, 5: 0},
+			wantWaitlist: []fakeWaitEntry{{id: 1, classId: 1, studentId: 5}},
+		},
+		{
+			name: "behind those waiting already",
+			school: fakeSchool{capacity: map[int]int{1: 1}, classOf: map[int]int{1: 1, 2: 0, 5: 0},
+				waitlist: []fakeWaitEntry{{id: 1, classId: 1, studentId: 2}}, nextId: 1},
+			studentId:    5,
+			to:           1,
+			wantEntry:    &models.WaitlistEntry{ID: 2, ClassID: 1, StudentID: 5, Position: 2, QueuedAt: "2024-01-15 09:30:00"},
+			wantClassOf:  map[int]int{1: 1, 2: 0, 5: 0},
+			wantWaitlist: []fakeWaitEntry{{id: 1, classId: 1, studentId: 2}, {id: 2, classId: 1, studentId: 5}},
+		},
+		{
+			name: "waiting for the class keeps the place",
+			school: fakeSchool{capacity: map[int]int{1: 1}, classOf: map[int]int{1: 1, 5: 0, 6: 0},
+				waitlist: []fakeWaitEntry{{id: 1, classId: 1, studentId: 5}, {id: 2, classId: 1, studentId: 6}}, nextId: 2},
+			studentId:    5,
+			to:           1,
+			wantEntry:    &models.WaitlistEntry{ID: 1, ClassID: 1, StudentID: 5, Position: 1, QueuedAt: "2024-01-15 09:30:00"},
+			wantClassOf:  map[int]int{1: 1, 5: 0, 6: 0},
+			wantWaitlist: []fakeWaitEntry{{id: 1, classId: 1, studentId: 5}, {id: 2, classId: 1, studentId: 6}},
+		},
+		{
+			name: "waiting for another class goes to the back",
+			school: fakeSchool{capacity: map[int]int{1: 1, 2: 1}, classOf: map[int]int{1: 1, 3: 2, 5: 0, 6: 0},
+				waitlist: []fakeWaitEntry{{id: 1, classId: 2, studentId: 5}, {id: 2, classId: 1, studentId: 6}}, nextId: 2},
+			studentId:    5,
+			to:           1,
+			wantEntry:    &models.WaitlistEntry{ID: 3, ClassID: 1, StudentID: 5, Position: 2, QueuedAt: "2024-01-15 09:30:00"},
+			wantClassOf:  map[int]int{1: 1, 3: 2, 5: 0, 6: 0},
+			wantWaitlist: []fakeWaitEntry{{id: 2, classId: 1, studentId: 6}, {id: 3, classId: 1, studentId: 5}},
+		},
+		{
+			name: "taking a seat leaves the waitlist",
+			school: fakeSchool{capacity: map[int]int{1: 1, 2: 2}, classOf: map[int]int{1: 1, 5: 0},
+				waitlist: []fakeWaitEntry{{id: 1, classId: 1, studentId: 5}}, nextId: 1},
+			studentId:   5,
+			to:          2,
+			wantClassOf: map[int]int{1: 1, 5: 2},
+			wantMoves:   []string{"5 to 2: enrolled"},
+		},
+		{
+			name: "the seat left behind goes down the waitlists",
+			school: fakeSchool{capacity: map[int]int{1: 1, 2: 5, 3: 1}, classOf: map[int]int{5: 1, 6: 3, 7: 0},
+				waitlist: []fakeWaitEntry{{id: 1, classId: 1, studentId: 6}, {id: 2, classId: 3, studentId: 7}}, nextId: 2},
+			studentId:   5,
+			from:        1,
+			to:          2,
+			wantClassOf: map[int]int{5: 2, 6: 1, 7: 3},
+			wantMoves:   []string{"5 to 2: class change", "6 to 1: seat freed up for waitlist", "7 to 3: seat freed up for waitlist"},
+		},
+		{
+			name:        "same class",
+			school:      fakeSchool{capacity: map[int]int{1: 1}, classOf: map[int]int{5: 1}},
+			studentId:   5,
+			from:        1,
+			to:          1,
+			wantClassOf: map[int]int{5: 1},
+		},
+		{
+			name:        "unknown class",
+			school:      fakeSchool{capacity: map[int]int{1: 1}, classOf: map[int]int{5: 0}},
+			studentId:   5,
+			to:          9,
+			wantErr:     utils.ErrInvalid,
+			wantClassOf: map[int]int{5: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			school := tt.school
+			db := sql.OpenDB(&school)
+			defer db.Close()
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+
+			entry, err := placeStudent(tx, tt.studentId, tt.from, tt.to, models.AuditInfo{Actor: "test"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("placeStudent() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("placeStudent() error = %v", err)
+			}
+			if !reflect.DeepEqual(entry, tt.wantEntry) {
+				t.Errorf("placeStudent() = %+v, want %+v", entry, tt.wantEntry)
+			}
+			if !reflect.DeepEqual(school.classOf, tt.wantClassOf) {
+				t.Errorf("classes = %v, want %v", school.classOf, tt.wantClassOf)
+			}
+			if len(school.waitlist) != 0 || len(tt.wantWaitlist) != 0 {
+				if !reflect.DeepEqual(school.waitlist, tt.wantWaitlist) {
+					t.Errorf("waitlist = %v, want %v", school.waitlist, tt.wantWaitlist)
+				}
+			}
+			if !reflect.DeepEqual(school.moves, tt.wantMoves) {
+				t.Errorf("moves = %q, want %q", school.moves, tt.wantMoves)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Package cache keeps computed values in memory for a short time.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// Cache is a map whose entries expire ttl after they were set. It is safe to
+// use from several goroutines.
+type Cache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+func New(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, entries: make(map[string]entry)}
+}
+
+func (c *Cache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Get returns the value for key if it has not expired yet
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set stores value under key and drops every entry that has expired, so keys
+// that are never asked for again do not pile up
+func (c *Cache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry{value: value, expires: now.Add(c.ttl)}
+}
+
+// Clear drops every entry
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]entry)
+}
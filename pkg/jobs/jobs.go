@@ -0,0 +1,131 @@
+// Package jobs runs long requests in the background. Jobs are kept in
+// memory, so they are lost when the server restarts.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	Pending Status = "pending"
+	Running Status = "running"
+	Done    Status = "done"
+	Failed  Status = "failed"
+)
+
+type Job struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     Status     `json:"status"`
+	Progress   int        `json:"progress"`
+	Total      int        `json:"total"`
+	Error      string     `json:"error,omitempty"`
+	ResultKey  string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Tracker lets a running job report how far it is
+type Tracker struct {
+	id string
+}
+
+func (t Tracker) SetTotal(total int) {
+	update(t.id, func(j *Job) { j.Total = total })
+}
+
+func (t Tracker) Step() {
+	update(t.id, func(j *Job) { j.Progress++ })
+}
+
+var (
+	mu   sync.Mutex
+	jobs = make(map[string]*Job)
+)
+
+func update(id string, fn func(j *Job)) {
+	mu.Lock()
+	defer mu.Unlock()
+	if job, ok := jobs[id]; ok {
+		fn(job)
+	}
+}
+
+// Start runs fn in its own goroutine and returns the pending job straight
+// away. fn returns the blob key of its result.
+func Start(kind string, fn func(t Tracker) (string, error)) Job {
+	b := make([]byte, 8)
+	rand.Read(b)
+	job := &Job{
+		ID:        hex.EncodeToString(b),
+		Kind:      kind,
+		Status:    Pending,
+		CreatedAt: time.Now(),
+	}
+
+	mu.Lock()
+	jobs[job.ID] = job
+	started := *job
+	mu.Unlock()
+
+	go func() {
+		update(job.ID, func(j *Job) { j.Status = Running })
+
+		var key string
+		var err error
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("job panicked: %v", r)
+				}
+			}()
+			key, err = fn(Tracker{id: job.ID})
+		}()
+
+		update(job.ID, func(j *Job) {
+			now := time.Now()
+			j.FinishedAt = &now
+			if err != nil {
+				j.Status = Failed
+				j.Error = err.Error()
+				return
+			}
+			j.Status = Done
+			j.ResultKey = key
+		})
+	}()
+
+	return started
+}
+
+// Get returns a copy of the job
+func Get(id string) (Job, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	job, ok := jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Prune forgets jobs that finished more than maxAge ago and returns them, so
+// the caller can remove their results.
+func Prune(maxAge time.Duration) []Job {
+	mu.Lock()
+	defer mu.Unlock()
+	var pruned []Job
+	for id, job := range jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > maxAge {
+			pruned = append(pruned, *job)
+			delete(jobs, id)
+		}
+	}
+	return pruned
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestS3Escape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "students/7/photo.jpg", want: "students/7/photo.jpg"},
+		{in: "AZaz09-_.~", want: "AZaz09-_.~"},
+		{in: "a b", want: "a%20b"},
+		{in: "a+b=c&d", want: "a%2Bb%3Dc%26d"},
+		{in: "100%", want: "100%25"},
+		{in: "é", want: "%C3%A9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := s3Escape(tt.in); got != tt.want {
+				t.Errorf("s3Escape(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// The signature was worked out separately from the steps in the AWS
+// signature version 4 documentation
+func TestS3RequestSignature(t *testing.T) {
+	s, err := NewS3Store(S3Config{Endpoint: "http://127.0.0.1:9000/", Bucket: "photos", Region: "eu-west-1", AccessKey: "key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC) }
+
+	req, err := s.request(http.MethodPut, "students/7/a b+c.jpg", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.URL.EscapedPath(), "/photos/students/7/a%20b%2Bc.jpg"; got != want {
+		t.Errorf("path = %q, want %q", got, want)
+	}
+	if got, want := req.Header.Get("X-Amz-Date"), "20240115T093000Z"; got != want {
+		t.Errorf("X-Amz-Date = %q, want %q", got, want)
+	}
+	if got, want := req.Header.Get("X-Amz-Content-Sha256"), "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+		t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, want)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=key/20240115/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=daae212e3608dbfaa6de689c4a5d7adff465a66148bb4de48ee1c0117b95d03e"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	objects := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		path := r.URL.EscapedPath()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[path] = string(body)
+		case http.MethodGet:
+			body, ok := objects[path]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, body)
+		case http.MethodDelete:
+			delete(objects, path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewS3Store(S3Config{Endpoint: server.URL, Bucket: "photos", AccessKey: "key", SecretKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Put("students/7/a b.jpg", strings.NewReader("jpeg"), "image/jpeg")
+	if err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if _, ok := objects["/photos/students/7/a%20b.jpg"]; !ok {
+		t.Fatalf("Put() stored %v, want /photos/students/7/a%%20b.jpg", objects)
+	}
+
+	r, err := s.Open("students/7/a b.jpg")
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	body, _ := io.ReadAll(r)
+	r.Close()
+	if string(body) != "jpeg" {
+		t.Errorf("Open() read %q, want %q", body, "jpeg")
+	}
+
+	err = s.Delete("students/7/a b.jpg")
+	if err != nil {
+		t.Fatalf("Delete() = %v", err)
+	}
+	_, err = s.Open("students/7/a b.jpg")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() after Delete() = %v, want ErrNotFound", err)
+	}
+
+	s.cfg.AccessKey = "other"
+	err = s.Put("students/7/b.jpg", strings.NewReader("jpeg"), "")
+	if err == nil || err.Error() != "s3 403 Forbidden: AccessDenied" {
+		t.Errorf("Put() with a bad key = %v, want the s3 error", err)
+	}
+}
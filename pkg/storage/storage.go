@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNotFound is returned by Open when no blob is stored under the key
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files outside of the database. Keys are slash
+// separated paths such as "submissions/4/12/3f9a".
+type BlobStore interface {
+	Put(key string, r io.Reader, contentType string) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// FromEnv returns the store configured through the environment.
+//
+// BLOB_STORE=local (the default) keeps files in BLOB_DIR, "uploads" when
+// unset. BLOB_STORE=s3 uses an S3 compatible service (AWS, MinIO, ...)
+// configured by S3_ENDPOINT, S3_BUCKET, S3_REGION, S3_ACCESS_KEY and
+// S3_SECRET_KEY.
+func FromEnv() (BlobStore, error) {
+	switch os.Getenv("BLOB_STORE") {
+	case "", "local":
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", os.Getenv("BLOB_STORE"))
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Compares two models by their JSON fields and returns the fields that
+// differ on each side. A nil model gives a nil map and the other side is
+// returned whole. Fields left out through omitempty count as null.
+func DiffFields(before interface{}, after interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	if beforeFields == nil || afterFields == nil {
+		return beforeFields, afterFields, nil
+	}
+
+	changedBefore := make(map[string]interface{})
+	changedAfter := make(map[string]interface{})
+	for key, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[key]) {
+			changedBefore[key] = value
+			changedAfter[key] = afterFields[key]
+		}
+	}
+	for key, value := range afterFields {
+		if _, ok := beforeFields[key]; !ok {
+			changedBefore[key] = nil
+			changedAfter[key] = value
+		}
+	}
+	return changedBefore, changedAfter, nil
+}
+
+func jsonFields(model interface{}) (map[string]interface{}, error) {
+	if model == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+type diffModel struct {
+	ID        int     `json:"id,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	ClassID   int     `json:"class_id,omitempty"`
+	DeletedAt *string `json:"deleted_at,omitempty"`
+}
+
+func TestDiffFields(t *testing.T) {
+	deletedAt := "2024-01-15 09:30:00"
+	tests := []struct {
+		name       string
+		before     interface{}
+		after      interface{}
+		wantBefore map[string]interface{}
+		wantAfter  map[string]interface{}
+	}{
+		{
+			name:       "same",
+			before:     diffModel{ID: 1, Name: "Ada"},
+			after:      diffModel{ID: 1, Name: "Ada"},
+			wantBefore: map[string]interface{}{},
+			wantAfter:  map[string]interface{}{},
+		},
+		{
+			name:       "changed field",
+			before:     diffModel{ID: 1, Name: "Ada", ClassID: 2},
+			after:      diffModel{ID: 1, Name: "Grace", ClassID: 2},
+			wantBefore: map[string]interface{}{"name": "Ada"},
+			wantAfter:  map[string]interface{}{"name": "Grace"},
+		},
+		{
+			name:       "field set",
+			before:     diffModel{ID: 1, Name: "Ada"},
+			after:      diffModel{ID: 1, Name: "Ada", DeletedAt: &deletedAt},
+			wantBefore: map[string]interface{}{"deleted_at": nil},
+			wantAfter:  map[string]interface{}{"deleted_at": deletedAt},
+		},
+		{
+			name:       "field cleared",
+			before:     diffModel{ID: 1, Name: "Ada", ClassID: 2},
+			after:      diffModel{ID: 1, Name: "Ada"},
+			wantBefore: map[string]interface{}{"class_id": float64(2)},
+			wantAfter:  map[string]interface{}{"class_id": nil},
+		},
+		{
+			name:      "created",
+			after:     diffModel{ID: 1, Name: "Ada"},
+			wantAfter: map[string]interface{}{"id": float64(1), "name": "Ada"},
+		},
+		{
+			name:       "removed",
+			before:     diffModel{ID: 1, Name: "Ada"},
+			wantBefore: map[string]interface{}{"id": float64(1), "name": "Ada"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after, err := DiffFields(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("DiffFields() error = %v", err)
+			}
+			if !reflect.DeepEqual(before, tt.wantBefore) {
+				t.Errorf("DiffFields() before = %v, want %v", before, tt.wantBefore)
+			}
+			if !reflect.DeepEqual(after, tt.wantAfter) {
+				t.Errorf("DiffFields() after = %v, want %v", after, tt.wantAfter)
+			}
+		})
+	}
+}
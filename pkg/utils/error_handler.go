@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -13,3 +14,53 @@ func ErrorHandler(err error, msg string) error {
 	errorLogger.Println(msg, err)
 	return fmt.Errorf("%s", msg)
 }
+
+// Errors returned by ConflictHandler, InvalidHandler, StaleHandler and
+// NotFoundHandler match these, so handlers can answer with 409, 400, 412 or
+// 404 instead of 500
+var (
+	ErrConflict           = errors.New("conflict")
+	ErrInvalid            = errors.New("invalid request")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrNotFound           = errors.New("not found")
+)
+
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e kindError) Error() string {
+	return e.msg
+}
+
+func (e kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// Like ErrorHandler, but for requests that clash with existing data
+// (e.g. a unique key).
+func ConflictHandler(err error, msg string) error {
+	ErrorHandler(err, msg)
+	return kindError{msg: msg, kind: ErrConflict}
+}
+
+// Like ErrorHandler, but for requests the database layer rejects as invalid
+// (e.g. a score above the maximum).
+func InvalidHandler(err error, msg string) error {
+	ErrorHandler(err, msg)
+	return kindError{msg: msg, kind: ErrInvalid}
+}
+
+// Like ErrorHandler, but for writes based on an older version of the row
+// than the one stored
+func StaleHandler(err error, msg string) error {
+	ErrorHandler(err, msg)
+	return kindError{msg: msg, kind: ErrPreconditionFailed}
+}
+
+// Like ErrorHandler, but for rows that do not exist
+func NotFoundHandler(err error, msg string) error {
+	ErrorHandler(err, msg)
+	return kindError{msg: msg, kind: ErrNotFound}
+}
@@ -51,7 +51,7 @@ func GenerateUpdateQuery(table string, model interface{}) string {
 			if updates != "" {
 				updates += ", "
 			}
-			updates += fmt.Sprintf("%s = \"%s\"", dbTag, modelVal.Field(i).Interface())
+			updates += fmt.Sprintf("%s = \"%v\"", dbTag, modelVal.Field(i).Interface())
 		}
 	}
 
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// One VEVENT. Times are written as floating local times, so they show at the
+// same wall clock time in every calendar. Until is the last day a weekly
+// event repeats on, leave it zero for a one-off event.
+type ICalEvent struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Until       time.Time
+}
+
+const icalTimeFormat = "20060102T150405"
+
+// Writes a VCALENDAR (RFC 5545) with the given events to w
+func WriteICalendar(w io.Writer, name string, events []ICalEvent) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//restapi//timetable//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:" + icalEscape(name),
+	}
+
+	stamp := time.Now().UTC().Format(icalTimeFormat) + "Z"
+	for _, e := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+e.UID,
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+e.Start.Format(icalTimeFormat),
+			"DTEND:"+e.End.Format(icalTimeFormat),
+			"SUMMARY:"+icalEscape(e.Summary),
+		)
+		if e.Location != "" {
+			lines = append(lines, "LOCATION:"+icalEscape(e.Location))
+		}
+		if e.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+icalEscape(e.Description))
+		}
+		if !e.Until.IsZero() {
+			until := time.Date(e.Until.Year(), e.Until.Month(), e.Until.Day(), 23, 59, 59, 0, time.Local)
+			lines = append(lines, "RRULE:FREQ=WEEKLY;UNTIL="+until.Format(icalTimeFormat))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		_, err := io.WriteString(w, icalFold(line))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Escapes the characters that have a meaning in TEXT values
+func icalEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// Ends the line with CRLF and folds it every 75 octets, without splitting a
+// multi byte character
+func icalFold(line string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range line {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// First date on or after from that falls on the ISO weekday (1 = Monday)
+func NextWeekday(from time.Time, isoWeekday int) time.Time {
+	offset := (isoWeekday%7 - int(from.Weekday()) + 7) % 7
+	return from.AddDate(0, 0, offset)
+}
+
+// Combines a date and an HH:MM time into one local time
+func DateAt(date time.Time, clock string) (time.Time, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", clock)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, time.Local), nil
+}
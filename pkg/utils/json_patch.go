@@ -0,0 +1,422 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types of the two patch formats PATCH accepts
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// One problem with a patch. Field is the JSON field, or the JSON Pointer of
+// an operation that could not be applied.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Everything wrong with a patch at once, so the client can fix it in one go.
+// Matches ErrInvalid.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fieldErr := range e {
+		msgs[i] = fieldErr.Field + " " + fieldErr.Message
+	}
+	return strings.Join(msgs, ", ")
+}
+
+func (e FieldErrors) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// A JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) for one of the
+// models, applied with ApplyTo
+type Patch struct {
+	merge interface{}
+	ops   []PatchOperation
+}
+
+// One operation of a JSON Patch. Value stays nil when it is not given, and
+// holds "null" when it is given as null.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func MergePatch(body []byte) (Patch, error) {
+	var merge interface{}
+	err := json.Unmarshal(body, &merge)
+	if err != nil {
+		return Patch{}, InvalidHandler(err, "merge patch is not valid JSON")
+	}
+	if _, ok := merge.(map[string]interface{}); !ok {
+		return Patch{}, InvalidHandler(nil, "merge patch must be a JSON object")
+	}
+	return Patch{merge: merge}, nil
+}
+
+func JSONPatch(body []byte) (Patch, error) {
+	var ops []PatchOperation
+	err := json.Unmarshal(body, &ops)
+	if err != nil {
+		return Patch{}, InvalidHandler(err, "JSON patch must be an array of operations")
+	}
+
+	var errs FieldErrors
+	for i, op := range ops {
+		prefix := fmt.Sprintf("operation %d (%s): ", i, op.Op)
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				errs = append(errs, FieldError{op.Path, prefix + "value is required"})
+			}
+		case "move", "copy":
+			_, err = parsePointer(op.From)
+			if err != nil {
+				errs = append(errs, FieldError{op.From, prefix + "from " + err.Error()})
+			}
+		case "remove":
+		default:
+			errs = append(errs, FieldError{op.Path, fmt.Sprintf("operation %d: unknown op %q", i, op.Op)})
+			continue
+		}
+		_, err = parsePointer(op.Path)
+		if err != nil {
+			errs = append(errs, FieldError{op.Path, prefix + "path " + err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return Patch{}, errs
+	}
+	return Patch{ops: ops}, nil
+}
+
+// Applies the patch to model, a pointer to a struct, through its JSON form.
+// Fields without a db tag and the id are read-only. Unknown fields, values
+// of the wrong type and removed fields come back as FieldErrors, a failed
+// JSON Patch test as a StaleHandler error.
+func (p Patch) ApplyTo(model interface{}) error {
+	current, err := json.Marshal(model)
+	if err != nil {
+		return ErrorHandler(err, "error encoding model")
+	}
+	var before, doc interface{}
+	json.Unmarshal(current, &before)
+	json.Unmarshal(current, &doc)
+
+	if p.ops == nil {
+		doc = mergePatch(doc, p.merge)
+	} else {
+		doc, err = applyOperations(doc, p.ops)
+		if err != nil {
+			return err
+		}
+	}
+
+	typ := reflect.TypeOf(model).Elem()
+	errs := checkPatchedFields(typ, before.(map[string]interface{}), doc)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return ErrorHandler(err, "error encoding patched model")
+	}
+	modelVal := reflect.ValueOf(model).Elem()
+	modelVal.Set(reflect.Zero(typ))
+	err = json.Unmarshal(patched, model)
+	if err != nil {
+		return ErrorHandler(err, "error decoding patched model")
+	}
+	return nil
+}
+
+// RFC 7396: null removes a member, objects are merged, anything else
+// replaces what was there
+func mergePatch(doc interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	docObj, ok := doc.(map[string]interface{})
+	if !ok {
+		docObj = make(map[string]interface{})
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(docObj, key)
+		} else {
+			docObj[key] = mergePatch(docObj[key], value)
+		}
+	}
+	return docObj
+}
+
+func applyOperations(doc interface{}, ops []PatchOperation) (interface{}, error) {
+	for i, op := range ops {
+		path, _ := parsePointer(op.Path)
+		var value interface{}
+		if op.Value != nil {
+			json.Unmarshal(op.Value, &value)
+		}
+
+		var err error
+		switch op.Op {
+		case "add":
+			doc, err = addValue(doc, path, value)
+		case "remove":
+			doc, _, err = removeValue(doc, path)
+		case "replace":
+			if len(path) == 0 {
+				doc = value
+				break
+			}
+			doc, _, err = removeValue(doc, path)
+			if err == nil {
+				doc, err = addValue(doc, path, value)
+			}
+		case "move":
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				err = fmt.Errorf("cannot move a value into itself")
+				break
+			}
+			from, _ := parsePointer(op.From)
+			doc, value, err = removeValue(doc, from)
+			if err == nil {
+				doc, err = addValue(doc, path, value)
+			}
+		case "copy":
+			from, _ := parsePointer(op.From)
+			value, err = getValue(doc, from)
+			if err == nil {
+				doc, err = addValue(doc, path, deepCopy(value))
+			}
+		case "test":
+			current, getErr := getValue(doc, path)
+			if getErr != nil || !reflect.DeepEqual(current, value) {
+				return nil, StaleHandler(nil, fmt.Sprintf("operation %d (test): %s is not %s", i, op.Path, op.Value))
+			}
+		}
+		if err != nil {
+			return nil, FieldErrors{{op.Path, fmt.Sprintf("operation %d (%s): %v", i, op.Op, err)}}
+		}
+	}
+	return doc, nil
+}
+
+// Splits a JSON Pointer (RFC 6901) into its unescaped tokens, "" is the
+// whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("must be empty or start with /")
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("path does not exist")
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[i]
+		default:
+			return nil, fmt.Errorf("path does not exist")
+		}
+	}
+	return doc, nil
+}
+
+// Runs change on the container holding the last token of path and puts what
+// it returns back in its place
+func updateParent(doc interface{}, path []string, change func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("path does not exist")
+		}
+		child, err := updateParent(child, path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		container[path[0]] = child
+		return container, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updateParent(container[i], path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		container[i] = child
+		return container, nil
+	}
+	return nil, fmt.Errorf("path does not exist")
+}
+
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			if token == "-" {
+				return append(c, value), nil
+			}
+			i, err := arrayIndex(token, len(c))
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		return nil, fmt.Errorf("path does not exist")
+	})
+}
+
+// Also returns the value that was removed
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document")
+	}
+	var removed interface{}
+	doc, err := updateParent(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			value, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("path does not exist")
+			}
+			removed = value
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = c[i]
+			return append(c[:i], c[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("path does not exist")
+	})
+	return doc, removed, err
+}
+
+// Reads an array index from 0 to max
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("array index %q is out of range", token)
+	}
+	return i, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	data, _ := json.Marshal(value)
+	var copied interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+// Compares the patched document with the model it was made from
+func checkPatchedFields(typ reflect.Type, before map[string]interface{}, doc interface{}) FieldErrors {
+	after, ok := doc.(map[string]interface{})
+	if !ok {
+		return FieldErrors{{"", "must be a JSON object"}}
+	}
+
+	var errs FieldErrors
+	for key, value := range after {
+		field, ok := jsonField(typ, key)
+		if !ok {
+			errs = append(errs, FieldError{key, "is not a known field"})
+			continue
+		}
+		if field.Name == "ID" || field.Tag.Get("db") == "" {
+			if !reflect.DeepEqual(before[key], value) {
+				errs = append(errs, FieldError{key, "is read-only"})
+			}
+			continue
+		}
+		if value == nil {
+			errs = append(errs, FieldError{key, "cannot be null"})
+			continue
+		}
+		data, _ := json.Marshal(value)
+		if json.Unmarshal(data, reflect.New(field.Type).Interface()) != nil {
+			errs = append(errs, FieldError{key, "must be " + typeName(field.Type)})
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			errs = append(errs, FieldError{key, "cannot be removed"})
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func jsonField(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if strings.Split(field.Tag.Get("json"), ",")[0] == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func typeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	case reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	}
+	return "a " + typ.String()
+}
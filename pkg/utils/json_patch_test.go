@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type patchModel struct {
+	ID      int    `json:"id,omitempty" db:"id,omitempty"`
+	Name    string `json:"name,omitempty" db:"name,omitempty"`
+	Age     int    `json:"age,omitempty" db:"age,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+func newPatchModel() patchModel {
+	return patchModel{ID: 1, Name: "Ada", Age: 36, Version: 4}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    patchModel
+		wantErr error
+		fields  []string
+	}{
+		{name: "replaces a field", body: `{"name": "Grace"}`, want: patchModel{ID: 1, Name: "Grace", Age: 36, Version: 4}},
+		{name: "empty patch", body: `{}`, want: newPatchModel()},
+		{name: "same id is fine", body: `{"id": 1, "age": 37}`, want: patchModel{ID: 1, Name: "Ada", Age: 37, Version: 4}},
+		{name: "not json", body: `{`, wantErr: ErrInvalid},
+		{name: "not an object", body: `["name"]`, wantErr: ErrInvalid},
+		{name: "null removes", body: `{"name": null}`, wantErr: ErrInvalid, fields: []string{"name"}},
+		{name: "unknown field", body: `{"nickname": "A"}`, wantErr: ErrInvalid, fields: []string{"nickname"}},
+		{name: "id is read-only", body: `{"id": 2}`, wantErr: ErrInvalid, fields: []string{"id"}},
+		{name: "field without db tag is read-only", body: `{"version": 9}`, wantErr: ErrInvalid, fields: []string{"version"}},
+		{name: "wrong type", body: `{"age": "old"}`, wantErr: ErrInvalid, fields: []string{"age"}},
+		{name: "every error at once", body: `{"age": "old", "id": 2, "x": 1}`, wantErr: ErrInvalid, fields: []string{"age", "id", "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newPatchModel()
+			patch, err := MergePatch([]byte(tt.body))
+			if err == nil {
+				err = patch.ApplyTo(&model)
+			}
+			checkPatchResult(t, model, err, tt.want, tt.wantErr, tt.fields)
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    patchModel
+		wantErr error
+		fields  []string
+	}{
+		{name: "replace", body: `[{"op": "replace", "path": "/name", "value": "Grace"}]`, want: patchModel{ID: 1, Name: "Grace", Age: 36, Version: 4}},
+		{name: "add over a field", body: `[{"op": "add", "path": "/age", "value": 40}]`, want: patchModel{ID: 1, Name: "Ada", Age: 40, Version: 4}},
+		{name: "test then replace", body: `[{"op": "test", "path": "/age", "value": 36}, {"op": "replace", "path": "/age", "value": 37}]`,
+			want: patchModel{ID: 1, Name: "Ada", Age: 37, Version: 4}},
+		{name: "failed test", body: `[{"op": "test", "path": "/age", "value": 35}]`, wantErr: ErrPreconditionFailed},
+		{name: "test of a missing path", body: `[{"op": "test", "path": "/nickname", "value": "A"}]`, wantErr: ErrPreconditionFailed},
+		{name: "remove", body: `[{"op": "remove", "path": "/name"}]`, wantErr: ErrInvalid, fields: []string{"name"}},
+		{name: "remove a missing path", body: `[{"op": "remove", "path": "/nickname"}]`, wantErr: ErrInvalid, fields: []string{"/nickname"}},
+		{name: "move to an unknown field", body: `[{"op": "move", "from": "/name", "path": "/nickname"}]`, wantErr: ErrInvalid, fields: []string{"name", "nickname"}},
+		{name: "copy to a known field", body: `[{"op": "copy", "from": "/age", "path": "/id"}]`, wantErr: ErrInvalid, fields: []string{"id"}},
+		{name: "not an array", body: `{"op": "remove"}`, wantErr: ErrInvalid},
+		{name: "unknown op", body: `[{"op": "rename", "path": "/name"}]`, wantErr: ErrInvalid, fields: []string{"/name"}},
+		{name: "value missing", body: `[{"op": "replace", "path": "/name"}]`, wantErr: ErrInvalid, fields: []string{"/name"}},
+		{name: "path without slash", body: `[{"op": "remove", "path": "name"}]`, wantErr: ErrInvalid, fields: []string{"name"}},
+		{name: "from without slash", body: `[{"op": "copy", "from": "name", "path": "/name"}]`, wantErr: ErrInvalid, fields: []string{"name"}},
+		{name: "replace the document", body: `[{"op": "replace", "path": "", "value": [1]}]`, wantErr: ErrInvalid, fields: []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := newPatchModel()
+			patch, err := JSONPatch([]byte(tt.body))
+			if err == nil {
+				err = patch.ApplyTo(&model)
+			}
+			checkPatchResult(t, model, err, tt.want, tt.wantErr, tt.fields)
+		})
+	}
+}
+
+func checkPatchResult(t *testing.T, got patchModel, err error, want patchModel, wantErr error, fields []string) {
+	t.Helper()
+	if wantErr == nil {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+		return
+	}
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("got error %v, want one matching %v", err, wantErr)
+	}
+	if fields == nil {
+		return
+	}
+	var fieldErrs FieldErrors
+	if !errors.As(err, &fieldErrs) {
+		t.Fatalf("got error %T, want FieldErrors", err)
+	}
+	var gotFields []string
+	for _, fieldErr := range fieldErrs {
+		gotFields = append(gotFields, fieldErr.Field)
+	}
+	if !reflect.DeepEqual(gotFields, fields) {
+		t.Fatalf("got errors for %q, want %q", gotFields, fields)
+	}
+}
+
+func TestApplyOperationsOnArrays(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      PatchOperation
+		want    []interface{}
+		wantErr bool
+	}{
+		{name: "append", op: PatchOperation{Op: "add", Path: "/list/-", Value: []byte(`"d"`)}, want: []interface{}{"a", "b", "c", "d"}},
+		{name: "insert", op: PatchOperation{Op: "add", Path: "/list/1", Value: []byte(`"x"`)}, want: []interface{}{"a", "x", "b", "c"}},
+		{name: "insert at the end", op: PatchOperation{Op: "add", Path: "/list/3", Value: []byte(`"x"`)}, want: []interface{}{"a", "b", "c", "x"}},
+		{name: "remove", op: PatchOperation{Op: "remove", Path: "/list/0"}, want: []interface{}{"b", "c"}},
+		{name: "replace", op: PatchOperation{Op: "replace", Path: "/list/2", Value: []byte(`"z"`)}, want: []interface{}{"a", "b", "z"}},
+		{name: "move", op: PatchOperation{Op: "move", From: "/list/0", Path: "/list/-"}, want: []interface{}{"b", "c", "a"}},
+		{name: "index past the end", op: PatchOperation{Op: "remove", Path: "/list/3"}, wantErr: true},
+		{name: "leading zero", op: PatchOperation{Op: "remove", Path: "/list/01"}, wantErr: true},
+		{name: "negative index", op: PatchOperation{Op: "add", Path: "/list/-1", Value: []byte(`"x"`)}, wantErr: true},
+		{name: "move into itself", op: PatchOperation{Op: "move", From: "/list", Path: "/list/0"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := map[string]interface{}{"list": []interface{}{"a", "b", "c"}}
+			got, err := applyOperations(doc, []PatchOperation{tt.op})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			list := got.(map[string]interface{})["list"]
+			if !reflect.DeepEqual(list, tt.want) {
+				t.Fatalf("got %v, want %v", list, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		want    []string
+		wantErr bool
+	}{
+		{pointer: "", want: nil},
+		{pointer: "/", want: []string{""}},
+		{pointer: "/name", want: []string{"name"}},
+		{pointer: "/a/0/b", want: []string{"a", "0", "b"}},
+		{pointer: "/a~1b", want: []string{"a/b"}},
+		{pointer: "/a~0b", want: []string{"a~b"}},
+		{pointer: "/~01", want: []string{"~1"}},
+		{pointer: "name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pointer, func(t *testing.T) {
+			got, err := parsePointer(tt.pointer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
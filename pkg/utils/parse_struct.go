@@ -22,25 +22,32 @@ func GetStructValues(model interface{}) []interface{} {
 	return values
 }
 
-// Takes a model and gets the current db value. Then iterate over update
-// map to upadate the model. Call sqlconnect.GenerateUpdateQuery() after this.
-func PatchTeacherModel(db *sql.DB, tabel string, id int, model *models.Teacher, update map[string]interface{}) error {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id = %d", tabel, id)
-	err := db.QueryRow(query).Scan(
+// Takes a model and gets the current db value, locked by tx. Then iterate
+// over update map to upadate the model.
+func PatchClassModel(tx *sql.Tx, id int, model *models.Class, update map[string]interface{}) error {
+	err := tx.QueryRow("SELECT id, name, grade, section, room, capacity, COALESCE(homeroom_teacher_id, 0), COALESCE(academic_year_id, 0) FROM classes WHERE id = ? FOR UPDATE", id).Scan(
 		&model.ID,
-		&model.FirstName,
-		&model.LastName,
-		&model.Email,
-		&model.Class,
-		&model.Subject,
+		&model.Name,
+		&model.Grade,
+		&model.Section,
+		&model.Room,
+		&model.Capacity,
+		&model.HomeroomTeacherID,
+		&model.AcademicYearID,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return ErrorHandler(err, "teacher not found")
+			return NotFoundHandler(err, "class not found")
 		}
-		return ErrorHandler(err, "error retrieving teacher")
+		return ErrorHandler(err, "error retrieving class")
 	}
 
+	return applyModelUpdates(model, update)
+}
+
+// Sets every field of the pointed to model whose json tag matches a key in
+// update. The id is never overwritten.
+func applyModelUpdates(model interface{}, update map[string]interface{}) error {
 	modelVal := reflect.ValueOf(model).Elem()
 	modelTyp := modelVal.Type()
 
@@ -57,10 +64,10 @@ func PatchTeacherModel(db *sql.DB, tabel string, id int, model *models.Teacher,
 				if fieldVal.CanSet() {
 					val := reflect.ValueOf(v)
 
-					if val.Type().ConvertibleTo(fieldVal.Type()) {
+					if val.IsValid() && val.Type().ConvertibleTo(fieldVal.Type()) {
 						fieldVal.Set(val.Convert(fieldVal.Type()))
 					} else {
-						msg := fmt.Sprintf("cannot convert %v to %v", val.Type(), fieldVal.Type())
+						msg := fmt.Sprintf("cannot convert %v to %v", val.Kind(), fieldVal.Type())
 						return ErrorHandler(fmt.Errorf("%s", msg), msg)
 					}
 				}
@@ -72,49 +79,62 @@ func PatchTeacherModel(db *sql.DB, tabel string, id int, model *models.Teacher,
 }
 
 // Takes a model and gets the current db value. Then iterate over update
-// map to upadate the model. Call sqlconnect.GenerateUpdateQuery() after this.
-func PatchStudentModel(db *sql.DB, tabel string, id int, model *models.Student, update map[string]interface{}) error {
-	query := fmt.Sprintf("SELECT * FROM %s WHERE id = %d", tabel, id)
-	err := db.QueryRow(query).Scan(
+// map to upadate the model.
+func PatchSubjectModel(db *sql.DB, id int, model *models.Subject, update map[string]interface{}) error {
+	err := db.QueryRow("SELECT id, name, code FROM subjects WHERE id = ?", id).Scan(
 		&model.ID,
-		&model.FirstName,
-		&model.LastName,
-		&model.Email,
-		&model.Class,
+		&model.Name,
+		&model.Code,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return ErrorHandler(err, "teacher not found")
+			return ErrorHandler(err, "subject not found")
 		}
-		return ErrorHandler(err, "error retrieving teacher")
+		return ErrorHandler(err, "error retrieving subject")
 	}
 
-	modelVal := reflect.ValueOf(model).Elem()
-	modelTyp := modelVal.Type()
+	return applyModelUpdates(model, update)
+}
 
-	for k, v := range update {
-		if k == "id" {
-			continue
+// Takes a model and gets the current db value. Then iterate over update
+// map to upadate the model.
+func PatchTeachingAssignmentModel(db *sql.DB, id int, model *models.TeachingAssignment, update map[string]interface{}) error {
+	err := db.QueryRow("SELECT id, teacher_id, class_id, subject_id, COALESCE(term_id, 0) FROM teaching_assignments WHERE id = ?", id).Scan(
+		&model.ID,
+		&model.TeacherID,
+		&model.ClassID,
+		&model.SubjectID,
+		&model.TermID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrorHandler(err, "teaching assignment not found")
 		}
-		for i := 0; i < modelVal.NumField(); i++ {
-			field := modelTyp.Field(i)
-
-			if field.Tag.Get("json") == k+",omitempty" {
-				fieldVal := modelVal.Field(i)
+		return ErrorHandler(err, "error retrieving teaching assignment")
+	}
 
-				if fieldVal.CanSet() {
-					val := reflect.ValueOf(v)
+	return applyModelUpdates(model, update)
+}
 
-					if val.Type().ConvertibleTo(fieldVal.Type()) {
-						fieldVal.Set(val.Convert(fieldVal.Type()))
-					} else {
-						msg := fmt.Sprintf("cannot convert %v to %v", val.Type(), fieldVal.Type())
-						return ErrorHandler(fmt.Errorf("%s", msg), msg)
-					}
-				}
-				break
-			}
+// Takes a model and gets the current db value. Then iterate over update
+// map to upadate the model.
+func PatchAssessmentModel(db *sql.DB, id int, model *models.Assessment, update map[string]interface{}) error {
+	err := db.QueryRow("SELECT id, class_id, subject_id, title, max_score, weight, due_date, COALESCE(term_id, 0) FROM assessments WHERE id = ?", id).Scan(
+		&model.ID,
+		&model.ClassID,
+		&model.SubjectID,
+		&model.Title,
+		&model.MaxScore,
+		&model.Weight,
+		&model.DueDate,
+		&model.TermID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrorHandler(err, "assessment not found")
 		}
+		return ErrorHandler(err, "error retrieving assessment")
 	}
-	return nil
+
+	return applyModelUpdates(model, update)
 }
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	signingKey     []byte
+	signingKeyOnce sync.Once
+)
+
+// URL_SIGNING_KEY signs download links. Without it a random key is used,
+// and links stop working when the server restarts.
+func urlSigningKey() []byte {
+	signingKeyOnce.Do(func() {
+		signingKey = []byte(os.Getenv("URL_SIGNING_KEY"))
+		if len(signingKey) == 0 {
+			signingKey = make([]byte, 32)
+			rand.Read(signingKey)
+		}
+	})
+	return signingKey
+}
+
+func urlSignature(path string, expires int64) string {
+	mac := hmac.New(sha256.New, urlSigningKey())
+	fmt.Fprintf(mac, "%s\n%d", path, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Returns path with "expires" and "signature" query parameters, valid for ttl
+func SignURL(path string, ttl time.Duration) string {
+	expires := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", urlSignature(path, expires))
+	return path + "?" + q.Encode()
+}
+
+// Checks a url made by SignURL. Fails when it was changed or has expired.
+func VerifySignedURL(u *url.URL) error {
+	expires, err := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if err != nil {
+		return ErrorHandler(err, "invalid or missing expires parameter")
+	}
+	if time.Now().Unix() > expires {
+		return ErrorHandler(nil, "download link has expired")
+	}
+	signature, err := hex.DecodeString(u.Query().Get("signature"))
+	if err != nil {
+		return ErrorHandler(err, "invalid signature")
+	}
+	expected, _ := hex.DecodeString(urlSignature(u.Path, expires))
+	if !hmac.Equal(signature, expected) {
+		return ErrorHandler(nil, "invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignedURL(t *testing.T) {
+	const path = "/students/7/photo"
+	tests := []struct {
+		name    string
+		url     func() string
+		wantErr string
+	}{
+		{name: "valid", url: func() string { return SignURL(path, time.Minute) }},
+		{name: "other path", url: func() string {
+			return strings.Replace(SignURL(path, time.Minute), "/7/", "/8/", 1)
+		}, wantErr: "invalid signature"},
+		{name: "expired", url: func() string { return SignURL(path, -time.Minute) }, wantErr: "download link has expired"},
+		{name: "expires pushed back", url: func() string {
+			u, _ := url.Parse(SignURL(path, time.Minute))
+			q := u.Query()
+			q.Set("expires", "99999999999")
+			return path + "?" + q.Encode()
+		}, wantErr: "invalid signature"},
+		{name: "missing expires", url: func() string { return path + "?signature=00" }, wantErr: "invalid or missing expires parameter"},
+		{name: "signature not hex", url: func() string {
+			return path + "?expires=99999999999&signature=zz"
+		}, wantErr: "invalid signature"},
+		{name: "missing signature", url: func() string { return path + "?expires=99999999999" }, wantErr: "invalid signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = VerifySignedURL(u)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifySignedURL() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("VerifySignedURL() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// The parts of a sheet the tests look at
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSXWriter(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]interface{}
+		want [][]string
+	}{
+		{name: "no rows", rows: nil, want: nil},
+		{name: "strings and ints", rows: [][]interface{}{{"name", "age"}, {"Ada", 36}},
+			want: [][]string{{"s:name", "s:age"}, {"s:Ada", "n:36"}}},
+		{name: "markup is escaped", rows: [][]interface{}{{`<b>&"x"</b>`}}, want: [][]string{{`s:<b>&"x"</b>`}}},
+		{name: "spaces are kept", rows: [][]interface{}{{"  two  "}}, want: [][]string{{"s:  two  "}}},
+		{name: "other values are text", rows: [][]interface{}{{1.5, true, int64(7)}}, want: [][]string{{"s:1.5", "s:true", "s:7"}}},
+		{name: "empty row", rows: [][]interface{}{{}}, want: [][]string{nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			x, err := NewXLSXWriter(&buf, "Sheet")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range tt.rows {
+				err = x.WriteRow(row)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err = x.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sheet := readSheet(t, buf.Bytes())
+			var got [][]string
+			for _, row := range sheet.Rows {
+				var cells []string
+				for _, cell := range row.Cells {
+					if cell.Type == "inlineStr" {
+						cells = append(cells, "s:"+cell.Inline)
+					} else {
+						cells = append(cells, "n:"+cell.Value)
+					}
+				}
+				got = append(got, cells)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if strings.Join(got[i], "|") != strings.Join(tt.want[i], "|") {
+					t.Fatalf("row %d is %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "teachers", want: "teachers"},
+		{name: "a & b", want: "a & b"},
+		{name: strings.Repeat("x", 40), want: strings.Repeat("x", 31)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			var buf bytes.Buffer
+			x, err := NewXLSXWriter(&buf, tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			x.Close()
+
+			var workbook struct {
+				Sheets []struct {
+					Name string `xml:"name,attr"`
+				} `xml:"sheets>sheet"`
+			}
+			err = xml.Unmarshal(readPart(t, buf.Bytes(), "xl/workbook.xml"), &workbook)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != tt.want {
+				t.Fatalf("got sheets %+v, want one named %q", workbook.Sheets, tt.want)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestXLSXWriterKeepsError(t *testing.T) {
+	// The zip writer buffers the first parts, so this does not fail yet
+	x, err := NewXLSXWriter(failingWriter{}, "Sheet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write until the buffer is flushed
+	row := []interface{}{strings.Repeat("x", 1<<16)}
+	for i := 0; i < 100 && err == nil; i++ {
+		err = x.WriteRow(row)
+	}
+	if err == nil {
+		t.Fatal("got no error from a writer that fails")
+	}
+	if x.WriteRow(row) == nil || x.Close() == nil {
+		t.Fatal("the error was not kept")
+	}
+}
+
+func readPart(t *testing.T, file []byte, name string) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func readSheet(t *testing.T, file []byte) xlsxSheet {
+	t.Helper()
+	for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels"} {
+		readPart(t, file, part)
+	}
+	var sheet xlsxSheet
+	err := xml.Unmarshal(readPart(t, file, "xl/worksheets/sheet1.xml"), &sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sheet
+}